@@ -1,18 +1,34 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"criage/pkg"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 var packageManager *pkg.PackageManager
 
-func init() {
-	var err error
-	packageManager, err = pkg.NewPackageManager()
+// initPackageManager создает пакетный менеджер с переопределениями конфигурации
+// из флагов --config key=value
+func initPackageManager(configOverrides []string) {
+	overrides, err := pkg.ParseConfigOverrides(configOverrides)
+	if err == nil {
+		packageManager, err = pkg.NewPackageManagerWithOverrides(overrides)
+	}
 	if err != nil {
 		fmt.Print(pkg.T("error_init_package_manager", err))
 		os.Exit(1)
@@ -20,29 +36,163 @@ func init() {
 }
 
 // installPackage устанавливает пакет
-func installPackage(packageName string) error {
-	return packageManager.InstallPackage(packageName, "", false, false, false, "", "")
+func installPackage(ctx context.Context, packageName, version string, global, force, dev bool, arch, osName string) error {
+	return packageManager.InstallPackage(ctx, packageName, version, global, force, dev, arch, osName)
 }
 
 // uninstallPackage удаляет пакет
-func uninstallPackage(packageName string) error {
-	return packageManager.UninstallPackage(packageName, false, false)
+func uninstallPackage(ctx context.Context, packageName string, global, purge bool) error {
+	return packageManager.UninstallPackage(ctx, packageName, global, purge)
+}
+
+// planInstall показывает план установки пакета без внесения изменений
+func planInstall(ctx context.Context, packageName, version string, global, force, dev bool, arch, osName string, jsonOutput bool) error {
+	plan, err := packageManager.PlanInstall(ctx, packageName, version, global, force, dev, arch, osName)
+	if err != nil {
+		return err
+	}
+	return printPlan(plan, jsonOutput)
+}
+
+// planUninstall показывает план удаления пакета без внесения изменений
+func planUninstall(ctx context.Context, packageName string, global, jsonOutput bool) error {
+	plan, err := packageManager.PlanUninstall(ctx, packageName, global)
+	if err != nil {
+		return err
+	}
+	return printPlan(plan, jsonOutput)
+}
+
+// planUpdate показывает план обновления пакетов без внесения изменений.
+// Без аргументов план строится для всех устаревших пакетов (глобальных при global)
+func planUpdate(ctx context.Context, packageNames []string, global, jsonOutput bool) error {
+	if len(packageNames) == 0 {
+		packages, err := packageManager.ListPackages(ctx, global, true)
+		if err != nil {
+			return err
+		}
+		for _, packageInfo := range packages {
+			packageNames = append(packageNames, packageInfo.Name)
+		}
+	}
+
+	plan := pkg.NewPlan("update")
+	for _, packageName := range packageNames {
+		packagePlan, err := packageManager.PlanUpdate(ctx, packageName)
+		if err != nil {
+			return err
+		}
+		plan.Merge(packagePlan)
+	}
+
+	return printPlan(plan, jsonOutput)
+}
+
+// printPlan выводит план операции в текстовом виде или в формате JSON
+func printPlan(plan *pkg.Plan, jsonOutput bool) error {
+	if jsonOutput {
+		data, err := plan.JSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Println(pkg.T("plan_title", plan.Operation))
+	if plan.IsEmpty() {
+		fmt.Println(pkg.T("plan_nothing_to_do"))
+		return nil
+	}
+
+	symbols := map[pkg.PlanAction]string{
+		pkg.PlanAdd:       "+",
+		pkg.PlanUpgrade:   "↑",
+		pkg.PlanDowngrade: "↓",
+		pkg.PlanReinstall: "~",
+		pkg.PlanRemove:    "-",
+	}
+
+	for _, action := range []pkg.PlanAction{pkg.PlanAdd, pkg.PlanUpgrade, pkg.PlanDowngrade, pkg.PlanReinstall, pkg.PlanRemove} {
+		steps := plan.StepsByAction(action)
+		if len(steps) == 0 {
+			continue
+		}
+
+		fmt.Printf("\n%s (%d):\n", pkg.T("plan_action_"+string(action)), len(steps))
+		for _, step := range steps {
+			versions := step.ToVersion
+			switch {
+			case step.FromVersion != "" && step.ToVersion != "":
+				versions = step.FromVersion + " -> " + step.ToVersion
+			case step.FromVersion != "":
+				versions = step.FromVersion
+			}
+
+			line := fmt.Sprintf("  %s %s %s", symbols[action], step.Name, versions)
+			if step.Repository != "" {
+				line += fmt.Sprintf(" [%s]", step.Repository)
+			}
+			if step.Cached {
+				line += " " + pkg.T("plan_cached")
+			} else if step.DownloadSize > 0 {
+				line += fmt.Sprintf(" %s %s", pkg.T("plan_download"), formatSize(step.DownloadSize))
+			}
+			if step.Dependency {
+				line += " " + pkg.T("plan_dependency")
+			}
+			fmt.Println(line)
+
+			if !step.HooksKnown && action != pkg.PlanRemove {
+				fmt.Printf("      %s\n", pkg.T("plan_hooks_unknown"))
+			}
+			for _, hook := range step.Hooks {
+				fmt.Printf("      %s %s: %s\n", pkg.T("plan_hook"), hook.Stage, hook.Command)
+			}
+		}
+	}
+
+	fmt.Printf("\n%s: %s\n", pkg.T("plan_download_size"), formatSize(plan.DownloadSize))
+	fmt.Printf("%s: %s\n", pkg.T("plan_disk_delta"), formatSizeDelta(plan.DiskDelta))
+	return nil
+}
+
+// formatSize форматирует размер в байтах в удобочитаемом виде
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// formatSizeDelta форматирует изменение размера со знаком
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + formatSize(-delta)
+	}
+	return "+" + formatSize(delta)
 }
 
 // updatePackage обновляет пакет
-func updatePackage(packageName string) error {
-	return packageManager.UpdatePackage(packageName)
+func updatePackage(ctx context.Context, packageName string) error {
+	return packageManager.UpdatePackage(ctx, packageName)
 }
 
-// updateAllPackages обновляет все пакеты
-func updateAllPackages() error {
-	packages, err := packageManager.ListPackages(false, true)
+// updateAllPackages обновляет все устаревшие пакеты (глобальные при global)
+func updateAllPackages(ctx context.Context, global bool) error {
+	packages, err := packageManager.ListPackages(ctx, global, true)
 	if err != nil {
 		return err
 	}
 
 	for _, packageInfo := range packages {
-		if err := packageManager.UpdatePackage(packageInfo.Name); err != nil {
+		if err := packageManager.UpdatePackage(ctx, packageInfo.Name); err != nil {
 			fmt.Print(pkg.T("failed_to_update", packageInfo.Name, err))
 		}
 	}
@@ -50,26 +200,53 @@ func updateAllPackages() error {
 }
 
 // searchPackages выполняет поиск пакетов
-func searchPackages(query string) error {
-	results, err := packageManager.SearchPackages(query)
+func searchPackages(ctx context.Context, query string, options pkg.SearchOptions, jsonOutput bool) error {
+	results, err := packageManager.SearchPackages(ctx, query, options)
 	if err != nil {
 		return err
 	}
 
-	fmt.Print(pkg.T("packages_found", len(results)))
-	for _, result := range results {
-		fmt.Printf("- %s (%s): %s\n", result.Name, result.Version, result.Description)
+	if jsonOutput {
+		return printJSON(results)
+	}
+
+	fmt.Println(pkg.T("packages_found", results.Total))
+	if len(results.Results) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+		pkg.T("column_package"), pkg.T("column_version"), pkg.T("column_repository"),
+		pkg.T("column_downloads"), pkg.T("column_updated"), pkg.T("column_description"))
+	for _, result := range results.Results {
+		updated := "-"
+		if !result.Updated.IsZero() {
+			updated = result.Updated.Format("2006-01-02")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%s\n",
+			result.Name, result.Version, result.Repository, result.Downloads, updated, result.Description)
+	}
+	w.Flush()
+
+	if results.Limit > 0 && results.Total > results.Limit {
+		pages := (results.Total + results.Limit - 1) / results.Limit
+		fmt.Println(pkg.T("search_page_info", results.Page, pages))
 	}
 	return nil
 }
 
 // listPackages показывает список установленных пакетов
-func listPackages() error {
-	packages, err := packageManager.ListPackages(false, false)
+func listPackages(ctx context.Context, global, jsonOutput bool) error {
+	packages, err := packageManager.ListPackages(ctx, global, false)
 	if err != nil {
 		return err
 	}
 
+	if jsonOutput {
+		return printJSON(packages)
+	}
+
 	fmt.Print(pkg.T("packages_installed", len(packages)))
 	for _, pkg := range packages {
 		fmt.Printf("- %s (%s)\n", pkg.Name, pkg.Version)
@@ -77,6 +254,71 @@ func listPackages() error {
 	return nil
 }
 
+// listOutdatedPackages показывает пакеты, для которых доступны обновления.
+// Возвращает ошибку с кодом выхода 1, если хотя бы один пакет устарел, и с кодом 2,
+// если какой-либо пакет не удалось проверить
+func listOutdatedPackages(cmd *cobra.Command, global, jsonOutput bool) error {
+	ctx := cmd.Context()
+	outdated, err := packageManager.ListOutdated(ctx, global)
+	var checkErr *pkg.OutdatedCheckError
+	if err != nil && !errors.As(err, &checkErr) {
+		return err
+	}
+
+	if jsonOutput {
+		if outdated == nil {
+			outdated = []*pkg.OutdatedPackage{}
+		}
+		if err := printJSON(outdated); err != nil {
+			return err
+		}
+	} else if len(outdated) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			pkg.T("column_package"), pkg.T("column_current"), pkg.T("column_wanted"),
+			pkg.T("column_latest"), pkg.T("column_repository"))
+		for _, entry := range outdated {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				entry.Name, entry.Current, entry.Wanted, entry.Latest, entry.Repository)
+		}
+		w.Flush()
+	} else if checkErr == nil {
+		fmt.Println(pkg.T("all_packages_up_to_date"))
+	}
+
+	if checkErr != nil {
+		fmt.Fprintln(os.Stderr, checkErr)
+		return exitWithCode(cmd, 2)
+	}
+	if len(outdated) > 0 {
+		return exitWithCode(cmd, 1)
+	}
+	return nil
+}
+
+// printJSON выводит значение в формате JSON
+func printJSON(value interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// exitCodeError завершает команду с заданным кодом выхода без вывода ошибки
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// exitWithCode отключает вывод ошибки и справки cobra и возвращает exitCodeError
+func exitWithCode(cmd *cobra.Command, code int) error {
+	cmd.SilenceErrors = true
+	cmd.SilenceUsage = true
+	return &exitCodeError{code: code}
+}
+
 // showPackageInfo показывает информацию о пакете
 func showPackageInfo(packageName string) error {
 	info, err := packageManager.GetPackageInfo(packageName)
@@ -99,14 +341,62 @@ func createPackage(name string) error {
 }
 
 // publishPackage публикует пакет
-func publishPackage() error {
-	return packageManager.PublishPackage("", "")
+func publishPackage(ctx context.Context, registry, token string) error {
+	return packageManager.PublishPackage(ctx, registry, token)
+}
+
+// listScripts выводит скрипты манифеста текущего проекта
+func listScripts() error {
+	scripts, err := packageManager.ListScripts()
+	if err != nil {
+		return err
+	}
+	if len(scripts) == 0 {
+		fmt.Println(pkg.T("no_scripts"))
+		return nil
+	}
+
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Println(pkg.T("available_scripts"))
+	for _, name := range names {
+		fmt.Printf("  %s\n    %s\n", name, scripts[name])
+	}
+	return nil
+}
+
+// runScript выполняет скрипт манифеста; код выхода скрипта становится кодом выхода criage
+func runScript(cmd *cobra.Command, name string, args []string) error {
+	err := packageManager.RunScript(cmd.Context(), name, args)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitWithCode(cmd, exitErr.ExitCode())
+	}
+	return err
+}
+
+// showSBOM выводит SBOM архива или установленного пакета либо сохраняет его в output
+func showSBOM(target, output string) error {
+	data, err := packageManager.ReadSBOM(target)
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		return os.WriteFile(output, data, 0644)
+	}
+	fmt.Println(string(data))
+	return nil
 }
 
 // showArchiveMetadata показывает метаданные архива
 func showArchiveMetadata(archivePath string) error {
 	// Используем общий архивный менеджер через фабрику
-	archiveManager, err := pkg.NewCommonArchiveManager(pkg.DefaultConfig(), version)
+	archiveManager, err := pkg.NewCommonArchiveManager(packageManager.GetConfigManager().GetConfig(), version)
 	if err != nil {
 		return fmt.Errorf("failed to create archive manager: %w", err)
 	}
@@ -153,28 +443,596 @@ func showArchiveMetadata(archivePath string) error {
 		}
 	}
 
+	provenance, err := pkg.ReadProvenance(archivePath, format)
+	if err != nil {
+		return fmt.Errorf("failed to read provenance: %w", err)
+	}
+	if provenance == nil {
+		fmt.Printf("\n%s\n", pkg.T("provenance_none"))
+		return nil
+	}
+	showProvenance(provenance)
+
+	return nil
+}
+
+// showProvenance выводит документ происхождения пакета
+func showProvenance(provenance *pkg.Provenance) {
+	fmt.Printf("\n%s\n", pkg.T("provenance_title"))
+
+	source := provenance.Source
+	switch {
+	case source.Revision == "":
+		fmt.Printf("%s: %s\n", pkg.T("metadata_source"), pkg.T("metadata_source_unknown"))
+	case source.Dirty:
+		fmt.Printf("%s: %s %s (%s)\n", pkg.T("metadata_source"), source.VCS, source.Revision, pkg.T("metadata_source_dirty"))
+	default:
+		fmt.Printf("%s: %s %s (%s)\n", pkg.T("metadata_source"), source.VCS, source.Revision, pkg.T("metadata_source_clean"))
+	}
+	if source.Repository != "" {
+		fmt.Printf("  %s\n", source.Repository)
+	}
+	if source.Branch != "" {
+		fmt.Printf("  %s\n", source.Branch)
+	}
+
+	builder := provenance.Builder.ID
+	if provenance.Builder.Version != "" {
+		builder += " " + provenance.Builder.Version
+	}
+	fmt.Printf("%s: %s\n", pkg.T("metadata_builder"), builder)
+	fmt.Printf("%s: %s\n", pkg.T("build_script"), provenance.BuildScript)
+	if len(provenance.BuildEnv) > 0 {
+		fmt.Printf("%s:\n", pkg.T("metadata_build_env"))
+		keys := make([]string, 0, len(provenance.BuildEnv))
+		for key := range provenance.BuildEnv {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Printf("  %s=%s\n", key, provenance.BuildEnv[key])
+		}
+	}
+	if len(provenance.Targets) > 0 {
+		fmt.Printf("%s:\n", pkg.T("target_platforms"))
+		for _, target := range provenance.Targets {
+			fmt.Printf("  - %s/%s\n", target.OS, target.Arch)
+		}
+	}
+	if len(provenance.Inputs) > 0 {
+		fmt.Printf("%s:\n", pkg.T("metadata_inputs"))
+		for _, input := range provenance.Inputs {
+			fmt.Printf("  %s  %s\n", input.SHA256, input.Name)
+		}
+	}
+	fmt.Printf("%s: %d\n", pkg.T("metadata_outputs"), len(provenance.Outputs))
+}
+
+// showPackageDiff показывает различия манифестов двух версий пакета
+func showPackageDiff(ctx context.Context, packageName, from, to string, fetch, jsonOutput bool) error {
+	diff, err := packageManager.DiffPackage(ctx, packageName, from, to, fetch)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(diff)
+	}
+
+	fmt.Printf("%s: %s (%s) -> %s (%s)\n", diff.Name, diff.FromVersion, diff.FromSource, diff.ToVersion, diff.ToSource)
+	if diff.IsEmpty() {
+		fmt.Println(pkg.T("diff_no_changes"))
+	}
+
+	sections := []struct {
+		title   string
+		changes []pkg.ValueChange
+		list    bool
+	}{
+		{pkg.T("package_dependencies"), diff.Dependencies, false},
+		{pkg.T("diff_dev_dependencies"), diff.DevDependencies, false},
+		{pkg.T("diff_hooks"), diff.Hooks, false},
+		{pkg.T("diff_scripts"), diff.Scripts, false},
+		{pkg.T("diff_files"), diff.Files, true},
+		{pkg.T("target_platforms"), diff.Platforms, true},
+	}
+
+	for _, section := range sections {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s:\n", section.title)
+		for _, change := range section.changes {
+			switch {
+			case section.list && change.Kind == pkg.ChangeAdded:
+				fmt.Printf("  + %s\n", change.Key)
+			case section.list:
+				fmt.Printf("  - %s\n", change.Key)
+			case change.Kind == pkg.ChangeAdded:
+				fmt.Printf("  + %s: %s\n", change.Key, change.To)
+			case change.Kind == pkg.ChangeRemoved:
+				fmt.Printf("  - %s: %s\n", change.Key, change.From)
+			default:
+				fmt.Printf("  ~ %s: %s -> %s\n", change.Key, change.From, change.To)
+			}
+		}
+	}
+
+	if !diff.Complete {
+		fmt.Printf("\n%s\n", pkg.T("diff_incomplete"))
+	}
+
+	if diff.Changelog != "" {
+		fmt.Printf("\n%s:\n%s\n", pkg.T("diff_changelog"), diff.Changelog)
+	}
+
 	return nil
 }
 
 // setConfig устанавливает значение конфигурации
 func setConfig(key, value string) error {
-	fmt.Print(pkg.T("config_set", key, value))
-	// Здесь будет реализация установки конфигурации
+	configManager := packageManager.GetConfigManager()
+	if err := configManager.SetValue(key, value); err != nil {
+		return err
+	}
+
+	fmt.Println(pkg.T("config_value_set", key, value))
+	if origin := configManager.Origin(key); !strings.HasPrefix(origin, pkg.LayerUser+":") {
+		fmt.Println(pkg.T("config_value_overridden", key, origin))
+	}
+	return nil
+}
+
+// unsetConfig сбрасывает значение конфигурации
+func unsetConfig(key string) error {
+	if err := packageManager.GetConfigManager().UnsetValue(key); err != nil {
+		return err
+	}
+
+	fmt.Println(pkg.T("config_value_unset", key))
 	return nil
 }
 
 // getConfig получает значение конфигурации
-func getConfig(key string) error {
-	fmt.Print(pkg.T("config_get", key))
-	// Здесь будет реализация получения конфигурации
+func getConfig(key, format string) error {
+	value, err := packageManager.GetConfigManager().GetValue(key)
+	if err != nil {
+		return err
+	}
+
+	if format != "" {
+		return printFormatted(map[string]string{key: value}, format)
+	}
+
+	fmt.Println(value)
 	return nil
 }
 
-// listConfig показывает все настройки
-func listConfig() error {
+// configEntry значение конфигурации с источником
+type configEntry struct {
+	Value  string `json:"value" yaml:"value"`
+	Origin string `json:"origin" yaml:"origin"`
+}
+
+// listConfig показывает все настройки. showOrigin добавляет слой, из которого получено значение
+func listConfig(format string, showOrigin bool) error {
+	configManager := packageManager.GetConfigManager()
+	values := configManager.ListValues()
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+		// Секреты из settings не выводятся; значение доступно через config get
+		if pkg.IsSensitiveKey(key) {
+			values[key] = pkg.MaskSecret(values[key])
+		}
+	}
+	sort.Strings(keys)
+
+	if format != "" {
+		if !showOrigin {
+			return printFormatted(values, format)
+		}
+		entries := make(map[string]configEntry, len(values))
+		for _, key := range keys {
+			entries[key] = configEntry{Value: values[key], Origin: configManager.Origin(key)}
+		}
+		return printFormatted(entries, format)
+	}
+
 	fmt.Println(pkg.T("config_list"))
-	// Здесь будет реализация показа всех настроек
+	if showOrigin {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s\t%s = %s\n", configManager.Origin(key), key, values[key])
+		}
+		return w.Flush()
+	}
+
+	for _, key := range keys {
+		fmt.Printf("%s = %s\n", key, values[key])
+	}
+	return nil
+}
+
+// configFileReport результат проверки файла конфигурации
+type configFileReport struct {
+	Path   string           `json:"path"`
+	Valid  bool             `json:"valid"`
+	Error  string           `json:"error,omitempty"`
+	Fields []pkg.FieldError `json:"fields,omitempty"`
+}
+
+// validateConfig проверяет указанный файл или все файлы конфигурации слоев
+func validateConfig(cmd *cobra.Command, files []string, jsonOutput bool) error {
+	if len(files) == 0 {
+		var err error
+		if files, err = pkg.ConfigFiles(); err != nil {
+			return err
+		}
+	}
+
+	reports := make([]configFileReport, 0, len(files))
+	valid := true
+	for _, path := range files {
+		report := configFileReport{Path: path}
+		fieldErrors, err := pkg.ValidateConfigFile(path)
+		if err != nil {
+			report.Error = err.Error()
+		}
+		report.Fields = fieldErrors
+		report.Valid = err == nil && len(fieldErrors) == 0
+		valid = valid && report.Valid
+		reports = append(reports, report)
+	}
+
+	if jsonOutput {
+		if err := printFormatted(reports, "json"); err != nil {
+			return err
+		}
+	} else {
+		if len(reports) == 0 {
+			fmt.Println(pkg.T("config_validate_none"))
+		}
+		for _, report := range reports {
+			switch {
+			case report.Valid:
+				fmt.Println(pkg.T("config_validate_ok", report.Path))
+			case report.Error != "":
+				fmt.Println(report.Error)
+			default:
+				for _, fieldErr := range report.Fields {
+					fmt.Printf("%s: %s\n", report.Path, fieldErr)
+				}
+			}
+		}
+	}
+
+	if !valid {
+		return exitWithCode(cmd, 1)
+	}
 	return nil
 }
 
-// (удалены неиспользуемые вспомогательные функции)
+// outputFormat возвращает формат вывода из флагов --json и --yaml
+func outputFormat(cmd *cobra.Command) (string, error) {
+	jsonOutput, _ := cmd.Flags().GetBool("json")
+	yamlOutput, _ := cmd.Flags().GetBool("yaml")
+
+	switch {
+	case jsonOutput && yamlOutput:
+		return "", fmt.Errorf("--json and --yaml cannot be used together")
+	case jsonOutput:
+		return "json", nil
+	case yamlOutput:
+		return "yaml", nil
+	default:
+		return "", nil
+	}
+}
+
+// printFormatted выводит значение в формате JSON или YAML
+func printFormatted(value interface{}, format string) error {
+	if format == "yaml" {
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		return encoder.Encode(value)
+	}
+	return printJSON(value)
+}
+
+// addRepository добавляет репозиторий или обновляет существующий
+func addRepository(ctx context.Context, name, url string, priority int, token string) error {
+	configManager := packageManager.GetConfigManager()
+	if err := configManager.AddRepository(name, url, "criage", priority); err != nil {
+		return err
+	}
+	if token != "" {
+		if err := packageManager.Login(ctx, name, pkg.Credential{Token: token}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(pkg.T("repo_added", name, url))
+	return nil
+}
+
+// loginRepository сохраняет учетные данные репозитория. Токен или пароль,
+// не переданные флагом, читаются из stdin
+func loginRepository(ctx context.Context, name, token, username string, passwordStdin bool) error {
+	credential := pkg.Credential{Token: token, Username: username}
+
+	switch {
+	case username != "":
+		password, err := readSecret(pkg.T("login_password_prompt"), passwordStdin)
+		if err != nil {
+			return err
+		}
+		credential.Password = password
+	case token == "":
+		secret, err := readSecret(pkg.T("login_token_prompt"), false)
+		if err != nil {
+			return err
+		}
+		credential.Token = secret
+	}
+
+	if err := packageManager.Login(ctx, name, credential); err != nil {
+		return err
+	}
+
+	fmt.Println(pkg.T("login_success", name))
+	return nil
+}
+
+// logoutRepository удаляет учетные данные репозитория
+func logoutRepository(ctx context.Context, name string) error {
+	if err := packageManager.Logout(ctx, name); err != nil {
+		return err
+	}
+
+	fmt.Println(pkg.T("logout_success", name))
+	return nil
+}
+
+// readSecret читает строку из stdin. Приглашение выводится только для терминала
+// и если значение не передается явно через --password-stdin
+func readSecret(prompt string, fromStdin bool) (string, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 && !fromStdin {
+		fmt.Fprint(os.Stderr, prompt)
+	}
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read from stdin: %w", err)
+	}
+
+	secret := strings.TrimRight(line, "\r\n")
+	if secret == "" {
+		return "", fmt.Errorf("empty value read from stdin")
+	}
+	return secret, nil
+}
+
+// removeRepository удаляет репозиторий
+func removeRepository(name string) error {
+	if err := packageManager.GetConfigManager().RemoveRepository(name); err != nil {
+		return err
+	}
+
+	fmt.Println(pkg.T("repo_removed", name))
+	return nil
+}
+
+// setRepositoryEnabled включает или отключает репозиторий
+func setRepositoryEnabled(name string, enabled bool) error {
+	if err := packageManager.GetConfigManager().SetRepositoryEnabled(name, enabled); err != nil {
+		return err
+	}
+
+	if enabled {
+		fmt.Println(pkg.T("repo_enabled", name))
+	} else {
+		fmt.Println(pkg.T("repo_disabled", name))
+	}
+	return nil
+}
+
+// setRepositoryPriority устанавливает приоритет репозитория
+func setRepositoryPriority(name, value string) error {
+	priority, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid priority: %s", value)
+	}
+
+	if err := packageManager.GetConfigManager().SetRepositoryPriority(name, priority); err != nil {
+		return err
+	}
+
+	fmt.Println(pkg.T("repo_priority_set", name, priority))
+	return nil
+}
+
+// setRepositoryRateLimit задает или сбрасывает ограничение частоты запросов к репозиторию.
+// Без значения показывает действующее ограничение
+func setRepositoryRateLimit(args []string, burst int, reset bool) error {
+	configManager := packageManager.GetConfigManager()
+	name := args[0]
+
+	switch {
+	case reset:
+		if err := configManager.SetRepositoryRateLimit(name, nil); err != nil {
+			return err
+		}
+		fmt.Println(pkg.T("repo_rate_limit_reset", name))
+		return nil
+	case len(args) == 1:
+		if _, exists := configManager.GetRepository(name); !exists {
+			return fmt.Errorf("repository not found: %s", name)
+		}
+		limits := configManager.GetConfig().RateLimit.ForRepository(name)
+		fmt.Println(pkg.T("repo_rate_limit_value", name, limits.RequestsPerSecond, limits.Burst))
+		return nil
+	}
+
+	rate, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid requests per second: %s", args[1])
+	}
+	if burst == 0 {
+		// По умолчанию burst равен скорости за одну секунду
+		burst = int(rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+
+	limits := pkg.RateLimitSettings{RequestsPerSecond: rate, Burst: burst}
+	if err := configManager.SetRepositoryRateLimit(name, &limits); err != nil {
+		return err
+	}
+	fmt.Println(pkg.T("repo_rate_limit_value", name, limits.RequestsPerSecond, limits.Burst))
+	return nil
+}
+
+// listRepositories показывает репозитории в порядке приоритета
+func listRepositories(ctx context.Context, jsonOutput bool) error {
+	repositories := append([]pkg.Repository(nil), packageManager.GetConfigManager().GetRepositories()...)
+	sort.SliceStable(repositories, func(i, j int) bool {
+		return repositories[i].Priority > repositories[j].Priority
+	})
+
+	if jsonOutput {
+		for i := range repositories {
+			if repositories[i].AuthToken != "" {
+				repositories[i].AuthToken = "****"
+			}
+			repositories[i].Password = ""
+		}
+		return printJSON(repositories)
+	}
+
+	if len(repositories) == 0 {
+		fmt.Println(pkg.T("repo_none"))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+		pkg.T("column_name"), pkg.T("column_url"), pkg.T("column_priority"),
+		pkg.T("column_enabled"), pkg.T("column_auth"))
+	for _, repo := range repositories {
+		hasCredentials, err := packageManager.HasCredentials(ctx, repo)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%t\t%t\n",
+			repo.Name, repo.URL, repo.Priority, repo.Enabled, hasCredentials)
+	}
+	return w.Flush()
+}
+
+// showRepositoryInfo показывает информацию и статистику репозитория
+func showRepositoryInfo(ctx context.Context, name string, jsonOutput bool) error {
+	details, err := packageManager.GetRepositoryDetails(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		if details.Repository.AuthToken != "" {
+			details.Repository.AuthToken = "****"
+		}
+		details.Repository.Password = ""
+		return printJSON(details)
+	}
+
+	repo := details.Repository
+	fmt.Printf("%s: %s\n", pkg.T("column_name"), repo.Name)
+	fmt.Printf("%s: %s\n", pkg.T("column_url"), repo.URL)
+	fmt.Printf("%s: %d\n", pkg.T("column_priority"), repo.Priority)
+	fmt.Printf("%s: %t\n", pkg.T("column_enabled"), repo.Enabled)
+	fmt.Printf("%s: %s\n", pkg.T("column_rate_limit"), pkg.T("rate_limit_format", details.RateLimit.RequestsPerSecond, details.RateLimit.Burst))
+
+	keys := make([]string, 0, len(details.Server))
+	for key := range details.Server {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("%s: %v\n", key, details.Server[key])
+	}
+
+	if stats := details.Stats; stats != nil {
+		fmt.Printf("\n%s\n", pkg.T("repo_stats"))
+		fmt.Printf("  %s: %d\n", pkg.T("repo_total_downloads"), stats.TotalDownloads)
+		fmt.Printf("  %s: %d\n", pkg.T("repo_authors"), len(stats.PackagesByAuthor))
+		if len(stats.PackagesByLicense) > 0 {
+			licenses := make([]string, 0, len(stats.PackagesByLicense))
+			for license, count := range stats.PackagesByLicense {
+				licenses = append(licenses, fmt.Sprintf("%s (%d)", license, count))
+			}
+			sort.Strings(licenses)
+			fmt.Printf("  %s: %s\n", pkg.T("repo_licenses"), strings.Join(licenses, ", "))
+		}
+		if len(stats.PopularPackages) > 0 {
+			fmt.Printf("  %s: %s\n", pkg.T("repo_popular"), strings.Join(stats.PopularPackages, ", "))
+		}
+	}
+
+	return nil
+}
+
+// pingRepositories проверяет доступность репозиториев. Без аргументов проверяются
+// все включенные репозитории. Возвращает код выхода 1, если какой-либо недоступен
+func pingRepositories(cmd *cobra.Command, names []string, jsonOutput bool) error {
+	ctx := cmd.Context()
+	if len(names) == 0 {
+		for _, repo := range packageManager.GetConfigManager().GetRepositories() {
+			if repo.Enabled {
+				names = append(names, repo.Name)
+			}
+		}
+	}
+
+	results := make([]*pkg.RepositoryPing, 0, len(names))
+	failed := false
+	for _, name := range names {
+		ping, err := packageManager.PingRepository(ctx, name)
+		if err != nil {
+			return err
+		}
+		if ping.Error != "" || !ping.Compatible {
+			failed = true
+		}
+		results = append(results, ping)
+	}
+
+	if jsonOutput {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, ping := range results {
+			latency := ping.Latency.Round(time.Millisecond)
+			switch {
+			case ping.Error != "":
+				fmt.Println(pkg.T("repo_ping_failed", ping.Name, ping.Error))
+			case !ping.Compatible:
+				fmt.Println(pkg.T("repo_ping_incompatible", ping.Name, latency, ping.APIVersion, pkg.SupportedAPIVersion))
+			default:
+				version := ping.APIVersion
+				if version == "" {
+					version = "?"
+				}
+				fmt.Println(pkg.T("repo_ping_ok", ping.Name, latency, version))
+			}
+		}
+	}
+
+	if failed {
+		return exitWithCode(cmd, 1)
+	}
+	return nil
+}
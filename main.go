@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"criage/pkg"
 
@@ -14,6 +18,8 @@ var (
 )
 
 func main() {
+	pkg.BuildVersion = version
+
 	// Инициализируем локализацию (автоматически выбирает embedded/внешние файлы)
 	l := pkg.GetLocalization()
 
@@ -32,8 +38,19 @@ func main() {
 		Short:   l.Get("app_description"),
 		Long:    l.Get("app_long_description"),
 		Version: version,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Аргументы разобраны: ошибки выполнения (в том числе отмена по Ctrl-C)
+			// выводятся ниже без справки по команде
+			cmd.SilenceUsage = true
+			cmd.SilenceErrors = true
+
+			configOverrides, _ := cmd.Flags().GetStringArray("config")
+			initPackageManager(configOverrides)
+		},
 	}
 
+	rootCmd.PersistentFlags().StringArray("config", nil, l.Get("flag_config_override"))
+
 	// Команды управления пакетами
 	rootCmd.AddCommand(
 		newInstallCmd(),
@@ -44,12 +61,41 @@ func main() {
 		newInfoCmd(),
 		newCreateCmd(),
 		newBuildCmd(),
+		newPackCmd(),
 		newPublishCmd(),
+		newRunCmd(),
 		newConfigCmd(),
+		newRepoCmd(),
+		newLoginCmd(),
+		newLogoutCmd(),
 		newMetadataCmd(),
+		newSBOMCmd(),
+		newDiffCmd(),
 	)
 
-	if err := rootCmd.Execute(); err != nil {
+	// SIGINT/SIGTERM отменяют текущую операцию; повторный сигнал завершает процесс сразу
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	err := rootCmd.ExecuteContext(ctx)
+	cancelled := ctx.Err() != nil
+	stop()
+	if packageManager != nil {
+		packageManager.Close()
+	}
+
+	if err != nil {
+		if cancelled {
+			fmt.Println(l.Get("operation_cancelled"))
+			os.Exit(130)
+		}
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -65,7 +111,19 @@ func newInstallCmd() *cobra.Command {
 		Long:  l.Get("cmd_install_long"),
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return installPackage(args[0])
+			global, _ := cmd.Flags().GetBool("global")
+			version, _ := cmd.Flags().GetString("version")
+			force, _ := cmd.Flags().GetBool("force")
+			dev, _ := cmd.Flags().GetBool("dev")
+			arch, _ := cmd.Flags().GetString("arch")
+			osName, _ := cmd.Flags().GetString("os")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			if dryRun {
+				return planInstall(cmd.Context(), args[0], version, global, force, dev, arch, osName, jsonOutput)
+			}
+			return installPackage(cmd.Context(), args[0], version, global, force, dev, arch, osName)
 		},
 	}
 
@@ -75,6 +133,8 @@ func newInstallCmd() *cobra.Command {
 	cmd.Flags().BoolP("dev", "d", false, l.Get("flag_dev"))
 	cmd.Flags().StringP("arch", "a", "", l.Get("flag_arch"))
 	cmd.Flags().StringP("os", "o", "", l.Get("flag_os"))
+	cmd.Flags().Bool("dry-run", false, l.Get("flag_dry_run"))
+	cmd.Flags().Bool("json", false, l.Get("flag_json"))
 
 	return cmd
 }
@@ -89,12 +149,22 @@ func newUninstallCmd() *cobra.Command {
 		Long:  l.Get("cmd_uninstall_long"),
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return uninstallPackage(args[0])
+			global, _ := cmd.Flags().GetBool("global")
+			purge, _ := cmd.Flags().GetBool("purge")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			if dryRun {
+				return planUninstall(cmd.Context(), args[0], global, jsonOutput)
+			}
+			return uninstallPackage(cmd.Context(), args[0], global, purge)
 		},
 	}
 
 	cmd.Flags().BoolP("global", "g", false, l.Get("flag_global"))
 	cmd.Flags().BoolP("purge", "p", false, l.Get("flag_purge"))
+	cmd.Flags().Bool("dry-run", false, l.Get("flag_dry_run"))
+	cmd.Flags().Bool("json", false, l.Get("flag_json"))
 
 	return cmd
 }
@@ -108,15 +178,24 @@ func newUpdateCmd() *cobra.Command {
 		Short: l.Get("cmd_update"),
 		Long:  l.Get("cmd_update_long"),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			global, _ := cmd.Flags().GetBool("global")
+			dryRun, _ := cmd.Flags().GetBool("dry-run")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			if dryRun {
+				return planUpdate(cmd.Context(), args, global, jsonOutput)
+			}
 			if len(args) == 0 {
-				return updateAllPackages()
+				return updateAllPackages(cmd.Context(), global)
 			}
-			return updatePackage(args[0])
+			return updatePackage(cmd.Context(), args[0])
 		},
 	}
 
 	cmd.Flags().BoolP("global", "g", false, l.Get("flag_global"))
 	cmd.Flags().BoolP("all", "a", false, l.Get("flag_all"))
+	cmd.Flags().Bool("dry-run", false, l.Get("flag_dry_run"))
+	cmd.Flags().Bool("json", false, l.Get("flag_json"))
 
 	return cmd
 }
@@ -125,15 +204,52 @@ func newUpdateCmd() *cobra.Command {
 func newSearchCmd() *cobra.Command {
 	l := pkg.GetLocalization()
 
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "search [query]",
 		Short: l.Get("cmd_search"),
 		Long:  l.Get("cmd_search_long"),
 		Args:  cobra.MinimumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return searchPackages(args[0])
+			repo, _ := cmd.Flags().GetString("repo")
+			timeout, _ := cmd.Flags().GetDuration("timeout")
+			osName, _ := cmd.Flags().GetString("os")
+			arch, _ := cmd.Flags().GetString("arch")
+			author, _ := cmd.Flags().GetString("author")
+			license, _ := cmd.Flags().GetString("license")
+			keyword, _ := cmd.Flags().GetString("keyword")
+			sortBy, _ := cmd.Flags().GetString("sort")
+			limit, _ := cmd.Flags().GetInt("limit")
+			page, _ := cmd.Flags().GetInt("page")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			return searchPackages(cmd.Context(), args[0], pkg.SearchOptions{
+				Repository: repo,
+				Timeout:    timeout,
+				OS:         osName,
+				Arch:       arch,
+				Author:     author,
+				License:    license,
+				Keyword:    keyword,
+				Sort:       sortBy,
+				Limit:      limit,
+				Page:       page,
+			}, jsonOutput)
 		},
 	}
+
+	cmd.Flags().StringP("repo", "r", "", l.Get("flag_repo"))
+	cmd.Flags().Duration("timeout", 0, l.Get("flag_search_timeout"))
+	cmd.Flags().String("os", "", l.Get("flag_os"))
+	cmd.Flags().String("arch", "", l.Get("flag_arch"))
+	cmd.Flags().String("author", "", l.Get("flag_author"))
+	cmd.Flags().String("license", "", l.Get("flag_license"))
+	cmd.Flags().String("keyword", "", l.Get("flag_keyword"))
+	cmd.Flags().String("sort", pkg.SearchSortScore, l.Get("flag_sort"))
+	cmd.Flags().Int("limit", pkg.DefaultSearchLimit, l.Get("flag_limit"))
+	cmd.Flags().Int("page", 1, l.Get("flag_page"))
+	cmd.Flags().Bool("json", false, l.Get("flag_json"))
+
+	return cmd
 }
 
 // Команда списка пакетов
@@ -145,12 +261,20 @@ func newListCmd() *cobra.Command {
 		Short: l.Get("cmd_list"),
 		Long:  l.Get("cmd_list_long"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listPackages()
+			global, _ := cmd.Flags().GetBool("global")
+			outdated, _ := cmd.Flags().GetBool("outdated")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			if outdated {
+				return listOutdatedPackages(cmd, global, jsonOutput)
+			}
+			return listPackages(cmd.Context(), global, jsonOutput)
 		},
 	}
 
 	cmd.Flags().BoolP("global", "g", false, l.Get("flag_global"))
 	cmd.Flags().BoolP("outdated", "o", false, l.Get("flag_outdated"))
+	cmd.Flags().Bool("json", false, l.Get("flag_json"))
 
 	return cmd
 }
@@ -203,14 +327,88 @@ func newBuildCmd() *cobra.Command {
 			output, _ := cmd.Flags().GetString("output")
 			format, _ := cmd.Flags().GetString("format")
 			compression, _ := cmd.Flags().GetInt("compression")
+			targets, _ := cmd.Flags().GetStringArray("target")
+			cleanEnv, _ := cmd.Flags().GetBool("clean-env")
+			keepEnv, _ := cmd.Flags().GetStringArray("keep-env")
+			reproducible, _ := cmd.Flags().GetBool("reproducible")
+			verifyReproducible, _ := cmd.Flags().GetBool("verify-reproducible")
+			sbomFormat, _ := cmd.Flags().GetString("sbom")
+			noCache, _ := cmd.Flags().GetBool("no-cache")
+
+			_, err := packageManager.BuildPackage(cmd.Context(), pkg.BuildOptions{
+				Output:             output,
+				Format:             format,
+				CompressionLevel:   compression,
+				Targets:            targets,
+				CleanEnv:           cleanEnv,
+				KeepEnv:            keepEnv,
+				Reproducible:       reproducible,
+				VerifyReproducible: verifyReproducible,
+				SBOMFormat:         sbomFormat,
+				NoCache:            noCache,
+			})
+			return err
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", l.Get("flag_output"))
+	cmd.Flags().StringP("format", "f", "tar.zst", l.Get("flag_format"))
+	cmd.Flags().IntP("compression", "c", 3, l.Get("flag_compression"))
+	cmd.Flags().StringArray("target", nil, l.Get("flag_build_target"))
+	cmd.Flags().Bool("clean-env", false, l.Get("flag_clean_env"))
+	cmd.Flags().StringArray("keep-env", nil, l.Get("flag_keep_env"))
+	cmd.Flags().Bool("reproducible", false, l.Get("flag_reproducible"))
+	cmd.Flags().Bool("verify-reproducible", false, l.Get("flag_verify_reproducible"))
+	cmd.Flags().Bool("no-cache", false, l.Get("flag_no_cache"))
+	cmd.Flags().String("sbom", pkg.SBOMFormatSPDX, l.Get("flag_sbom"))
+
+	return cmd
+}
+
+// Команда упаковки собранной директории
+func newPackCmd() *cobra.Command {
+	l := pkg.GetLocalization()
 
-			return packageManager.BuildPackage(output, format, compression)
+	cmd := &cobra.Command{
+		Use:   "pack <directory>",
+		Short: l.Get("cmd_pack"),
+		Long:  l.Get("cmd_pack_long"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifestPath, _ := cmd.Flags().GetString("manifest")
+			name, _ := cmd.Flags().GetString("name")
+			version, _ := cmd.Flags().GetString("version")
+			output, _ := cmd.Flags().GetString("output")
+			format, _ := cmd.Flags().GetString("format")
+			compression, _ := cmd.Flags().GetInt("compression")
+			exclude, _ := cmd.Flags().GetStringArray("exclude")
+			reproducible, _ := cmd.Flags().GetBool("reproducible")
+			sbomFormat, _ := cmd.Flags().GetString("sbom")
+
+			_, err := packageManager.PackDirectory(cmd.Context(), args[0], pkg.PackOptions{
+				ManifestPath:     manifestPath,
+				Name:             name,
+				Version:          version,
+				Output:           output,
+				Format:           format,
+				CompressionLevel: compression,
+				ExcludeFiles:     exclude,
+				Reproducible:     reproducible,
+				SBOMFormat:       sbomFormat,
+			})
+			return err
 		},
 	}
 
+	cmd.Flags().StringP("manifest", "m", "", l.Get("flag_pack_manifest"))
+	cmd.Flags().String("name", "", l.Get("flag_pack_name"))
+	cmd.Flags().String("version", "", l.Get("flag_pack_version"))
 	cmd.Flags().StringP("output", "o", "", l.Get("flag_output"))
 	cmd.Flags().StringP("format", "f", "tar.zst", l.Get("flag_format"))
 	cmd.Flags().IntP("compression", "c", 3, l.Get("flag_compression"))
+	cmd.Flags().StringArray("exclude", nil, l.Get("flag_pack_exclude"))
+	cmd.Flags().Bool("reproducible", false, l.Get("flag_reproducible"))
+	cmd.Flags().String("sbom", pkg.SBOMFormatSPDX, l.Get("flag_sbom"))
 
 	return cmd
 }
@@ -224,7 +422,9 @@ func newPublishCmd() *cobra.Command {
 		Short: l.Get("cmd_publish"),
 		Long:  l.Get("cmd_publish_long"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return publishPackage()
+			registry, _ := cmd.Flags().GetString("registry")
+			token, _ := cmd.Flags().GetString("token")
+			return publishPackage(cmd.Context(), registry, token)
 		},
 	}
 
@@ -234,6 +434,28 @@ func newPublishCmd() *cobra.Command {
 	return cmd
 }
 
+// Команда запуска скриптов манифеста
+func newRunCmd() *cobra.Command {
+	l := pkg.GetLocalization()
+
+	cmd := &cobra.Command{
+		Use:   "run [script] [args...]",
+		Short: l.Get("cmd_run"),
+		Long:  l.Get("cmd_run_long"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				return listScripts()
+			}
+			return runScript(cmd, args[0], args[1:])
+		},
+	}
+
+	// Флаги после имени скрипта передаются скрипту
+	cmd.Flags().SetInterspersed(false)
+
+	return cmd
+}
+
 // Команда конфигурации
 func newConfigCmd() *cobra.Command {
 	l := pkg.GetLocalization()
@@ -260,7 +482,23 @@ func newConfigCmd() *cobra.Command {
 		Short: l.Get("config_get"),
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return getConfig(args[0])
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			return getConfig(args[0], format)
+		},
+	}
+	getCmd.Flags().Bool("json", false, l.Get("flag_json"))
+	getCmd.Flags().Bool("yaml", false, l.Get("flag_yaml"))
+
+	// Подкоманда unset
+	unsetCmd := &cobra.Command{
+		Use:   "unset [key]",
+		Short: l.Get("config_unset"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return unsetConfig(args[0])
 		},
 	}
 
@@ -269,11 +507,33 @@ func newConfigCmd() *cobra.Command {
 		Use:   "list",
 		Short: l.Get("config_list"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return listConfig()
+			format, err := outputFormat(cmd)
+			if err != nil {
+				return err
+			}
+			showOrigin, _ := cmd.Flags().GetBool("show-origin")
+			return listConfig(format, showOrigin)
 		},
 	}
+	listCmd.Flags().Bool("show-origin", false, l.Get("flag_show_origin"))
+	listCmd.Flags().Bool("json", false, l.Get("flag_json"))
+	listCmd.Flags().Bool("yaml", false, l.Get("flag_yaml"))
+
+	// Подкоманда validate работает без инициализации менеджера пакетов,
+	// чтобы проверять в том числе поврежденную конфигурацию
+	validateCmd := &cobra.Command{
+		Use:              "validate [file]",
+		Short:            l.Get("config_validate"),
+		Args:             cobra.MaximumNArgs(1),
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			return validateConfig(cmd, args, jsonOutput)
+		},
+	}
+	validateCmd.Flags().Bool("json", false, l.Get("flag_json"))
 
-	cmd.AddCommand(setCmd, getCmd, listCmd)
+	cmd.AddCommand(setCmd, getCmd, unsetCmd, listCmd, validateCmd)
 	return cmd
 }
 
@@ -291,3 +551,207 @@ func newMetadataCmd() *cobra.Command {
 		},
 	}
 }
+
+// Команда вывода SBOM пакета
+func newSBOMCmd() *cobra.Command {
+	l := pkg.GetLocalization()
+
+	cmd := &cobra.Command{
+		Use:   "sbom <archive|package>",
+		Short: l.Get("cmd_sbom"),
+		Long:  l.Get("cmd_sbom_long"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, _ := cmd.Flags().GetString("output")
+			return showSBOM(args[0], output)
+		},
+	}
+
+	cmd.Flags().StringP("output", "o", "", l.Get("flag_sbom_output"))
+
+	return cmd
+}
+
+// Команда сравнения версий пакета
+func newDiffCmd() *cobra.Command {
+	l := pkg.GetLocalization()
+
+	cmd := &cobra.Command{
+		Use:   "diff [package] [from] [to]",
+		Short: l.Get("cmd_diff"),
+		Long:  l.Get("cmd_diff_long"),
+		Args:  cobra.RangeArgs(1, 3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fetch, _ := cmd.Flags().GetBool("fetch")
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+
+			var from, to string
+			if len(args) > 1 {
+				from = args[1]
+			}
+			if len(args) > 2 {
+				to = args[2]
+			}
+			return showPackageDiff(cmd.Context(), args[0], from, to, fetch, jsonOutput)
+		},
+	}
+
+	cmd.Flags().Bool("fetch", false, l.Get("flag_fetch"))
+	cmd.Flags().Bool("json", false, l.Get("flag_json"))
+
+	return cmd
+}
+
+// Команда входа в репозиторий
+func newLoginCmd() *cobra.Command {
+	l := pkg.GetLocalization()
+
+	cmd := &cobra.Command{
+		Use:   "login [repository]",
+		Short: l.Get("cmd_login"),
+		Long:  l.Get("cmd_login_long"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, _ := cmd.Flags().GetString("token")
+			username, _ := cmd.Flags().GetString("username")
+			passwordStdin, _ := cmd.Flags().GetBool("password-stdin")
+			return loginRepository(cmd.Context(), args[0], token, username, passwordStdin)
+		},
+	}
+
+	cmd.Flags().String("token", "", l.Get("flag_token"))
+	cmd.Flags().StringP("username", "u", "", l.Get("flag_username"))
+	cmd.Flags().Bool("password-stdin", false, l.Get("flag_password_stdin"))
+
+	return cmd
+}
+
+// Команда выхода из репозитория
+func newLogoutCmd() *cobra.Command {
+	l := pkg.GetLocalization()
+
+	return &cobra.Command{
+		Use:   "logout [repository]",
+		Short: l.Get("cmd_logout"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return logoutRepository(cmd.Context(), args[0])
+		},
+	}
+}
+
+// Команда управления репозиториями
+func newRepoCmd() *cobra.Command {
+	l := pkg.GetLocalization()
+
+	cmd := &cobra.Command{
+		Use:   "repo",
+		Short: l.Get("cmd_repo"),
+		Long:  l.Get("cmd_repo_long"),
+	}
+
+	// Подкоманда add
+	addCmd := &cobra.Command{
+		Use:   "add [name] [url]",
+		Short: l.Get("repo_add"),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			priority, _ := cmd.Flags().GetInt("priority")
+			token, _ := cmd.Flags().GetString("token")
+			return addRepository(cmd.Context(), args[0], args[1], priority, token)
+		},
+	}
+	addCmd.Flags().Int("priority", 5, l.Get("flag_priority"))
+	addCmd.Flags().String("token", "", l.Get("flag_token"))
+
+	// Подкоманда remove
+	removeCmd := &cobra.Command{
+		Use:     "remove [name]",
+		Aliases: []string{"rm"},
+		Short:   l.Get("repo_remove"),
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return removeRepository(args[0])
+		},
+	}
+
+	// Подкоманда list
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: l.Get("repo_list"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			return listRepositories(cmd.Context(), jsonOutput)
+		},
+	}
+	listCmd.Flags().Bool("json", false, l.Get("flag_json"))
+
+	// Подкоманды enable и disable
+	enableCmd := &cobra.Command{
+		Use:   "enable [name]",
+		Short: l.Get("repo_enable"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setRepositoryEnabled(args[0], true)
+		},
+	}
+	disableCmd := &cobra.Command{
+		Use:   "disable [name]",
+		Short: l.Get("repo_disable"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setRepositoryEnabled(args[0], false)
+		},
+	}
+
+	// Подкоманда priority
+	priorityCmd := &cobra.Command{
+		Use:   "priority [name] [priority]",
+		Short: l.Get("repo_priority"),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return setRepositoryPriority(args[0], args[1])
+		},
+	}
+
+	// Подкоманда rate-limit
+	rateLimitCmd := &cobra.Command{
+		Use:   "rate-limit [name] [requests-per-second]",
+		Short: l.Get("repo_rate_limit"),
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			burst, _ := cmd.Flags().GetInt("burst")
+			reset, _ := cmd.Flags().GetBool("reset")
+			return setRepositoryRateLimit(args, burst, reset)
+		},
+	}
+	rateLimitCmd.Flags().Int("burst", 0, l.Get("flag_burst"))
+	rateLimitCmd.Flags().Bool("reset", false, l.Get("flag_rate_limit_reset"))
+
+	// Подкоманда info
+	infoCmd := &cobra.Command{
+		Use:   "info [name]",
+		Short: l.Get("repo_info"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			return showRepositoryInfo(cmd.Context(), args[0], jsonOutput)
+		},
+	}
+	infoCmd.Flags().Bool("json", false, l.Get("flag_json"))
+
+	// Подкоманда ping
+	pingCmd := &cobra.Command{
+		Use:     "ping [name...]",
+		Aliases: []string{"check"},
+		Short:   l.Get("repo_ping"),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, _ := cmd.Flags().GetBool("json")
+			return pingRepositories(cmd, args, jsonOutput)
+		},
+	}
+	pingCmd.Flags().Bool("json", false, l.Get("flag_json"))
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd, enableCmd, disableCmd, priorityCmd, rateLimitCmd, infoCmd, pingCmd)
+	return cmd
+}
@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"reflect"
 	"testing"
@@ -293,13 +294,15 @@ func TestApiSchemaCompatibility(t *testing.T) {
 // TestRateLimiterFunctionality проверяет работу rate limiter
 func TestRateLimiterFunctionality(t *testing.T) {
 	// Создаем rate limiter с высокой частотой для быстрого тестирования
-	rl := NewRateLimiter(100) // 100 запросов в секунду
+	rl := NewRateLimiter(100, 1) // 100 запросов в секунду
 	defer rl.Close()
 
 	// Проверяем, что rate limiter не блокирует нормальные запросы
 	start := time.Now()
 	for i := 0; i < 5; i++ {
-		rl.Wait()
+		if err := rl.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
 	}
 	elapsed := time.Since(start)
 
@@ -309,12 +312,14 @@ func TestRateLimiterFunctionality(t *testing.T) {
 	}
 
 	// Проверяем, что rate limiter действительно ограничивает частоту
-	rl2 := NewRateLimiter(2) // 2 запроса в секунду
+	rl2 := NewRateLimiter(2, 1) // 2 запроса в секунду без burst
 	defer rl2.Close()
 
 	start = time.Now()
 	for i := 0; i < 3; i++ {
-		rl2.Wait()
+		if err := rl2.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
 	}
 	elapsed = time.Since(start)
 
@@ -326,12 +331,12 @@ func TestRateLimiterFunctionality(t *testing.T) {
 
 // BenchmarkRateLimiter бенчмарк для rate limiter
 func BenchmarkRateLimiter(b *testing.B) {
-	rl := NewRateLimiter(1000) // 1000 запросов в секунду
+	rl := NewRateLimiter(1000, 1) // 1000 запросов в секунду
 	defer rl.Close()
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		rl.Wait()
+		rl.Wait(context.Background())
 	}
 }
 
@@ -344,18 +349,18 @@ func TestNewApiEndpoints(t *testing.T) {
 
 	// Проверяем, что методы существуют (компиляция пройдет только если методы определены)
 	// Вызываем методы с пустыми параметрами для проверки их наличия
-	_, err = pm.ListRepositoryPackages("", 1, 10)
+	_, err = pm.ListRepositoryPackages(context.Background(), "", 1, 10)
 	if err == nil {
 		t.Log("ListRepositoryPackages method is available")
 	}
 
-	_, err = pm.GetPackageVersion("", "", "")
+	_, err = pm.GetPackageVersion(context.Background(), "", "", "")
 	if err == nil {
 		t.Log("GetPackageVersion method is available")
 	}
 
-	// Проверяем, что rate limiter инициализирован
-	if pm.rateLimiter == nil {
+	// Проверяем, что rate limiter создается для репозитория
+	if pm.rateLimiterFor("default") == nil {
 		t.Error("Rate limiter is not initialized")
 	}
 }
@@ -8,7 +8,7 @@ import (
 
 // NewCommonArchiveManager создает общий архивный менеджер на базе конфигурации клиента
 func NewCommonArchiveManager(cfg *Config, version string) (*commonarchive.Manager, error) {
-	commonCfg := toCommonConfig(cfg)
+	commonCfg := toCommonConfig(cfg, version)
 	if commonCfg == nil {
 		commonCfg = commonconfig.DefaultConfig()
 	}
@@ -16,10 +16,16 @@ func NewCommonArchiveManager(cfg *Config, version string) (*commonarchive.Manage
 }
 
 // toCommonConfig минимальный маппинг локального Config в общий config.Config
-func toCommonConfig(cfg *Config) *commonconfig.Config {
+func toCommonConfig(cfg *Config, version string) *commonconfig.Config {
 	if cfg == nil {
 		return commonconfig.DefaultConfig()
 	}
+	// Конфигурация может прийти напрямую из DefaultConfig() с путями вида ~/.cache/criage
+	normalized := *cfg
+	if err := normalizeConfigPaths(&normalized, nil); err == nil {
+		cfg = &normalized
+	}
+
 	repos := make([]commontypes.Repository, 0, len(cfg.Repositories))
 	for _, r := range cfg.Repositories {
 		repos = append(repos, commontypes.Repository{
@@ -35,8 +41,8 @@ func toCommonConfig(cfg *Config) *commonconfig.Config {
 		CachePath:        cfg.CachePath,
 		TempPath:         cfg.TempPath,
 		Timeout:          cfg.Timeout,
-		MaxConnections:   10,
-		UserAgent:        "CriageClient",
+		MaxConnections:   cfg.Network.MaxConnections,
+		UserAgent:        UserAgent(cfg.Network, version),
 		Repositories:     repos,
 		CompressionLevel: cfg.Compression.Level,
 		PreferredFormat:  cfg.Compression.Format,
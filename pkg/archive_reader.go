@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	commontypes "github.com/criage-oss/criage-common/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// errArchiveFileNotFound файла нет в архиве
+var errArchiveFileNotFound = errors.New("file not found in archive")
+
+// readArchiveFile читает файл name из корня архива без распаковки остальных файлов
+func readArchiveFile(archivePath string, format ArchiveFormat, name string) ([]byte, error) {
+	if format == commontypes.FormatZip {
+		reader, err := zip.OpenReader(archivePath)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		file, err := reader.Open(name)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, errArchiveFileNotFound
+			}
+			return nil, err
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decompressor, err := newDecompressor(file, format)
+	if err != nil {
+		return nil, err
+	}
+	defer decompressor.Close()
+
+	tarReader := tar.NewReader(decompressor)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, errArchiveFileNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Name == name || header.Name == "./"+name {
+			return io.ReadAll(tarReader)
+		}
+	}
+}
+
+// newDecompressor оборачивает r в поток распаковки формата format
+func newDecompressor(r io.Reader, format ArchiveFormat) (io.ReadCloser, error) {
+	switch format {
+	case commontypes.FormatTarZst:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case commontypes.FormatTarLZ4:
+		return io.NopCloser(lz4.NewReader(r)), nil
+	case commontypes.FormatTarXZ:
+		reader, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(reader), nil
+	case commontypes.FormatTarGZ:
+		return gzip.NewReader(r)
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
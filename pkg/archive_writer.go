@@ -0,0 +1,417 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	commontypes "github.com/criage-oss/criage-common/types"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+	"github.com/ulikunitz/xz"
+)
+
+// MetadataFileName файл метаданных пакета в корне архива
+const MetadataFileName = ".criage-metadata.json"
+
+// generatedFile файл, создаваемый criage и записываемый в корень архива перед файлами пакета
+type generatedFile struct {
+	name string
+	data []byte
+}
+
+// archiveEntry файл, директория или символическая ссылка, добавляемые в архив
+type archiveEntry struct {
+	// name путь внутри архива с разделителем /
+	name string
+	path string
+	info os.FileInfo
+	// link цель символической ссылки; пустая для остальных записей
+	link string
+}
+
+// archiveOptions параметры создания архива пакета
+type archiveOptions struct {
+	format ArchiveFormat
+	level  int
+	// includeFiles и excludeFiles шаблоны в стиле .gitignore относительно корня архива;
+	// пустой includeFiles выбирает все файлы
+	includeFiles []string
+	excludeFiles []string
+	// skipPaths пути, которые не попадают в архив независимо от шаблонов
+	skipPaths []string
+	// modTime ненулевое значение делает архив воспроизводимым: время изменения всех
+	// записей равно modTime, владелец и права нормализованы
+	modTime time.Time
+	// sbom параметры SBOM, встраиваемого рядом с метаданными; nil - без SBOM
+	sbom *sbomOptions
+	// provenance документ происхождения без выходных файлов, которые добавляются
+	// при создании архива; nil - без документа
+	provenance *Provenance
+}
+
+// createPackageArchive создает архив пакета с файлом метаданных и файлами sourceDir,
+// отобранными шаблонами include/exclude. Сам архив и пути skipPaths в него не
+// попадают. Архив записывается во временный файл и переименовывается после
+// успешного закрытия всех потоков сжатия
+func createPackageArchive(sourceDir, outputPath string, metadata *PackageMetadata, options archiveOptions) (err error) {
+	// Нулевой уровень означает уровень по умолчанию
+	if options.level == 0 {
+		options.level = CompressionNormal
+	}
+	if options.level < CompressionFast || options.level > CompressionBest {
+		return fmt.Errorf("unsupported compression level %d (expected %d-%d)", options.level, CompressionFast, CompressionBest)
+	}
+
+	metadataData, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	tempPath := outputPath + ".tmp"
+	skipPaths := append([]string{outputPath, tempPath}, options.skipPaths...)
+	entries, err := collectArchiveEntries(sourceDir, options.includeFiles, options.excludeFiles, skipPaths)
+	if err != nil {
+		return err
+	}
+
+	files := []generatedFile{{name: MetadataFileName, data: metadataData}}
+	var packed []packedFile
+	if options.sbom != nil || options.provenance != nil {
+		if packed, err = hashPackedFiles(entries); err != nil {
+			return fmt.Errorf("failed to hash files: %w", err)
+		}
+	}
+	if options.sbom != nil {
+		sbomData, err := generateSBOM(options.sbom, metadata, packed)
+		if err != nil {
+			return fmt.Errorf("failed to generate SBOM: %w", err)
+		}
+		files = append(files, generatedFile{name: SBOMFileName, data: sbomData})
+	}
+	if options.provenance != nil {
+		provenanceData, err := options.provenance.withOutputs(packed).marshal()
+		if err != nil {
+			return fmt.Errorf("failed to generate provenance: %w", err)
+		}
+		files = append(files, generatedFile{name: ProvenanceFileName, data: provenanceData})
+	}
+
+	if dir := filepath.Dir(outputPath); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	file, err := os.Create(tempPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tempPath)
+		}
+	}()
+
+	if options.format == commontypes.FormatZip {
+		err = writeZipArchive(file, options.level, files, entries, options.modTime)
+	} else {
+		err = writeTarArchive(file, options.format, options.level, files, entries, options.modTime)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, outputPath)
+}
+
+// collectArchiveEntries обходит sourceDir и возвращает файлы, выбранные шаблонами
+// include и не исключенные шаблонами exclude, отсортированные по пути в архиве.
+// Исключенная директория пропускается целиком. Директория попадает в архив, если
+// выбрана сама или содержит выбранные файлы. Символические ссылки попадают в архив
+// как ссылки, если указывают внутрь sourceDir; специальные файлы - ошибка
+func collectArchiveEntries(sourceDir string, includeFiles, excludeFiles, skipPaths []string) ([]archiveEntry, error) {
+	include, err := compilePatterns(includeFiles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid include_files: %w", err)
+	}
+	exclude, err := compilePatterns(excludeFiles)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude_files: %w", err)
+	}
+
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		skip[absPath] = true
+	}
+	absSource, err := filepath.Abs(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []archiveEntry
+	// dirs директории, пройденные фильтры exclude; included - выбраны ли они шаблонами include
+	dirs := make(map[string]archiveEntry)
+	included := map[string]bool{".": len(include) == 0}
+	added := make(map[string]bool)
+
+	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == sourceDir {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(relPath)
+
+		if skip[filepath.Join(absSource, relPath)] || exclude.match(name, info.IsDir(), false) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		entry := archiveEntry{name: name, path: path, info: info}
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if entry.link, err = packageLinkTarget(path, name); err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			return fmt.Errorf("%s: unsupported file type %s", name, info.Mode().Type())
+		}
+
+		parent := filepath.ToSlash(filepath.Dir(relPath))
+		selected := include.match(name, info.IsDir(), included[parent])
+		if info.IsDir() {
+			dirs[name] = entry
+			included[name] = selected
+		}
+		if !selected {
+			return nil
+		}
+
+		// Родительские директории выбранного файла добавляются в архив
+		for dir := parent; dir != "." && !added[dir]; dir = filepath.ToSlash(filepath.Dir(dir)) {
+			entries = append(entries, dirs[dir])
+			added[dir] = true
+		}
+		if !added[name] {
+			entries = append(entries, entry)
+			added[name] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect files: %w", err)
+	}
+
+	// Порядок обхода зависит от разделителя путей платформы, порядок в архиве - нет
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return entries, nil
+}
+
+// packageLinkTarget возвращает цель символической ссылки file с путем name в архиве.
+// Абсолютная цель или цель вне корня архива - ошибка
+func packageLinkTarget(file, name string) (string, error) {
+	target, err := os.Readlink(file)
+	if err != nil {
+		return "", err
+	}
+	link := filepath.ToSlash(target)
+	resolved := path.Join(path.Dir(name), link)
+	if filepath.IsAbs(target) || strings.HasPrefix(link, "/") || resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", fmt.Errorf("%s: symlink target %s is outside the package", name, target)
+	}
+	return target, nil
+}
+
+// normalizedMode возвращает права записи воспроизводимого архива: 0755 для
+// директорий и исполняемых файлов, 0644 для остальных
+func normalizedMode(info os.FileInfo) os.FileMode {
+	if info.IsDir() || info.Mode().Perm()&0111 != 0 {
+		return 0755
+	}
+	return 0644
+}
+
+// xzDictCaps размер словаря xz для уровней сжатия 1-9, как в пресетах xz(1)
+var xzDictCaps = [...]int{1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+// newCompressor оборачивает w в поток сжатия формата format с уровнем level (1-9)
+func newCompressor(w io.Writer, format ArchiveFormat, level int) (io.WriteCloser, error) {
+	switch format {
+	case commontypes.FormatTarZst:
+		// Один поток кодирования, чтобы результат не зависел от числа процессоров
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(1))
+	case commontypes.FormatTarLZ4:
+		// Уровень 1 - быстрый режим lz4, уровни 2-9 - режим высокого сжатия
+		lz4Level := lz4.Fast
+		if level > CompressionFast {
+			lz4Level = lz4.CompressionLevel(1 << (8 + level))
+		}
+		writer := lz4.NewWriter(w)
+		if err := writer.Apply(lz4.CompressionLevelOption(lz4Level)); err != nil {
+			return nil, err
+		}
+		return writer, nil
+	case commontypes.FormatTarXZ:
+		return xz.WriterConfig{DictCap: xzDictCaps[level-1]}.NewWriter(w)
+	case commontypes.FormatTarGZ:
+		return gzip.NewWriterLevel(w, level)
+	default:
+		return nil, fmt.Errorf("unsupported archive format: %s", format)
+	}
+}
+
+// writeTarArchive записывает сжатый tar-архив
+func writeTarArchive(w io.Writer, format ArchiveFormat, level int, files []generatedFile, entries []archiveEntry, modTime time.Time) error {
+	compressor, err := newCompressor(w, format, level)
+	if err != nil {
+		return err
+	}
+
+	tarWriter := tar.NewWriter(compressor)
+	if err := writeTarEntries(tarWriter, files, entries, modTime); err != nil {
+		tarWriter.Close()
+		compressor.Close()
+		return err
+	}
+	if err := tarWriter.Close(); err != nil {
+		compressor.Close()
+		return err
+	}
+	return compressor.Close()
+}
+
+// writeTarEntries добавляет созданные criage файлы и файлы пакета в tar-архив.
+// При ненулевом modTime заголовки нормализуются
+func writeTarEntries(tarWriter *tar.Writer, files []generatedFile, entries []archiveEntry, modTime time.Time) error {
+	for _, file := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{
+			Name:     file.name,
+			Mode:     0644,
+			Size:     int64(len(file.data)),
+			ModTime:  modTime,
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return err
+		}
+		if _, err := tarWriter.Write(file.data); err != nil {
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		header, err := tar.FileInfoHeader(entry.info, filepath.ToSlash(entry.link))
+		if err != nil {
+			return err
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		}
+		if !modTime.IsZero() {
+			header.Uid, header.Gid = 0, 0
+			header.Uname, header.Gname = "", ""
+			header.Mode = int64(normalizedMode(entry.info))
+			header.ModTime = modTime
+			header.AccessTime, header.ChangeTime = time.Time{}, time.Time{}
+		}
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.info.IsDir() || entry.link != "" {
+			continue
+		}
+		if err := copyFileTo(tarWriter, entry.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeZipArchive записывает ZIP-архив. При ненулевом modTime время и права
+// записей нормализуются
+func writeZipArchive(w io.Writer, level int, files []generatedFile, entries []archiveEntry, modTime time.Time) error {
+	zipWriter := zip.NewWriter(w)
+	zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+
+	var writer io.Writer
+	var err error
+	for i := 0; err == nil && i < len(files); i++ {
+		writer, err = zipWriter.CreateHeader(&zip.FileHeader{Name: files[i].name, Method: zip.Deflate, Modified: modTime})
+		if err == nil {
+			_, err = writer.Write(files[i].data)
+		}
+	}
+
+	for i := 0; err == nil && i < len(entries); i++ {
+		entry := entries[i]
+		var header *zip.FileHeader
+		header, err = zip.FileInfoHeader(entry.info)
+		if err != nil {
+			break
+		}
+		header.Name = entry.name
+		if entry.info.IsDir() {
+			header.Name += "/"
+		} else {
+			header.Method = zip.Deflate
+		}
+		if !modTime.IsZero() {
+			header.Modified = modTime
+			header.SetMode(normalizedMode(entry.info) | entry.info.Mode().Type())
+		}
+		writer, err = zipWriter.CreateHeader(header)
+		switch {
+		case err != nil || entry.info.IsDir():
+		case entry.link != "":
+			// Содержимое записи ссылки в ZIP - путь к цели
+			_, err = io.WriteString(writer, filepath.ToSlash(entry.link))
+		default:
+			err = copyFileTo(writer, entry.path)
+		}
+	}
+
+	if closeErr := zipWriter.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyFileTo копирует содержимое файла в w
+func copyFileTo(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
@@ -0,0 +1,751 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BuildTargetConfig целевая платформа сборки с собственными переменными окружения
+type BuildTargetConfig struct {
+	OS   string            `json:"os"`
+	Arch string            `json:"arch"`
+	Env  map[string]string `json:"env,omitempty"`
+}
+
+// String возвращает цель в виде os/arch
+func (t BuildTargetConfig) String() string {
+	return t.OS + "/" + t.Arch
+}
+
+// BuildConfig конфигурация сборки из build.json. В отличие от BuildManifest
+// содержит переменные окружения сборки и целей; ключи записываются в snake_case,
+// ключи BuildManifest в camelCase читаются для совместимости
+type BuildConfig struct {
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	BuildScript  string            `json:"build_script,omitempty"`
+	BuildEnv     map[string]string `json:"build_env,omitempty"`
+	OutputDir    string            `json:"output_dir,omitempty"`
+	StagingDir   string            `json:"staging_dir,omitempty"`
+	IncludeFiles []string          `json:"include_files,omitempty"`
+	ExcludeFiles []string          `json:"exclude_files,omitempty"`
+	// CacheInputs шаблоны файлов проекта, от которых зависит сборка; пустой список -
+	// все файлы проекта
+	CacheInputs []string            `json:"cache_inputs,omitempty"`
+	Compression CompressionConfig   `json:"compression"`
+	Targets     []BuildTargetConfig `json:"targets,omitempty"`
+}
+
+// UnmarshalJSON читает build.json в snake_case и в формате BuildManifest
+func (c *BuildConfig) UnmarshalJSON(data []byte) error {
+	var manifest BuildManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+
+	*c = BuildConfig{
+		Name:         manifest.Name,
+		Version:      manifest.Version,
+		BuildScript:  manifest.BuildScript,
+		BuildEnv:     manifest.Environment,
+		OutputDir:    manifest.OutputDir,
+		IncludeFiles: manifest.IncludeFiles,
+		ExcludeFiles: manifest.ExcludeFiles,
+		Compression:  manifest.Compression,
+	}
+	for _, target := range manifest.Targets {
+		c.Targets = append(c.Targets, BuildTargetConfig{OS: target.OS, Arch: target.Arch})
+	}
+
+	// Ключи в snake_case имеют приоритет; build_env дополняет environment
+	type plain BuildConfig
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// targetEnv возвращает переменные окружения сборки цели: build_env, дополненные env цели
+func (c *BuildConfig) targetEnv(target BuildTargetConfig) map[string]string {
+	env := make(map[string]string, len(c.BuildEnv)+len(target.Env))
+	for key, value := range c.BuildEnv {
+		env[key] = value
+	}
+	for key, value := range target.Env {
+		env[key] = value
+	}
+	return env
+}
+
+// archiveRoot возвращает директорию, из которой собирается архив: staging_dir,
+// если задана, иначе output_dir; без них - директория проекта
+func (c *BuildConfig) archiveRoot() string {
+	switch {
+	case c.StagingDir != "":
+		return c.StagingDir
+	case c.OutputDir != "":
+		return c.OutputDir
+	default:
+		return "."
+	}
+}
+
+// manifestFor возвращает манифест сборки для встраивания в архив цели
+func (c *BuildConfig) manifestFor(target BuildTargetConfig) *BuildManifest {
+	return &BuildManifest{
+		Name:         c.Name,
+		Version:      c.Version,
+		BuildScript:  c.BuildScript,
+		OutputDir:    c.OutputDir,
+		IncludeFiles: c.IncludeFiles,
+		ExcludeFiles: c.ExcludeFiles,
+		Compression:  c.Compression,
+		Targets:      []BuildTarget{{OS: target.OS, Arch: target.Arch}},
+		Environment:  c.targetEnv(target),
+	}
+}
+
+// BuildHooks хуки сборки из секции hooks манифеста criage.yaml. Хуки установки
+// описаны в PackageHooks; хуки сборки читаются отдельно в snake_case и camelCase
+type BuildHooks struct {
+	PreBuild  []string
+	PostBuild []string
+}
+
+// UnmarshalYAML читает pre_build/post_build (или preBuild/postBuild)
+func (h *BuildHooks) UnmarshalYAML(value *yaml.Node) error {
+	var hooks struct {
+		PreBuild       []string `yaml:"pre_build"`
+		PostBuild      []string `yaml:"post_build"`
+		PreBuildCamel  []string `yaml:"preBuild"`
+		PostBuildCamel []string `yaml:"postBuild"`
+	}
+	if err := value.Decode(&hooks); err != nil {
+		return err
+	}
+
+	h.PreBuild = append(hooks.PreBuild, hooks.PreBuildCamel...)
+	h.PostBuild = append(hooks.PostBuild, hooks.PostBuildCamel...)
+	return nil
+}
+
+// BuildOptions параметры сборки пакета
+type BuildOptions struct {
+	// Output путь к архиву; при сборке нескольких целей к имени добавляется os-arch.
+	// Пустое значение - <name>-<version>-<os>-<arch>.criage
+	Output           string
+	Format           string
+	CompressionLevel int
+	// Targets фильтры целей вида os/arch или os; пустой список - все цели build.json
+	Targets []string
+	// CleanEnv запускает скрипт сборки только с переменными из cleanEnvAllowList и KeepEnv
+	// вместо полного окружения процесса
+	CleanEnv bool
+	// KeepEnv дополнительные переменные окружения, сохраняемые в режиме CleanEnv
+	KeepEnv []string
+	// Reproducible создает воспроизводимые архивы: записи отсортированы, владелец,
+	// права и время нормализованы, время берется из SOURCE_DATE_EPOCH. Включается
+	// также, если SOURCE_DATE_EPOCH задана
+	Reproducible bool
+	// VerifyReproducible собирает каждую цель дважды и сравнивает SHA-256 архивов
+	VerifyReproducible bool
+	// SBOMFormat формат SBOM, встраиваемого в архив: spdx, cyclonedx или none (пустое значение)
+	SBOMFormat string
+	// NoCache выполняет полную сборку, не используя архивы из кеша сборок
+	NoCache bool
+}
+
+// cleanEnvAllowList переменные окружения процесса, доступные сборке в режиме CleanEnv
+var cleanEnvAllowList = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "LANG", "LC_ALL", "TERM", "TZ",
+	"TMPDIR", "TEMP", "TMP", "SOURCE_DATE_EPOCH",
+	// Без них не работают процессы Windows
+	"SYSTEMROOT", "COMSPEC", "PATHEXT", "WINDIR",
+}
+
+// injectedBuildEnv переменные, которые criage передает скриптам сборки
+var injectedBuildEnv = []string{
+	"CRIAGE_PACKAGE_NAME", "CRIAGE_VERSION", "CRIAGE_TARGET_OS", "CRIAGE_TARGET_ARCH",
+	"CRIAGE_OUTPUT_DIR", "CRIAGE_STAGING_DIR",
+}
+
+// BuildPackage собирает пакет для каждой цели из build.json и возвращает пути к архивам.
+// Скрипт сборки выполняется отдельно для каждой цели с ее переменными окружения.
+// При ошибке архивы уже собранных целей удаляются
+func (pm *PackageManager) BuildPackage(ctx context.Context, options BuildOptions) ([]string, error) {
+	archives, err := pm.buildPackage(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	paths := make([]string, len(archives))
+	for i, archive := range archives {
+		paths[i] = archive.path
+	}
+	return paths, nil
+}
+
+// builtArchive архив, собранный для цели target
+type builtArchive struct {
+	path   string
+	target BuildTargetConfig
+}
+
+// buildPackage выполняет BuildPackage и возвращает архивы вместе с их целями
+func (pm *PackageManager) buildPackage(ctx context.Context, options BuildOptions) ([]builtArchive, error) {
+	fmt.Println("Сборка пакета...")
+
+	// Загружаем локальную конфигурацию
+	manifest, err := pm.configManager.LoadLocalConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local config: %w", err)
+	}
+
+	// Проверяем сборочную конфигурацию
+	buildConfig, err := pm.configManager.LoadBuildConfig(".")
+	if err != nil {
+		// Создаем базовую конфигурацию сборки
+		buildConfig = &BuildConfig{
+			Name:         manifest.Name,
+			Version:      manifest.Version,
+			BuildScript:  "make",
+			OutputDir:    ".",
+			IncludeFiles: manifest.Files,
+			ExcludeFiles: manifest.Exclude,
+			Compression: CompressionConfig{
+				Format: options.Format,
+				Level:  options.CompressionLevel,
+			},
+		}
+	}
+	if len(buildConfig.Targets) == 0 {
+		buildConfig.Targets = []BuildTargetConfig{{OS: runtime.GOOS, Arch: runtime.GOARCH}}
+	}
+
+	targets, err := selectBuildTargets(buildConfig.Targets, options.Targets)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateSBOMFormat(options.SBOMFormat); err != nil {
+		return nil, err
+	}
+
+	hooks, err := pm.configManager.LoadBuildHooks(".")
+	if err != nil {
+		return nil, err
+	}
+
+	outputs := make([]string, len(targets))
+	for i, target := range targets {
+		outputs[i] = targetOutputPath(options.Output, manifest.Name, manifest.Version, target, len(targets) > 1)
+	}
+	// В архив не попадают архивы всех целей build.json, в том числе оставшиеся
+	// от предыдущих сборок
+	skipPaths := append([]string(nil), outputs...)
+	for _, target := range buildConfig.Targets {
+		skipPaths = append(skipPaths,
+			targetOutputPath("", manifest.Name, manifest.Version, target, true),
+			targetOutputPath(options.Output, manifest.Name, manifest.Version, target, true))
+	}
+
+	if buildConfig.StagingDir != "" {
+		if err := checkStagingDir(buildConfig.StagingDir); err != nil {
+			return nil, err
+		}
+	}
+
+	// Скрипт сборки получает путь к выходной директории в CRIAGE_OUTPUT_DIR
+	if buildConfig.OutputDir != "" {
+		if err := os.MkdirAll(buildConfig.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create output directory: %w", err)
+		}
+	}
+
+	// Происхождение фиксируется до запуска скриптов: сборка может изменить рабочую копию
+	inputs, err := provenanceInputDigests(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash build inputs: %w", err)
+	}
+
+	bc := &buildContext{
+		config:   buildConfig,
+		manifest: manifest,
+		hooks:    hooks,
+		skip:     skipPaths,
+		sbom:     pm.newSBOMOptions(options.SBOMFormat, manifest),
+		options:  options,
+		source:   detectSource(ctx, "."),
+		inputs:   inputs,
+	}
+	for _, target := range targets {
+		bc.targets = append(bc.targets, BuildTarget{OS: target.OS, Arch: target.Arch})
+	}
+
+	// Хуки сборки выполняются один раз за сборку, в том числе когда все цели взяты из
+	// кеша, с окружением без переменных цели. pre_build выполняется до вычисления ключей
+	// кеша, чтобы сгенерированные им файлы входили в ключ
+	hookEnv, err := buildEnvironment(os.Environ(), buildConfig, manifest, BuildTargetConfig{}, options)
+	if err != nil {
+		return nil, err
+	}
+	if err := pm.executeBuildHooks(ctx, "pre_build", hooks.PreBuild, hookEnv); err != nil {
+		return nil, err
+	}
+
+	// При ошибке удаляем архивы уже собранных целей, чтобы не оставлять неполный набор
+	built, succeeded := 0, false
+	defer func() {
+		if !succeeded {
+			for _, output := range outputs[:built] {
+				os.Remove(output)
+			}
+		}
+	}()
+
+	for i, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fmt.Printf("Цель сборки: %s\n", target)
+
+		env, err := buildEnvironment(os.Environ(), buildConfig, manifest, target, options)
+		if err != nil {
+			return nil, err
+		}
+
+		// В воспроизводимом режиме время записей архива и метаданных берется из
+		// SOURCE_DATE_EPOCH; скрипт сборки получает то же значение
+		var modTime time.Time
+		if options.Reproducible || options.VerifyReproducible || envHas(env, "SOURCE_DATE_EPOCH") {
+			if modTime, err = sourceDateEpoch(env); err != nil {
+				return nil, err
+			}
+			env = mergeEnv(env, map[string]string{"SOURCE_DATE_EPOCH": strconv.FormatInt(modTime.Unix(), 10)})
+		}
+
+		// Проверка воспроизводимости требует настоящей сборки, поэтому кеш не используется
+		var cachePath string
+		if !options.NoCache && !options.VerifyReproducible {
+			key, err := bc.cacheKey(target, env, modTime)
+			if err != nil {
+				return nil, err
+			}
+			cachePath = pm.buildCachePath(manifest.Name, manifest.Version, key)
+		}
+
+		cached := false
+		if cachePath != "" {
+			if cached, err = restoreCachedBuild(cachePath, outputs[i]); err != nil {
+				return nil, err
+			}
+		}
+		if cached {
+			built++
+			fmt.Printf("Входные данные не изменились, используется кешированная сборка: %s\n", outputs[i])
+		} else {
+			if err := pm.buildTarget(ctx, bc, target, env, modTime, outputs[i]); err != nil {
+				return nil, err
+			}
+
+			built++
+			fmt.Printf("Пакет собран с встроенными метаданными: %s\n", outputs[i])
+
+			// Ошибка записи в кеш не прерывает сборку
+			if cachePath != "" {
+				if err := copyFileAtomic(outputs[i], cachePath); err != nil {
+					fmt.Fprintf(os.Stderr, "Предупреждение: не удалось сохранить сборку в кеш: %v\n", err)
+				} else if err := pruneBuildCache(pm.configManager.GetConfig().CachePath); err != nil {
+					fmt.Fprintf(os.Stderr, "Предупреждение: не удалось очистить кеш сборок: %v\n", err)
+				}
+			}
+		}
+
+		if options.VerifyReproducible {
+			if err := pm.verifyReproducible(ctx, bc, target, env, modTime, outputs[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Хуки post_build получают пути ко всем архивам в CRIAGE_ARCHIVES через
+	// разделитель списка путей, а при одной цели - путь к архиву в CRIAGE_ARCHIVE.
+	// При ошибке архивы удаляются
+	archivePaths := make([]string, len(outputs))
+	for i, output := range outputs {
+		if archivePaths[i], err = filepath.Abs(output); err != nil {
+			return nil, err
+		}
+	}
+	postVars := map[string]string{"CRIAGE_ARCHIVES": strings.Join(archivePaths, string(os.PathListSeparator))}
+	if len(archivePaths) == 1 {
+		postVars["CRIAGE_ARCHIVE"] = archivePaths[0]
+	}
+	if err := pm.executeBuildHooks(ctx, "post_build", hooks.PostBuild, mergeEnv(hookEnv, postVars)); err != nil {
+		return nil, err
+	}
+
+	succeeded = true
+	archives := make([]builtArchive, len(targets))
+	for i, target := range targets {
+		archives[i] = builtArchive{path: outputs[i], target: target}
+	}
+	return archives, nil
+}
+
+// buildContext общие для всех целей параметры сборки
+type buildContext struct {
+	config   *BuildConfig
+	manifest *PackageManifest
+	hooks    *BuildHooks
+	// skip архивы целей, которые не попадают в собираемый архив
+	skip    []string
+	sbom    *sbomOptions
+	options BuildOptions
+	// source и inputs состояние исходного кода и конфигурации до запуска скриптов
+	source ProvenanceSource
+	inputs []ProvenanceDigest
+	// targets все собираемые цели
+	targets []BuildTarget
+	// cacheFiles хеши входных файлов для ключа кеша сборок
+	cacheFiles []buildCacheInput
+}
+
+// buildTarget выполняет скрипт сборки цели и создает архив output
+// из корня archiveRoot. Директория staging_dir очищается перед сборкой каждой цели.
+// Нулевой modTime - обычная сборка, иначе архив воспроизводимый
+func (pm *PackageManager) buildTarget(ctx context.Context, bc *buildContext, target BuildTargetConfig, env []string, modTime time.Time, output string) error {
+	startedAt := time.Now().UTC()
+	if bc.config.StagingDir != "" {
+		if err := os.RemoveAll(bc.config.StagingDir); err != nil {
+			return fmt.Errorf("failed to clean staging directory: %w", err)
+		}
+		if err := os.MkdirAll(bc.config.StagingDir, 0755); err != nil {
+			return fmt.Errorf("failed to create staging directory: %w", err)
+		}
+	}
+
+	// Выполняем скрипт сборки
+	if bc.config.BuildScript != "" {
+		fmt.Printf("Выполнение скрипта сборки: %s\n", bc.config.BuildScript)
+		if err := pm.executeBuildScript(ctx, bc.config.BuildScript, env); err != nil {
+			return fmt.Errorf("build script failed for %s: %w", target, err)
+		}
+	}
+
+	createdAt := modTime
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	} else {
+		startedAt = modTime
+	}
+
+	// Создаем структуру метаданных для встраивания в архив
+	metadata := &PackageMetadata{
+		PackageManifest: bc.manifest,
+		BuildManifest:   bc.config.manifestFor(target),
+		CompressionType: bc.options.Format,
+		CreatedAt:       createdAt,
+		CreatedBy:       "criage",
+	}
+	metadata.BuildManifest.Compression = CompressionConfig{Format: bc.options.Format, Level: bc.options.CompressionLevel}
+
+	// Создаем архив с встроенными метаданными
+	root := bc.config.archiveRoot()
+	if _, err := os.Stat(root); err != nil {
+		return fmt.Errorf("build output not found for %s: %w", target, err)
+	}
+	if err := createPackageArchive(root, output, metadata, archiveOptions{
+		format:       ArchiveFormat(bc.options.Format),
+		level:        bc.options.CompressionLevel,
+		includeFiles: bc.config.IncludeFiles,
+		excludeFiles: bc.config.ExcludeFiles,
+		skipPaths:    bc.skip,
+		modTime:      modTime,
+		sbom:         bc.sbom,
+		provenance: &Provenance{
+			BuildType:    ProvenanceBuildType,
+			Builder:      ProvenanceBuilder{ID: "criage", Version: BuildVersion},
+			Source:       bc.source,
+			BuildScript:  bc.config.BuildScript,
+			BuildEnv:     bc.config.targetEnv(target),
+			Target:       BuildTarget{OS: target.OS, Arch: target.Arch},
+			Targets:      bc.targets,
+			Reproducible: !modTime.IsZero(),
+			StartedAt:    startedAt,
+			FinishedAt:   createdAt,
+			Inputs:       bc.inputs,
+		},
+	}); err != nil {
+		return fmt.Errorf("failed to create archive for %s: %w", target, err)
+	}
+	return nil
+}
+
+// checkStagingDir проверяет, что staging_dir находится внутри директории проекта
+// и не совпадает с ней: содержимое staging_dir удаляется перед каждой сборкой
+func checkStagingDir(dir string) error {
+	projectDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	stagingDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(projectDir, stagingDir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("staging_dir must be a subdirectory of the project: %s", dir)
+	}
+	return nil
+}
+
+// verifyReproducible повторяет сборку цели во временный архив и сравнивает его
+// SHA-256 с архивом output
+func (pm *PackageManager) verifyReproducible(ctx context.Context, bc *buildContext, target BuildTargetConfig, env []string, modTime time.Time, output string) error {
+	fmt.Printf("Повторная сборка %s для проверки воспроизводимости\n", target)
+
+	workDir, err := pm.newWorkDir("build_verify")
+	if err != nil {
+		return fmt.Errorf("failed to create work directory: %w", err)
+	}
+	defer pm.removeWorkDir(workDir)
+
+	rebuilt := filepath.Join(workDir, filepath.Base(output))
+	if err := pm.buildTarget(ctx, bc, target, env, modTime, rebuilt); err != nil {
+		return err
+	}
+
+	expected, err := fileSHA256(output)
+	if err != nil {
+		return err
+	}
+	actual, err := fileSHA256(rebuilt)
+	if err != nil {
+		return err
+	}
+	if expected != actual {
+		return fmt.Errorf("build is not reproducible for %s: sha256 %s differs from %s on rebuild", target, expected, actual)
+	}
+
+	fmt.Printf("Сборка воспроизводима: %s sha256:%s\n", output, expected)
+	return nil
+}
+
+// defaultSourceDateEpoch время записей воспроизводимого архива без SOURCE_DATE_EPOCH:
+// 1980-01-01 00:00:00 UTC, минимальное время, представимое в ZIP
+const defaultSourceDateEpoch = 315532800
+
+// sourceDateEpoch возвращает время из SOURCE_DATE_EPOCH окружения env
+func sourceDateEpoch(env []string) (time.Time, error) {
+	value, ok := lookupEnv(env, "SOURCE_DATE_EPOCH")
+	if !ok || value == "" {
+		return time.Unix(defaultSourceDateEpoch, 0).UTC(), nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q (expected unix timestamp)", value)
+	}
+	if seconds < defaultSourceDateEpoch {
+		seconds = defaultSourceDateEpoch
+	}
+	return time.Unix(seconds, 0).UTC(), nil
+}
+
+// fileSHA256 возвращает SHA-256 содержимого файла в hex
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// executeBuildHooks выполняет команды хука сборки stage по порядку до первой ошибки
+func (pm *PackageManager) executeBuildHooks(ctx context.Context, stage string, commands []string, env []string) error {
+	for _, command := range commands {
+		fmt.Printf("Хук %s: %s\n", stage, command)
+		if err := pm.executeBuildScript(ctx, command, env); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("%s hook failed: %s: %w", stage, command, err)
+		}
+	}
+	return nil
+}
+
+// selectBuildTargets отбирает цели по фильтрам вида os/arch или os; "*" соответствует любому значению
+func selectBuildTargets(targets []BuildTargetConfig, filters []string) ([]BuildTargetConfig, error) {
+	if len(filters) == 0 {
+		return targets, nil
+	}
+
+	var selected []BuildTargetConfig
+	for _, filter := range filters {
+		osName, arch, _ := strings.Cut(strings.TrimSpace(filter), "/")
+		if osName == "" || strings.Contains(arch, "/") {
+			return nil, fmt.Errorf("invalid target %q (expected os/arch or os)", filter)
+		}
+
+		matched := false
+		for _, target := range targets {
+			if (osName == "*" || osName == target.OS) && (arch == "" || arch == "*" || arch == target.Arch) {
+				matched = true
+				if !containsTarget(selected, target) {
+					selected = append(selected, target)
+				}
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("no build targets match %s (available: %s)", filter, targetList(targets))
+		}
+	}
+
+	return selected, nil
+}
+
+// containsTarget проверяет, есть ли цель с той же платформой в списке
+func containsTarget(targets []BuildTargetConfig, target BuildTargetConfig) bool {
+	for _, t := range targets {
+		if t.OS == target.OS && t.Arch == target.Arch {
+			return true
+		}
+	}
+	return false
+}
+
+// targetList возвращает список целей через запятую
+func targetList(targets []BuildTargetConfig) string {
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// archiveExtensions расширения архивов, перед которыми вставляется суффикс цели
+var archiveExtensions = []string{".tar.zst", ".tar.lz4", ".tar.xz", ".tar.gz", ".zip", ".criage"}
+
+// targetOutputPath возвращает путь к архиву цели. Явно заданный путь используется
+// как есть при сборке одной цели; при нескольких целях к имени добавляется -os-arch
+func targetOutputPath(output, name, version string, target BuildTargetConfig, multiple bool) string {
+	suffix := "-" + target.OS + "-" + target.Arch
+	if output == "" {
+		return name + "-" + version + suffix + ".criage"
+	}
+	if !multiple {
+		return output
+	}
+
+	ext := filepath.Ext(output)
+	lower := strings.ToLower(output)
+	for _, archiveExt := range archiveExtensions {
+		if strings.HasSuffix(lower, archiveExt) {
+			ext = output[len(output)-len(archiveExt):]
+			break
+		}
+	}
+	return strings.TrimSuffix(output, ext) + suffix + ext
+}
+
+// buildEnvironment возвращает окружение скрипта сборки цели: окружение процесса
+// (в режиме CleanEnv только разрешенные переменные), build_env, env цели и
+// переменные CRIAGE_*, которые нельзя переопределить
+func buildEnvironment(environ []string, buildConfig *BuildConfig, manifest *PackageManifest, target BuildTargetConfig, options BuildOptions) ([]string, error) {
+	outputDir, err := filepath.Abs(buildConfig.OutputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve output directory: %w", err)
+	}
+
+	if options.CleanEnv {
+		environ = filterEnv(environ, append(append([]string(nil), cleanEnvAllowList...), options.KeepEnv...))
+	}
+
+	vars := map[string]string{
+		"CRIAGE_PACKAGE_NAME": manifest.Name,
+		"CRIAGE_VERSION":      manifest.Version,
+		"CRIAGE_TARGET_OS":    target.OS,
+		"CRIAGE_TARGET_ARCH":  target.Arch,
+		"CRIAGE_OUTPUT_DIR":   outputDir,
+	}
+	if buildConfig.StagingDir != "" {
+		if vars["CRIAGE_STAGING_DIR"], err = filepath.Abs(buildConfig.StagingDir); err != nil {
+			return nil, fmt.Errorf("failed to resolve staging directory: %w", err)
+		}
+	}
+
+	env := mergeEnv(environ, buildConfig.targetEnv(target))
+	return mergeEnv(env, vars), nil
+}
+
+// lookupEnv возвращает значение переменной name из окружения env
+func lookupEnv(env []string, name string) (string, bool) {
+	for i := len(env) - 1; i >= 0; i-- {
+		if key, value, _ := strings.Cut(env[i], "="); key == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// envHas проверяет, задана ли переменная name в окружении env
+func envHas(env []string, name string) bool {
+	_, ok := lookupEnv(env, name)
+	return ok
+}
+
+// filterEnv оставляет в окружении только переменные из списка names
+func filterEnv(environ []string, names []string) []string {
+	allowed := make(map[string]bool, len(names))
+	for _, name := range names {
+		allowed[name] = true
+	}
+
+	var env []string
+	for _, entry := range environ {
+		key, _, _ := strings.Cut(entry, "=")
+		if allowed[key] {
+			env = append(env, entry)
+		}
+	}
+	return env
+}
+
+// mergeEnv дополняет окружение base переменными vars, заменяя совпадающие.
+// Новые переменные добавляются в порядке сортировки имен
+func mergeEnv(base []string, vars map[string]string) []string {
+	env := make([]string, 0, len(base)+len(vars))
+	for _, entry := range base {
+		key, _, _ := strings.Cut(entry, "=")
+		if _, overridden := vars[key]; !overridden {
+			env = append(env, entry)
+		}
+	}
+
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = append(env, key+"="+vars[key])
+	}
+	return env
+}
@@ -0,0 +1,254 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// buildCacheDir поддиректория кеша пакета с архивами предыдущих сборок
+const buildCacheDir = "builds"
+
+var (
+	// buildCacheMaxAge срок, после которого неиспользуемый архив удаляется из кеша сборок
+	buildCacheMaxAge = 30 * 24 * time.Hour
+	// buildCacheMaxSize общий размер кеша сборок; при превышении удаляются архивы,
+	// которые дольше всего не использовались
+	buildCacheMaxSize int64 = 2 << 30
+)
+
+// buildCacheInput файл проекта, от которого зависит результат сборки
+type buildCacheInput struct {
+	Name   string      `json:"name"`
+	Mode   os.FileMode `json:"mode"`
+	SHA256 string      `json:"sha256"`
+}
+
+// buildCacheKey все входные данные сборки цели. SHA-256 его JSON-представления -
+// ключ архива в кеше сборок
+type buildCacheKey struct {
+	Builder  string            `json:"builder"`
+	Manifest *PackageManifest  `json:"manifest"`
+	Config   *BuildConfig      `json:"config"`
+	Hooks    *BuildHooks       `json:"hooks"`
+	Target   BuildTargetConfig `json:"target"`
+	// Env переменные build_env и цели, переменные CRIAGE_*, которые получает скрипт,
+	// и в режиме CleanEnv - переменные из списка разрешенных
+	Env    map[string]string `json:"env"`
+	Format string            `json:"format"`
+	Level  int               `json:"level"`
+	SBOM   string            `json:"sbom"`
+	// ModTime время записей воспроизводимого архива; 0 - обычная сборка
+	ModTime int64             `json:"mod_time"`
+	Source  ProvenanceSource  `json:"source"`
+	Files   []buildCacheInput `json:"files"`
+}
+
+// cacheInputs возвращает хеши файлов проекта, от которых зависит сборка: файлов,
+// выбранных cache_inputs (без них - всех файлов проекта), кроме директорий output_dir
+// и staging_dir, архивов целей и .git. Если архив собирается из директории проекта,
+// exclude_files применяются, а файлы пакета (include_files, без них - files манифеста)
+// хешируются всегда, даже если cache_inputs их не выбирает: иначе они заданы
+// относительно другого корня. Хеши вычисляются один раз для всех целей
+func (bc *buildContext) cacheInputs() ([]buildCacheInput, error) {
+	if bc.cacheFiles != nil {
+		return bc.cacheFiles, nil
+	}
+
+	skipPaths := append([]string{".git"}, bc.skip...)
+	for _, dir := range []string{bc.config.OutputDir, bc.config.StagingDir} {
+		if dir != "" && filepath.Clean(dir) != "." {
+			skipPaths = append(skipPaths, dir)
+		}
+	}
+
+	projectRoot := filepath.Clean(bc.config.archiveRoot()) == "."
+	var excludeFiles []string
+	if projectRoot {
+		excludeFiles = bc.config.ExcludeFiles
+	}
+
+	entries, err := collectArchiveEntries(".", bc.config.CacheInputs, excludeFiles, skipPaths)
+	if err != nil {
+		return nil, err
+	}
+	if projectRoot && len(bc.config.CacheInputs) > 0 {
+		includeFiles := bc.config.IncludeFiles
+		if len(includeFiles) == 0 {
+			includeFiles = bc.manifest.Files
+		}
+		packageEntries, err := collectArchiveEntries(".", includeFiles, excludeFiles, skipPaths)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, packageEntries...)
+	}
+
+	files := []buildCacheInput{}
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.info.IsDir() || seen[entry.name] {
+			continue
+		}
+		seen[entry.name] = true
+		var digest string
+		if entry.link != "" {
+			sum := sha256.Sum256([]byte(entry.link))
+			digest = hex.EncodeToString(sum[:])
+		} else if digest, err = fileSHA256(entry.path); err != nil {
+			return nil, err
+		}
+		files = append(files, buildCacheInput{Name: entry.name, Mode: normalizedMode(entry.info), SHA256: digest})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	bc.cacheFiles = files
+	return files, nil
+}
+
+// cacheKey возвращает ключ кеша сборки цели target с окружением скрипта сборки env
+func (bc *buildContext) cacheKey(target BuildTargetConfig, env []string, modTime time.Time) (string, error) {
+	files, err := bc.cacheInputs()
+	if err != nil {
+		return "", fmt.Errorf("failed to hash build inputs: %w", err)
+	}
+
+	// Остальные переменные процесса меняются от запуска к запуску (идентификаторы
+	// заданий CI, терминал, сессия) и в ключ не входят
+	names := append([]string(nil), injectedBuildEnv...)
+	for name := range bc.config.targetEnv(target) {
+		names = append(names, name)
+	}
+	if bc.options.CleanEnv {
+		names = append(append(names, cleanEnvAllowList...), bc.options.KeepEnv...)
+	}
+
+	vars := make(map[string]string, len(names))
+	for _, entry := range filterEnv(env, names) {
+		name, value, _ := strings.Cut(entry, "=")
+		vars[name] = value
+	}
+
+	key := buildCacheKey{
+		Builder:  sbomTool(),
+		Manifest: bc.manifest,
+		Config:   bc.config,
+		Hooks:    bc.hooks,
+		Target:   target,
+		Env:      vars,
+		Format:   bc.options.Format,
+		Level:    bc.options.CompressionLevel,
+		SBOM:     bc.options.SBOMFormat,
+		Source:   bc.source,
+		Files:    files,
+	}
+	if !modTime.IsZero() {
+		key.ModTime = modTime.Unix()
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// buildCachePath возвращает путь к архиву сборки с ключом key в кеше
+func (pm *PackageManager) buildCachePath(packageName, version, key string) string {
+	return filepath.Join(pm.configManager.GetCachePath(packageName, version), buildCacheDir, key)
+}
+
+// restoreCachedBuild копирует архив из кеша в output и сообщает, был ли он в кеше.
+// Время изменения архива в кеше обновляется: по нему выбираются архивы для удаления
+func restoreCachedBuild(cachePath, output string) (bool, error) {
+	if _, err := os.Stat(cachePath); os.IsNotExist(err) {
+		return false, nil
+	}
+	if err := copyFileAtomic(cachePath, output); err != nil {
+		return false, fmt.Errorf("failed to restore cached build: %w", err)
+	}
+	now := time.Now()
+	os.Chtimes(cachePath, now, now)
+	return true, nil
+}
+
+// pruneBuildCache удаляет из кеша сборок всех пакетов в cacheRoot архивы, которые не
+// использовались дольше buildCacheMaxAge, и затем самые давно использованные архивы,
+// пока общий размер больше buildCacheMaxSize
+func pruneBuildCache(cacheRoot string) error {
+	paths, err := filepath.Glob(filepath.Join(cacheRoot, "*", "*", buildCacheDir, "*"))
+	if err != nil {
+		return err
+	}
+
+	type cachedBuild struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var builds []cachedBuild
+	var total int64
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if time.Since(info.ModTime()) > buildCacheMaxAge {
+			os.Remove(path)
+			continue
+		}
+		builds = append(builds, cachedBuild{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(builds, func(i, j int) bool { return builds[i].modTime.Before(builds[j].modTime) })
+	for _, build := range builds {
+		if total <= buildCacheMaxSize {
+			break
+		}
+		if err := os.Remove(build.path); err != nil {
+			return err
+		}
+		total -= build.size
+	}
+	return nil
+}
+
+// copyFileAtomic копирует файл src в dst через временный файл, чтобы прерванное
+// копирование не оставило неполный dst
+func copyFileAtomic(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmpPath := dst + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, dst)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
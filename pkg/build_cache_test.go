@@ -0,0 +1,175 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestBuildCache проверяет, что сборка с неизменными входными данными берется из
+// кеша, а изменение файла проекта, переменной из списка разрешенных в режиме CleanEnv
+// или NoCache запускают скрипт сборки
+func TestBuildCache(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(project)
+
+	runs := filepath.Join(dir, "runs.log")
+	files := map[string]string{
+		LocalConfigName: "name: app\nversion: 1.0.0\n",
+		BuildConfigName: `{"name": "app", "version": "1.0.0", "staging_dir": "stage",
+			"build_script": "echo run >> \"$RUNS\" && cp src.txt \"$CRIAGE_STAGING_DIR/\"",
+			"build_env": {"RUNS": "` + filepath.ToSlash(runs) + `"},
+			"targets": [{"os": "linux", "arch": "amd64"}]}`,
+		"src.txt": "v1",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pm := newTestPackageManager(dir)
+	options := BuildOptions{Format: "tar.zst", CompressionLevel: 3}
+	build := func(wantRuns int) string {
+		t.Helper()
+		outputs, err := pm.BuildPackage(context.Background(), options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(runs)
+		if got := strings.Count(string(data), "run"); got != wantRuns {
+			t.Fatalf("build script ran %d times, want %d", got, wantRuns)
+		}
+		content, err := readArchiveFile(outputs[0], "tar.zst", "src.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(content)
+	}
+
+	build(1)
+	// Архив восстанавливается из кеша, даже если удален
+	if err := os.Remove("app-1.0.0-linux-amd64.criage"); err != nil {
+		t.Fatal(err)
+	}
+	if content := build(1); content != "v1" {
+		t.Errorf("cached archive content = %q", content)
+	}
+
+	if err := os.WriteFile("src.txt", []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if content := build(2); content != "v2" {
+		t.Errorf("rebuilt archive content = %q", content)
+	}
+
+	// Посторонняя переменная окружения процесса не входит в ключ
+	t.Setenv("CRIAGE_TEST_BUILD_FLAG", "1")
+	build(2)
+
+	// В режиме CleanEnv ключ зависит от разрешенных переменных
+	options.CleanEnv, options.KeepEnv = true, []string{"CRIAGE_TEST_BUILD_FLAG"}
+	build(3)
+	t.Setenv("CRIAGE_TEST_BUILD_FLAG", "2")
+	build(4)
+	build(4)
+
+	options.NoCache = true
+	build(5)
+}
+
+// TestPruneBuildCache проверяет удаление устаревших архивов и архивов, которые дольше
+// всего не использовались, при превышении размера кеша
+func TestPruneBuildCache(t *testing.T) {
+	maxAge, maxSize := buildCacheMaxAge, buildCacheMaxSize
+	buildCacheMaxAge, buildCacheMaxSize = time.Hour, 10
+	t.Cleanup(func() { buildCacheMaxAge, buildCacheMaxSize = maxAge, maxSize })
+
+	root := t.TempDir()
+	now := time.Now()
+	builds := map[string]time.Duration{
+		"app/1.0.0/builds/old":    2 * time.Hour,
+		"app/2.0.0/builds/least":  30 * time.Minute,
+		"lib/1.0.0/builds/recent": time.Minute,
+		"app/2.0.0/builds/newest": 0,
+	}
+	for name, age := range builds {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("12345"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pruneBuildCache(root); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]bool{
+		"app/1.0.0/builds/old":    false,
+		"app/2.0.0/builds/least":  false,
+		"lib/1.0.0/builds/recent": true,
+		"app/2.0.0/builds/newest": true,
+	} {
+		if _, err := os.Stat(filepath.Join(root, name)); (err == nil) != want {
+			t.Errorf("%s: exists %v, want %v", name, err == nil, want)
+		}
+	}
+}
+
+// TestBuildHooksOnce проверяет, что хуки pre_build и post_build выполняются один раз
+// за сборку нескольких целей, в том числе когда все цели взяты из кеша
+func TestBuildHooksOnce(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(project)
+
+	log := filepath.Join(dir, "hooks.log")
+	files := map[string]string{
+		LocalConfigName: "name: app\nversion: 1.0.0\nhooks:\n" +
+			"  pre_build: ['echo pre >> \"$LOG\"']\n" +
+			"  post_build: ['echo \"post $CRIAGE_ARCHIVES\" >> \"$LOG\"']\n",
+		BuildConfigName: `{"name": "app", "version": "1.0.0", "staging_dir": "stage", "build_script": "true",
+			"build_env": {"LOG": "` + filepath.ToSlash(log) + `"},
+			"targets": [{"os": "linux", "arch": "amd64"}, {"os": "linux", "arch": "arm64"}]}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pm := newTestPackageManager(dir)
+	for i := 0; i < 2; i++ {
+		if _, err := pm.BuildPackage(context.Background(), BuildOptions{Format: "tar.zst", CompressionLevel: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || lines[0] != "pre" || lines[2] != "pre" {
+		t.Fatalf("unexpected hook runs:\n%s", data)
+	}
+	archives := strings.Split(strings.TrimPrefix(lines[1], "post "), string(os.PathListSeparator))
+	if len(archives) != 2 || filepath.Base(archives[1]) != "app-1.0.0-linux-arm64.criage" || lines[3] != lines[1] {
+		t.Errorf("unexpected CRIAGE_ARCHIVES: %s", lines[1])
+	}
+}
@@ -0,0 +1,491 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	commonarchive "github.com/criage-oss/criage-common/archive"
+	commonconfig "github.com/criage-oss/criage-common/config"
+)
+
+// TestBuildConfigUnmarshal проверяет чтение build.json в snake_case и camelCase
+func TestBuildConfigUnmarshal(t *testing.T) {
+	var snake BuildConfig
+	data := `{"name":"app","build_script":"make","build_env":{"CGO_ENABLED":"0"},"output_dir":"dist",
+		"targets":[{"os":"linux","arch":"amd64","env":{"GOARCH":"amd64"}}]}`
+	if err := json.Unmarshal([]byte(data), &snake); err != nil {
+		t.Fatal(err)
+	}
+	if snake.BuildScript != "make" || snake.OutputDir != "dist" || snake.BuildEnv["CGO_ENABLED"] != "0" {
+		t.Errorf("unexpected config: %+v", snake)
+	}
+	if len(snake.Targets) != 1 || snake.Targets[0].Env["GOARCH"] != "amd64" {
+		t.Errorf("unexpected targets: %+v", snake.Targets)
+	}
+
+	var camel BuildConfig
+	data = `{"name":"app","buildScript":"make all","environment":{"A":"1"},"build_env":{"B":"2"},
+		"targets":[{"os":"darwin","arch":"arm64"}]}`
+	if err := json.Unmarshal([]byte(data), &camel); err != nil {
+		t.Fatal(err)
+	}
+	if camel.BuildScript != "make all" || camel.BuildEnv["A"] != "1" || camel.BuildEnv["B"] != "2" {
+		t.Errorf("unexpected config: %+v", camel)
+	}
+
+	env := camel.targetEnv(BuildTargetConfig{OS: "darwin", Arch: "arm64", Env: map[string]string{"B": "3"}})
+	if !reflect.DeepEqual(env, map[string]string{"A": "1", "B": "3"}) {
+		t.Errorf("target env must override build_env, got %v", env)
+	}
+}
+
+// TestSelectBuildTargets проверяет фильтры --target
+func TestSelectBuildTargets(t *testing.T) {
+	targets := []BuildTargetConfig{
+		{OS: "linux", Arch: "amd64"},
+		{OS: "linux", Arch: "arm64"},
+		{OS: "darwin", Arch: "arm64"},
+	}
+
+	tests := []struct {
+		filters []string
+		want    []string
+	}{
+		{nil, []string{"linux/amd64", "linux/arm64", "darwin/arm64"}},
+		{[]string{"linux"}, []string{"linux/amd64", "linux/arm64"}},
+		{[]string{"*/arm64"}, []string{"linux/arm64", "darwin/arm64"}},
+		{[]string{"darwin/arm64", "linux/arm64", "darwin"}, []string{"darwin/arm64", "linux/arm64"}},
+	}
+	for _, tt := range tests {
+		selected, err := selectBuildTargets(targets, tt.filters)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.filters, err)
+		}
+		var got []string
+		for _, target := range selected {
+			got = append(got, target.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.filters, got, tt.want)
+		}
+	}
+
+	for _, filter := range []string{"windows", "linux/386", "/amd64", "a/b/c"} {
+		if _, err := selectBuildTargets(targets, []string{filter}); err == nil {
+			t.Errorf("expected error for %q", filter)
+		}
+	}
+}
+
+// TestTargetOutputPath проверяет имена архивов целей
+func TestTargetOutputPath(t *testing.T) {
+	target := BuildTargetConfig{OS: "linux", Arch: "arm64"}
+
+	tests := []struct {
+		output   string
+		multiple bool
+		want     string
+	}{
+		{"", false, "app-1.0.0-linux-arm64.criage"},
+		{"", true, "app-1.0.0-linux-arm64.criage"},
+		{"dist/app.tar.zst", false, "dist/app.tar.zst"},
+		{"dist/app.tar.zst", true, "dist/app-linux-arm64.tar.zst"},
+		{"app.zip", true, "app-linux-arm64.zip"},
+		{"app", true, "app-linux-arm64"},
+	}
+	for _, tt := range tests {
+		if got := targetOutputPath(tt.output, "app", "1.0.0", target, tt.multiple); got != tt.want {
+			t.Errorf("targetOutputPath(%q, %v) = %q, want %q", tt.output, tt.multiple, got, tt.want)
+		}
+	}
+}
+
+// TestCreatePackageArchive проверяет, что архивы всех форматов читаются архивным менеджером
+func TestCreatePackageArchive(t *testing.T) {
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "bin", "app"), []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "debug.log"), []byte("log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := commonarchive.NewManager(commonconfig.DefaultConfig(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	metadata := &PackageMetadata{
+		PackageManifest: &PackageManifest{Name: "app", Version: "1.0.0"},
+		BuildManifest:   &BuildManifest{Targets: []BuildTarget{{OS: "linux", Arch: "amd64"}}},
+	}
+
+	for _, format := range []ArchiveFormat{"tar.zst", "tar.gz", "tar.lz4", "tar.xz", "zip"} {
+		output := filepath.Join(t.TempDir(), "app."+string(format))
+		if err := createPackageArchive(source, output, metadata, archiveOptions{format: format, level: CompressionNormal, excludeFiles: []string{"*.log"}}); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		extracted, err := manager.ExtractMetadataFromArchive(output, format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if extracted.BuildManifest == nil || len(extracted.BuildManifest.Targets) != 1 || extracted.BuildManifest.Targets[0].Arch != "amd64" {
+			t.Errorf("%s: unexpected metadata: %+v", format, extracted.BuildManifest)
+		}
+
+		dest := t.TempDir()
+		if err := manager.ExtractArchive(output, dest, format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if data, err := os.ReadFile(filepath.Join(dest, "bin", "app")); err != nil || string(data) != "binary" {
+			t.Errorf("%s: unexpected bin/app: %q, %v", format, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "debug.log")); !os.IsNotExist(err) {
+			t.Errorf("%s: excluded file must not be packed", format)
+		}
+	}
+}
+
+// TestCreatePackageArchiveSymlinks проверяет, что символические ссылки записываются в
+// архив как ссылки, а ссылки за пределы пакета отклоняются
+func TestCreatePackageArchiveSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on windows")
+	}
+	source := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(source, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "lib", "libx.so.1"), []byte("library"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("libx.so.1", filepath.Join(source, "lib", "libx.so")); err != nil {
+		t.Fatal(err)
+	}
+	metadata := &PackageMetadata{PackageManifest: &PackageManifest{Name: "app", Version: "1.0.0"}}
+
+	t.Run("links", func(t *testing.T) {
+		for _, format := range []ArchiveFormat{"tar.gz", "zip"} {
+			output := filepath.Join(t.TempDir(), "app."+string(format))
+			if err := createPackageArchive(source, output, metadata, archiveOptions{format: format}); err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+
+			var target string
+			if format == "zip" {
+				reader, err := zip.OpenReader(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				for _, file := range reader.File {
+					if file.Name == "lib/libx.so" && file.Mode()&os.ModeSymlink != 0 {
+						rc, err := file.Open()
+						if err != nil {
+							t.Fatal(err)
+						}
+						data, _ := io.ReadAll(rc)
+						rc.Close()
+						target = string(data)
+					}
+				}
+				reader.Close()
+			} else {
+				file, err := os.Open(output)
+				if err != nil {
+					t.Fatal(err)
+				}
+				decompressor, err := newDecompressor(file, format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				tarReader := tar.NewReader(decompressor)
+				for header, err := tarReader.Next(); err == nil; header, err = tarReader.Next() {
+					if header.Name == "lib/libx.so" && header.Typeflag == tar.TypeSymlink {
+						target = header.Linkname
+					}
+				}
+				decompressor.Close()
+				file.Close()
+			}
+			if target != "libx.so.1" {
+				t.Errorf("%s: lib/libx.so link target = %q", format, target)
+			}
+		}
+	})
+
+	t.Run("outside", func(t *testing.T) {
+		for _, link := range []string{"../../outside", "/etc/passwd"} {
+			dir := t.TempDir()
+			if err := os.Symlink(link, filepath.Join(dir, "escape")); err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(t.TempDir(), "app.tar.gz")
+			err := createPackageArchive(dir, output, metadata, archiveOptions{format: "tar.gz"})
+			if err == nil || !strings.Contains(err.Error(), "escape") {
+				t.Errorf("link to %s: expected error naming the file, got %v", link, err)
+			}
+		}
+	})
+}
+
+// TestCreatePackageArchiveLevels проверяет, что уровень сжатия применяется для всех
+// форматов, а недопустимый уровень отклоняется
+func TestCreatePackageArchiveLevels(t *testing.T) {
+	source := t.TempDir()
+	var data []byte
+	for i := 0; len(data) < 1<<20; i++ {
+		data = append(data, fmt.Sprintf("line %d of a compressible file %d\n", i, i%97)...)
+	}
+	if err := os.WriteFile(filepath.Join(source, "data.txt"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := commonarchive.NewManager(commonconfig.DefaultConfig(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	metadata := &PackageMetadata{PackageManifest: &PackageManifest{Name: "app", Version: "1.0.0"}}
+	for _, format := range []ArchiveFormat{"tar.zst", "tar.gz", "tar.lz4", "tar.xz", "zip"} {
+		sizes := map[int]int64{}
+		for _, level := range []int{CompressionFast, CompressionBest} {
+			output := filepath.Join(t.TempDir(), "app."+string(format))
+			if err := createPackageArchive(source, output, metadata, archiveOptions{format: format, level: level}); err != nil {
+				t.Fatalf("%s level %d: %v", format, level, err)
+			}
+			if err := manager.ExtractArchive(output, t.TempDir(), format); err != nil {
+				t.Fatalf("%s level %d: %v", format, level, err)
+			}
+			info, err := os.Stat(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sizes[level] = info.Size()
+		}
+		// Быстрый режим lz4 и режим высокого сжатия заметно различаются на любых данных
+		if format == "tar.lz4" && sizes[CompressionBest] >= sizes[CompressionFast] {
+			t.Errorf("%s: level %d (%d bytes) is not smaller than level %d (%d bytes)",
+				format, CompressionBest, sizes[CompressionBest], CompressionFast, sizes[CompressionFast])
+		}
+
+		for _, level := range []int{-1, 10} {
+			output := filepath.Join(t.TempDir(), "app."+string(format))
+			if err := createPackageArchive(source, output, metadata, archiveOptions{format: format, level: level}); err == nil {
+				t.Errorf("%s: expected error for level %d", format, level)
+			}
+		}
+	}
+}
+
+// TestCreatePackageArchiveReproducible проверяет, что архив не зависит от времени
+// изменения файлов и порядка их создания
+func TestCreatePackageArchiveReproducible(t *testing.T) {
+	modTime := time.Unix(1700000000, 0).UTC()
+	metadata := &PackageMetadata{
+		PackageManifest: &PackageManifest{Name: "app", Version: "1.0.0"},
+		CreatedAt:       modTime,
+	}
+
+	newSource := func(names []string, fileTime time.Time) string {
+		dir := t.TempDir()
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(path, fileTime, fileTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	first := newSource([]string{"a/b.txt", "a.txt", "z.txt"}, time.Now())
+	second := newSource([]string{"z.txt", "a.txt", "a/b.txt"}, time.Now().Add(-time.Hour))
+	if err := os.Chmod(filepath.Join(second, "z.txt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []ArchiveFormat{"tar.zst", "tar.gz", "tar.lz4", "tar.xz", "zip"} {
+		var digests []string
+		for _, source := range []string{first, second} {
+			output := filepath.Join(t.TempDir(), "app."+string(format))
+			if err := createPackageArchive(source, output, metadata, archiveOptions{format: format, level: CompressionNormal, modTime: modTime}); err != nil {
+				t.Fatalf("%s: %v", format, err)
+			}
+			digest, err := fileSHA256(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			digests = append(digests, digest)
+		}
+		if digests[0] != digests[1] {
+			t.Errorf("%s: archives differ: %s != %s", format, digests[0], digests[1])
+		}
+	}
+}
+
+// TestSourceDateEpoch проверяет разбор SOURCE_DATE_EPOCH
+func TestSourceDateEpoch(t *testing.T) {
+	tests := []struct {
+		env     []string
+		want    int64
+		wantErr bool
+	}{
+		{env: nil, want: defaultSourceDateEpoch},
+		{env: []string{"SOURCE_DATE_EPOCH=1700000000"}, want: 1700000000},
+		{env: []string{"SOURCE_DATE_EPOCH=1", "SOURCE_DATE_EPOCH=1700000001"}, want: 1700000001},
+		{env: []string{"SOURCE_DATE_EPOCH=10"}, want: defaultSourceDateEpoch},
+		{env: []string{"SOURCE_DATE_EPOCH=yesterday"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := sourceDateEpoch(tt.env)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("sourceDateEpoch(%v) error = %v, wantErr %v", tt.env, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got.Unix() != tt.want {
+			t.Errorf("sourceDateEpoch(%v) = %d, want %d", tt.env, got.Unix(), tt.want)
+		}
+	}
+}
+
+// TestCollectArchiveEntries проверяет отбор файлов шаблонами и пропуск собственных архивов
+func TestCollectArchiveEntries(t *testing.T) {
+	source := t.TempDir()
+	for _, name := range []string{"bin/app", "bin/app.debug", "lib/x/libx.so", "docs/readme.md", "app.log", "app-1.0.0.criage"} {
+		path := filepath.Join(source, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := collectArchiveEntries(source, []string{"bin/", "*.so", "*.criage"}, []string{"*.debug"}, []string{filepath.Join(source, "app-1.0.0.criage")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.name)
+	}
+	want := []string{"bin", "bin/app", "lib", "lib/x", "lib/x/libx.so"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("entries = %v, want %v", names, want)
+	}
+}
+
+// TestBuildEnvironment проверяет порядок применения переменных окружения сборки
+func TestBuildEnvironment(t *testing.T) {
+	environ := []string{"PATH=/bin", "HOME=/home/dev", "GOFLAGS=-mod=vendor", "SECRET=1", "CRIAGE_VERSION=0.0.1"}
+	buildConfig := &BuildConfig{
+		OutputDir: "dist",
+		BuildEnv:  map[string]string{"CGO_ENABLED": "0", "GOFLAGS": "-trimpath"},
+	}
+	manifest := &PackageManifest{Name: "app", Version: "2.0.0"}
+	target := BuildTargetConfig{OS: "linux", Arch: "arm64", Env: map[string]string{"CGO_ENABLED": "1", "CRIAGE_TARGET_OS": "x"}}
+
+	env, err := buildEnvironment(environ, buildConfig, manifest, target, BuildOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars := envMap(env)
+	outputDir, _ := filepath.Abs("dist")
+
+	want := map[string]string{
+		"PATH":                "/bin",
+		"SECRET":              "1",
+		"GOFLAGS":             "-trimpath",
+		"CGO_ENABLED":         "1",
+		"CRIAGE_PACKAGE_NAME": "app",
+		"CRIAGE_VERSION":      "2.0.0",
+		"CRIAGE_TARGET_OS":    "linux",
+		"CRIAGE_TARGET_ARCH":  "arm64",
+		"CRIAGE_OUTPUT_DIR":   outputDir,
+	}
+	for key, value := range want {
+		if vars[key] != value {
+			t.Errorf("%s = %q, want %q", key, vars[key], value)
+		}
+	}
+	if len(env) != len(vars) {
+		t.Errorf("duplicate variables in %v", env)
+	}
+
+	env, err = buildEnvironment(environ, buildConfig, manifest, target, BuildOptions{CleanEnv: true, KeepEnv: []string{"GOFLAGS"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vars = envMap(env)
+	if _, exists := vars["SECRET"]; exists {
+		t.Error("clean environment must not contain variables outside the allow list")
+	}
+	if vars["PATH"] != "/bin" || vars["HOME"] != "/home/dev" || vars["GOFLAGS"] != "-trimpath" || vars["CRIAGE_VERSION"] != "2.0.0" {
+		t.Errorf("unexpected clean environment: %v", env)
+	}
+}
+
+// envMap преобразует окружение в словарь
+func envMap(env []string) map[string]string {
+	vars := make(map[string]string, len(env))
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		vars[key] = value
+	}
+	return vars
+}
+
+// TestLoadBuildHooks проверяет чтение хуков сборки из criage.yaml
+func TestLoadBuildHooks(t *testing.T) {
+	cm := &ConfigManager{config: DefaultConfig()}
+
+	tests := []struct {
+		manifest            string
+		preBuild, postBuild []string
+	}{
+		{"name: app\nhooks:\n  pre_build: [go generate]\n  postBuild: [sha256sum $CRIAGE_ARCHIVE]\n  preInstall: [true]\n",
+			[]string{"go generate"}, []string{"sha256sum $CRIAGE_ARCHIVE"}},
+		{"name: app\nhooks: null\n", nil, nil},
+		{"name: app\n", nil, nil},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, LocalConfigName), []byte(tt.manifest), 0644); err != nil {
+			t.Fatal(err)
+		}
+		hooks, err := cm.LoadBuildHooks(dir)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.manifest, err)
+		}
+		if !reflect.DeepEqual(hooks.PreBuild, tt.preBuild) || !reflect.DeepEqual(hooks.PostBuild, tt.postBuild) {
+			t.Errorf("%q: unexpected hooks %+v", tt.manifest, hooks)
+		}
+	}
+
+	hooks, err := cm.LoadBuildHooks(t.TempDir())
+	if err != nil || len(hooks.PreBuild) != 0 {
+		t.Errorf("missing manifest must give no hooks, got %+v, %v", hooks, err)
+	}
+}
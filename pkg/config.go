@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	commontypes "github.com/criage-oss/criage-common/types"
@@ -14,220 +15,627 @@ import (
 const (
 	ConfigFileName    = "config.yaml"
 	LocalConfigName   = "criage.yaml"
+	BuildConfigName   = "build.json"
 	DefaultConfigDir  = ".config/criage"
 	DefaultCacheDir   = ".cache/criage"
 	DefaultGlobalPath = "/usr/local/lib/criage"
 	DefaultLocalPath  = "./criage_modules"
 )
 
-// ConfigManager управляет конфигурацией criage
+// ConfigManager управляет конфигурацией criage. Итоговая конфигурация собирается из слоев:
+// значения по умолчанию, /etc/criage/config.yaml, ~/.config/criage/config.yaml,
+// .criage/config.yaml проекта, переменные окружения CRIAGE_* и флаги командной строки.
+// Изменения записываются в пользовательскую конфигурацию
 type ConfigManager struct {
 	configPath string
 	config     *Config
+	layers     []*configLayer
+	overrides  map[string]string
+	origins    map[string]string
 }
 
 // NewConfigManager создает новый менеджер конфигурации
 func NewConfigManager() (*ConfigManager, error) {
+	return NewConfigManagerWithOverrides(nil)
+}
+
+// NewConfigManagerWithOverrides создает менеджер конфигурации с переопределениями
+// из командной строки, которые имеют наивысший приоритет
+func NewConfigManagerWithOverrides(overrides map[string]string) (*ConfigManager, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get home directory: %w", err)
 	}
 
-	configDir := filepath.Join(homeDir, DefaultConfigDir)
-	configPath := filepath.Join(configDir, ConfigFileName)
-
 	cm := &ConfigManager{
-		configPath: configPath,
+		configPath: filepath.Join(homeDir, DefaultConfigDir, ConfigFileName),
+		overrides:  overrides,
 	}
 
-	// Загружаем конфигурацию
-	config, err := cm.loadConfig()
-	if err != nil {
-		// Если конфигурация не найдена, создаем новую
-		config = DefaultConfig()
-
-		// Настраиваем пути относительно домашней директории
-		config.CachePath = filepath.Join(homeDir, DefaultCacheDir)
-		config.TempPath = filepath.Join(os.TempDir(), "criage")
-
-		// Создаем директории
-		if err := os.MkdirAll(configDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create config directory: %w", err)
+	for _, p := range configLayerPaths(cm.configPath) {
+		layer, err := loadConfigLayer(p.name, p.path)
+		if err != nil {
+			return nil, err
 		}
-
-		if err := cm.saveConfig(config); err != nil {
-			return nil, fmt.Errorf("failed to save default config: %w", err)
+		if p.name == LayerProject {
+			// Корень проекта - директория, содержащая .criage
+			layer.baseDir = filepath.Dir(filepath.Dir(p.path))
 		}
+		cm.layers = append(cm.layers, layer)
+	}
+
+	if err := cm.rebuild(); err != nil {
+		return nil, err
+	}
+
+	if err := cm.saveMigratedUserConfig(); err != nil {
+		return nil, err
 	}
 
-	cm.config = config
 	return cm, nil
 }
 
+// saveMigratedUserConfig записывает пользовательскую конфигурацию, мигрированную
+// со старой версии, предварительно сохранив исходный файл в <path>.v<версия>.bak.
+// Файлы системы и проекта мигрируются только в памяти
+func (cm *ConfigManager) saveMigratedUserConfig() error {
+	layer := cm.userLayer()
+	if layer.migratedFrom < 0 {
+		return nil
+	}
+
+	if _, err := backupConfigFile(layer.path, layer.content, fmt.Sprintf("v%d", layer.migratedFrom)); err != nil {
+		return err
+	}
+	layer.migratedFrom = -1
+	layer.content = nil
+	return cm.saveConfig()
+}
+
 // GetConfig возвращает текущую конфигурацию
 func (cm *ConfigManager) GetConfig() *Config {
 	return cm.config
 }
 
-// SetValue устанавливает значение конфигурации
+// settingsPrefix префикс ключей произвольных настроек (settings.<name>)
+const settingsPrefix = "settings."
+
+// configKeys известные ключи конфигурации в порядке вывода
+var configKeys = []string{
+	"global_path",
+	"local_path",
+	"cache_path",
+	"temp_path",
+	"compression.format",
+	"compression.level",
+	"parallel",
+	"timeout",
+	"retry_count",
+	"auto_update",
+	"verify_hashes",
+	"credential_helper",
+	"network.proxy",
+	"network.no_proxy",
+	"network.ca_bundle",
+	"network.client_cert",
+	"network.client_key",
+	"network.connect_timeout",
+	"network.download_timeout",
+	"network.max_connections",
+	"network.user_agent",
+	"rate_limit.requests_per_second",
+	"rate_limit.burst",
+	"install_policy.require_provenance",
+	"install_policy.require_clean_tree",
+}
+
+// ConfigKeys возвращает список известных ключей конфигурации
+func ConfigKeys() []string {
+	return append([]string(nil), configKeys...)
+}
+
+// SetValue проверяет и устанавливает значение конфигурации.
+// Произвольные настройки задаются ключами вида settings.<name>
 func (cm *ConfigManager) SetValue(key, value string) error {
+	// Проверяем значение на копии итоговой конфигурации
+	validated := *cm.config
+	if err := setConfigValue(&validated, key, value); err != nil {
+		return err
+	}
+
+	cm.userLayer().set(key, typedConfigValue(&validated, key))
+	return cm.saveConfig()
+}
+
+// setConfigValue проверяет значение и записывает его в конфигурацию
+func setConfigValue(config *Config, key, value string) error {
 	switch key {
-	case "global_path":
-		cm.config.GlobalPath = value
-	case "local_path":
-		cm.config.LocalPath = value
-	case "cache_path":
-		cm.config.CachePath = value
-	case "temp_path":
-		cm.config.TempPath = value
+	case "global_path", "local_path", "cache_path", "temp_path":
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("%s must not be empty", key)
+		}
+		if _, err := ExpandPath(value, ""); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		*configPathField(config, key) = value
 	case "compression.format":
-		cm.config.Compression.Format = value
+		switch ArchiveFormat(value) {
+		case commontypes.FormatTarZst, commontypes.FormatTarLZ4, commontypes.FormatTarXZ, commontypes.FormatTarGZ, commontypes.FormatZip:
+		default:
+			return fmt.Errorf("invalid compression.format value: %s (expected tar.zst, tar.lz4, tar.xz, tar.gz or zip)", value)
+		}
+		config.Compression.Format = value
 	case "compression.level":
-		level := 3 // По умолчанию
-		if value == "fast" || value == "1" {
-			level = CompressionFast
-		} else if value == "normal" || value == "3" {
-			level = CompressionNormal
-		} else if value == "best" || value == "9" {
-			level = CompressionBest
-		}
-		cm.config.Compression.Level = level
+		level, err := parseCompressionLevel(value)
+		if err != nil {
+			return err
+		}
+		config.Compression.Level = level
 	case "parallel":
-		var parallel int
-		if _, err := fmt.Sscanf(value, "%d", &parallel); err != nil {
-			return fmt.Errorf("invalid parallel value: %s", value)
+		parallel, err := parseIntValue(key, value)
+		if err != nil {
+			return err
 		}
 		if parallel < 1 || parallel > 64 {
 			return fmt.Errorf("parallel must be between 1 and 64")
 		}
-		cm.config.Parallel = parallel
+		config.Parallel = parallel
 	case "timeout":
-		var timeout int
-		if _, err := fmt.Sscanf(value, "%d", &timeout); err != nil {
-			return fmt.Errorf("invalid timeout value: %s", value)
+		timeout, err := parseIntValue(key, value)
+		if err != nil {
+			return err
+		}
+		if timeout < 1 {
+			return fmt.Errorf("timeout must be a positive number of seconds")
 		}
-		cm.config.Timeout = timeout
+		config.Timeout = timeout
 	case "retry_count":
-		var retryCount int
-		if _, err := fmt.Sscanf(value, "%d", &retryCount); err != nil {
-			return fmt.Errorf("invalid retry_count value: %s", value)
+		retryCount, err := parseIntValue(key, value)
+		if err != nil {
+			return err
+		}
+		if retryCount < 0 {
+			return fmt.Errorf("retry_count must not be negative")
+		}
+		config.RetryCount = retryCount
+	case "auto_update", "verify_hashes", "install_policy.require_provenance", "install_policy.require_clean_tree":
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid %s value: %s (expected true or false)", key, value)
+		}
+		switch key {
+		case "auto_update":
+			config.AutoUpdate = flag
+		case "verify_hashes":
+			config.VerifyHashes = flag
+		case "install_policy.require_provenance":
+			config.InstallPolicy.RequireProvenance = flag
+		default:
+			config.InstallPolicy.RequireCleanTree = flag
+		}
+	case "credential_helper":
+		// Относительный путь зависел бы от текущей директории, то есть от проекта
+		helper := strings.TrimSpace(value)
+		if strings.ContainsAny(helper, `/\`) {
+			if !filepath.IsAbs(helper) && !strings.HasPrefix(helper, "~/") {
+				return fmt.Errorf("credential_helper must be a helper name or an absolute path: %s", helper)
+			}
+			expanded, err := ExpandPath(helper, "")
+			if err != nil {
+				return err
+			}
+			helper = expanded
+		}
+		config.CredentialHelper = helper
+	case "network.proxy":
+		if value != "" {
+			if _, err := parseProxyURL(value); err != nil {
+				return err
+			}
+		}
+		config.Network.Proxy = value
+	case "network.no_proxy":
+		config.Network.NoProxy = value
+	case "network.user_agent":
+		config.Network.UserAgent = value
+	case "rate_limit.requests_per_second":
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 {
+			return fmt.Errorf("invalid rate_limit.requests_per_second value: %s (expected a non-negative number, 0 disables the limit)", value)
+		}
+		config.RateLimit.RequestsPerSecond = rate
+	case "rate_limit.burst":
+		burst, err := parseIntValue(key, value)
+		if err != nil {
+			return err
+		}
+		if burst < 1 {
+			return fmt.Errorf("rate_limit.burst must be at least 1")
+		}
+		config.RateLimit.Burst = burst
+	case "network.ca_bundle", "network.client_cert", "network.client_key":
+		// Необязательные пути: пустое значение отключает настройку
+		if value != "" {
+			if _, err := ExpandPath(value, ""); err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+		}
+		*configPathField(config, key) = value
+	case "network.connect_timeout", "network.download_timeout", "network.max_connections":
+		number, err := parseIntValue(key, value)
+		if err != nil {
+			return err
+		}
+		switch key {
+		case "network.connect_timeout":
+			if number < 1 {
+				return fmt.Errorf("network.connect_timeout must be a positive number of seconds")
+			}
+			config.Network.ConnectTimeout = number
+		case "network.download_timeout":
+			if number < 0 {
+				return fmt.Errorf("network.download_timeout must not be negative (0 disables the limit)")
+			}
+			config.Network.DownloadTimeout = number
+		default:
+			if number < 1 || number > 256 {
+				return fmt.Errorf("network.max_connections must be between 1 and 256")
+			}
+			config.Network.MaxConnections = number
 		}
-		cm.config.RetryCount = retryCount
-	case "auto_update":
-		cm.config.AutoUpdate = strings.ToLower(value) == "true"
-	case "verify_hashes":
-		cm.config.VerifyHashes = strings.ToLower(value) == "true"
 	default:
-		// Произвольные настройки
-		if cm.config.Settings == nil {
-			cm.config.Settings = make(map[string]interface{})
+		name, ok := settingName(key)
+		if !ok {
+			return fmt.Errorf("unknown config key: %s", key)
+		}
+		if config.Settings == nil {
+			config.Settings = make(map[string]interface{})
+		}
+		config.Settings[name] = value
+	}
+
+	return nil
+}
+
+// UnsetValue удаляет значение из пользовательской конфигурации; итоговым
+// становится значение из слоев с меньшим приоритетом или значение по умолчанию
+func (cm *ConfigManager) UnsetValue(key string) error {
+	if _, ok := settingName(key); !ok {
+		if _, err := configValue(cm.config, key); err != nil {
+			return err
 		}
-		cm.config.Settings[key] = value
 	}
 
-	return cm.saveConfig(cm.config)
+	if !cm.userLayer().unset(key) {
+		return fmt.Errorf("%s is not set in %s", key, cm.configPath)
+	}
+	return cm.saveConfig()
 }
 
 // GetValue получает значение конфигурации
 func (cm *ConfigManager) GetValue(key string) (string, error) {
+	return configValue(cm.config, key)
+}
+
+// configValue возвращает значение ключа конфигурации в виде строки
+func configValue(config *Config, key string) (string, error) {
 	switch key {
-	case "global_path":
-		return cm.config.GlobalPath, nil
-	case "local_path":
-		return cm.config.LocalPath, nil
-	case "cache_path":
-		return cm.config.CachePath, nil
-	case "temp_path":
-		return cm.config.TempPath, nil
+	case "global_path", "local_path", "cache_path", "temp_path":
+		return *configPathField(config, key), nil
 	case "compression.format":
-		return cm.config.Compression.Format, nil
+		return config.Compression.Format, nil
 	case "compression.level":
-		return fmt.Sprintf("%d", cm.config.Compression.Level), nil
+		return strconv.Itoa(config.Compression.Level), nil
 	case "parallel":
-		return fmt.Sprintf("%d", cm.config.Parallel), nil
+		return strconv.Itoa(config.Parallel), nil
 	case "timeout":
-		return fmt.Sprintf("%d", cm.config.Timeout), nil
+		return strconv.Itoa(config.Timeout), nil
 	case "retry_count":
-		return fmt.Sprintf("%d", cm.config.RetryCount), nil
+		return strconv.Itoa(config.RetryCount), nil
 	case "auto_update":
-		return fmt.Sprintf("%t", cm.config.AutoUpdate), nil
+		return strconv.FormatBool(config.AutoUpdate), nil
 	case "verify_hashes":
-		return fmt.Sprintf("%t", cm.config.VerifyHashes), nil
-	default:
-		if cm.config.Settings != nil {
-			if value, exists := cm.config.Settings[key]; exists {
-				return fmt.Sprintf("%v", value), nil
-			}
-		}
+		return strconv.FormatBool(config.VerifyHashes), nil
+	case "credential_helper":
+		return config.CredentialHelper, nil
+	case "network.proxy":
+		return config.Network.Proxy, nil
+	case "network.no_proxy":
+		return config.Network.NoProxy, nil
+	case "network.ca_bundle", "network.client_cert", "network.client_key":
+		return *configPathField(config, key), nil
+	case "network.connect_timeout":
+		return strconv.Itoa(config.Network.ConnectTimeout), nil
+	case "network.download_timeout":
+		return strconv.Itoa(config.Network.DownloadTimeout), nil
+	case "network.max_connections":
+		return strconv.Itoa(config.Network.MaxConnections), nil
+	case "network.user_agent":
+		return config.Network.UserAgent, nil
+	case "rate_limit.requests_per_second":
+		return strconv.FormatFloat(config.RateLimit.RequestsPerSecond, 'g', -1, 64), nil
+	case "rate_limit.burst":
+		return strconv.Itoa(config.RateLimit.Burst), nil
+	case "install_policy.require_provenance":
+		return strconv.FormatBool(config.InstallPolicy.RequireProvenance), nil
+	case "install_policy.require_clean_tree":
+		return strconv.FormatBool(config.InstallPolicy.RequireCleanTree), nil
+	}
+
+	name, ok := settingName(key)
+	if !ok {
 		return "", fmt.Errorf("unknown config key: %s", key)
 	}
+	value, exists := config.Settings[name]
+	if !exists {
+		return "", fmt.Errorf("setting is not set: %s", key)
+	}
+	return fmt.Sprintf("%v", value), nil
 }
 
 // ListValues возвращает все значения конфигурации
 func (cm *ConfigManager) ListValues() map[string]string {
-	values := map[string]string{
-		"global_path":        cm.config.GlobalPath,
-		"local_path":         cm.config.LocalPath,
-		"cache_path":         cm.config.CachePath,
-		"temp_path":          cm.config.TempPath,
-		"compression.format": cm.config.Compression.Format,
-		"compression.level":  fmt.Sprintf("%d", cm.config.Compression.Level),
-		"parallel":           fmt.Sprintf("%d", cm.config.Parallel),
-		"timeout":            fmt.Sprintf("%d", cm.config.Timeout),
-		"retry_count":        fmt.Sprintf("%d", cm.config.RetryCount),
-		"auto_update":        fmt.Sprintf("%t", cm.config.AutoUpdate),
-		"verify_hashes":      fmt.Sprintf("%t", cm.config.VerifyHashes),
+	values := make(map[string]string, len(configKeys)+len(cm.config.Settings))
+	for _, key := range configKeys {
+		values[key], _ = configValue(cm.config, key)
 	}
 
 	// Добавляем произвольные настройки
-	if cm.config.Settings != nil {
-		for key, value := range cm.config.Settings {
-			values[key] = fmt.Sprintf("%v", value)
-		}
+	for name, value := range cm.config.Settings {
+		values[settingsPrefix+name] = fmt.Sprintf("%v", value)
 	}
 
 	return values
 }
 
-// AddRepository добавляет новый репозиторий
+// defaultConfig возвращает конфигурацию по умолчанию с путями,
+// вычисленными так же, как при первом запуске
+func (cm *ConfigManager) defaultConfig() (*Config, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	config := DefaultConfig()
+	config.CachePath = filepath.Join(homeDir, DefaultCacheDir)
+	config.TempPath = filepath.Join(os.TempDir(), "criage")
+	return config, nil
+}
+
+// configPathField возвращает указатель на поле пути конфигурации
+func configPathField(config *Config, key string) *string {
+	switch key {
+	case "global_path":
+		return &config.GlobalPath
+	case "local_path":
+		return &config.LocalPath
+	case "cache_path":
+		return &config.CachePath
+	case "network.ca_bundle":
+		return &config.Network.CABundle
+	case "network.client_cert":
+		return &config.Network.ClientCert
+	case "network.client_key":
+		return &config.Network.ClientKey
+	default:
+		return &config.TempPath
+	}
+}
+
+// settingName возвращает имя произвольной настройки из ключа settings.<name>
+func settingName(key string) (string, bool) {
+	name := strings.TrimPrefix(key, settingsPrefix)
+	if name == key || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+// parseIntValue разбирает целочисленное значение ключа
+func parseIntValue(key, value string) (int, error) {
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value: %s (expected an integer)", key, value)
+	}
+	return number, nil
+}
+
+// parseCompressionLevel разбирает уровень сжатия: fast, normal, best или число от 1 до 9
+func parseCompressionLevel(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "fast":
+		return CompressionFast, nil
+	case "normal":
+		return CompressionNormal, nil
+	case "best":
+		return CompressionBest, nil
+	}
+
+	level, err := strconv.Atoi(value)
+	if err != nil || level < 1 || level > 9 {
+		return 0, fmt.Errorf("invalid compression.level value: %s (expected fast, normal, best or 1-9)", value)
+	}
+	return level, nil
+}
+
+// AddRepository добавляет новый репозиторий в пользовательскую конфигурацию.
+// Репозиторий с тем же именем заменяется
 func (cm *ConfigManager) AddRepository(name, url, repoType string, priority int) error {
-	// Проверяем, не существует ли уже репозиторий с таким именем
-	for i, repo := range cm.config.Repositories {
-		if repo.Name == name {
-			// Обновляем существующий
-			cm.config.Repositories[i] = Repository{
-				Name:     name,
-				URL:      url,
-				Priority: priority,
-				Enabled:  true,
-			}
-			return cm.saveConfig(cm.config)
-		}
+	if name == "" {
+		return fmt.Errorf("repository name must not be empty")
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("invalid repository URL: %s (expected http:// or https://)", url)
 	}
 
-	// Добавляем новый репозиторий
-	cm.config.Repositories = append(cm.config.Repositories, Repository{
+	repositories, err := cm.userRepositories()
+	if err != nil {
+		return err
+	}
+
+	repo := Repository{
 		Name:     name,
 		URL:      url,
 		Priority: priority,
 		Enabled:  true,
-	})
+	}
+
+	// Проверяем, не существует ли уже репозиторий с таким именем
+	for i := range repositories {
+		if repositories[i].Name == name {
+			// Обновляем существующий
+			repositories[i] = repo
+			return cm.saveUserRepositories(repositories)
+		}
+	}
 
-	return cm.saveConfig(cm.config)
+	// Добавляем новый репозиторий
+	return cm.saveUserRepositories(append(repositories, repo))
 }
 
-// RemoveRepository удаляет репозиторий
+// RemoveRepository удаляет репозиторий из пользовательской конфигурации
 func (cm *ConfigManager) RemoveRepository(name string) error {
-	for i, repo := range cm.config.Repositories {
+	repositories, err := cm.userRepositories()
+	if err != nil {
+		return err
+	}
+
+	for i, repo := range repositories {
 		if repo.Name == name {
-			cm.config.Repositories = append(cm.config.Repositories[:i], cm.config.Repositories[i+1:]...)
-			return cm.saveConfig(cm.config)
+			return cm.saveUserRepositories(append(repositories[:i], repositories[i+1:]...))
 		}
 	}
 
+	if _, exists := cm.GetRepository(name); exists {
+		return fmt.Errorf("repository %s is defined in %s and cannot be removed from the user config", name, cm.Origin(repositoryKey(name)))
+	}
 	return fmt.Errorf("repository not found: %s", name)
 }
 
+// GetRepository возвращает репозиторий по имени
+func (cm *ConfigManager) GetRepository(name string) (*Repository, bool) {
+	for i := range cm.config.Repositories {
+		if cm.config.Repositories[i].Name == name {
+			repo := cm.config.Repositories[i]
+			return &repo, true
+		}
+	}
+	return nil, false
+}
+
+// SetRepositoryEnabled включает или отключает репозиторий
+func (cm *ConfigManager) SetRepositoryEnabled(name string, enabled bool) error {
+	return cm.updateRepository(name, func(repo *Repository) {
+		repo.Enabled = enabled
+	})
+}
+
+// SetRepositoryPriority устанавливает приоритет репозитория
+func (cm *ConfigManager) SetRepositoryPriority(name string, priority int) error {
+	return cm.updateRepository(name, func(repo *Repository) {
+		repo.Priority = priority
+	})
+}
+
+// SetRepositoryRateLimit задает ограничение частоты запросов к репозиторию
+// в пользовательской конфигурации; nil удаляет его, и действует общее ограничение
+func (cm *ConfigManager) SetRepositoryRateLimit(name string, limits *RateLimitSettings) error {
+	if _, exists := cm.GetRepository(name); !exists {
+		return fmt.Errorf("repository not found: %s", name)
+	}
+	if limits != nil {
+		if limits.RequestsPerSecond < 0 {
+			return fmt.Errorf("requests per second must not be negative")
+		}
+		if limits.Burst < 1 {
+			return fmt.Errorf("burst must be at least 1")
+		}
+	}
+
+	layer := cm.userLayer()
+	section, _ := layer.data["rate_limit"].(map[string]interface{})
+	if section == nil {
+		section = make(map[string]interface{})
+	}
+	repositories, _ := section["repositories"].(map[string]interface{})
+	if repositories == nil {
+		repositories = make(map[string]interface{})
+	}
+
+	if limits == nil {
+		if _, exists := repositories[name]; !exists {
+			return fmt.Errorf("no rate limit set for %s in %s", name, cm.configPath)
+		}
+		delete(repositories, name)
+	} else {
+		repositories[name] = map[string]interface{}{
+			"requests_per_second": limits.RequestsPerSecond,
+			"burst":               limits.Burst,
+		}
+	}
+
+	if len(repositories) > 0 {
+		section["repositories"] = repositories
+	} else {
+		delete(section, "repositories")
+	}
+	if len(section) > 0 {
+		layer.data["rate_limit"] = section
+	} else {
+		delete(layer.data, "rate_limit")
+	}
+
+	return cm.saveConfig()
+}
+
+// ClearRepositoryCredentials удаляет учетные данные, записанные открытым текстом
+// в пользовательской конфигурации. Возвращает true, если конфигурация изменилась
+func (cm *ConfigManager) ClearRepositoryCredentials(name string) (bool, error) {
+	repositories, err := cm.userRepositories()
+	if err != nil {
+		return false, err
+	}
+
+	for i := range repositories {
+		repo := &repositories[i]
+		if repo.Name != name || (repo.AuthToken == "" && repo.Username == "" && repo.Password == "") {
+			continue
+		}
+		repo.AuthToken, repo.Username, repo.Password = "", "", ""
+		return true, cm.saveUserRepositories(repositories)
+	}
+	return false, nil
+}
+
+// updateRepository изменяет репозиторий и сохраняет пользовательскую конфигурацию.
+// Репозиторий из другого слоя копируется в пользовательскую конфигурацию и переопределяется в ней
+func (cm *ConfigManager) updateRepository(name string, update func(repo *Repository)) error {
+	repositories, err := cm.userRepositories()
+	if err != nil {
+		return err
+	}
+
+	for i := range repositories {
+		if repositories[i].Name == name {
+			update(&repositories[i])
+			return cm.saveUserRepositories(repositories)
+		}
+	}
+
+	repo, exists := cm.GetRepository(name)
+	if !exists {
+		return fmt.Errorf("repository not found: %s", name)
+	}
+
+	// Конфигурация проекта приоритетнее пользовательской, переопределение не подействует
+	if origin := cm.Origin(repositoryKey(name)); strings.HasPrefix(origin, LayerProject+":") {
+		return fmt.Errorf("repository %s is defined in %s; change it there", name, origin)
+	}
+
+	update(repo)
+	return cm.saveUserRepositories(append(repositories, *repo))
+}
+
 // GetRepositories возвращает список репозиториев
 func (cm *ConfigManager) GetRepositories() []Repository {
 	return cm.config.Repositories
@@ -238,10 +646,9 @@ func (cm *ConfigManager) GetRepositoriesCommon() []commontypes.Repository {
 	return cm.config.Repositories
 }
 
-// SetRepositoriesCommon устанавливает репозитории из формата common/types.Repository
+// SetRepositoriesCommon устанавливает репозитории пользовательской конфигурации из формата common/types.Repository
 func (cm *ConfigManager) SetRepositoriesCommon(repos []commontypes.Repository) error {
-	cm.config.Repositories = repos
-	return cm.saveConfig(cm.config)
+	return cm.saveUserRepositories(repos)
 }
 
 // LoadLocalConfig загружает локальную конфигурацию проекта
@@ -252,6 +659,11 @@ func (cm *ConfigManager) LoadLocalConfig(projectPath string) (*PackageManifest,
 		return nil, fmt.Errorf("local config not found")
 	}
 
+	return cm.LoadManifestFile(configPath)
+}
+
+// LoadManifestFile загружает манифест пакета из файла YAML или JSON
+func (cm *ConfigManager) LoadManifestFile(configPath string) (*PackageManifest, error) {
 	data, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read local config: %w", err)
@@ -274,6 +686,26 @@ func (cm *ConfigManager) LoadLocalConfig(projectPath string) (*PackageManifest,
 	return nil, fmt.Errorf("failed to parse local config: unsupported format")
 }
 
+// LoadBuildHooks загружает хуки сборки из манифеста проекта.
+// Отсутствие манифеста или секции hooks не является ошибкой
+func (cm *ConfigManager) LoadBuildHooks(projectPath string) (*BuildHooks, error) {
+	var manifest struct {
+		Hooks BuildHooks `yaml:"hooks"`
+	}
+
+	data, err := os.ReadFile(filepath.Join(projectPath, LocalConfigName))
+	if os.IsNotExist(err) {
+		return &manifest.Hooks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse build hooks: %w", err)
+	}
+	return &manifest.Hooks, nil
+}
+
 // SaveLocalConfig сохраняет локальную конфигурацию проекта
 func (cm *ConfigManager) SaveLocalConfig(projectPath string, manifest *PackageManifest) error {
 	configPath := filepath.Join(projectPath, LocalConfigName)
@@ -291,8 +723,8 @@ func (cm *ConfigManager) SaveLocalConfig(projectPath string, manifest *PackageMa
 }
 
 // LoadBuildConfig загружает конфигурацию сборки
-func (cm *ConfigManager) LoadBuildConfig(projectPath string) (*BuildManifest, error) {
-	configPath := filepath.Join(projectPath, "build.json")
+func (cm *ConfigManager) LoadBuildConfig(projectPath string) (*BuildConfig, error) {
+	configPath := filepath.Join(projectPath, BuildConfigName)
 
 	if _, err := os.Stat(configPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("build config not found")
@@ -303,19 +735,19 @@ func (cm *ConfigManager) LoadBuildConfig(projectPath string) (*BuildManifest, er
 		return nil, fmt.Errorf("failed to read build config: %w", err)
 	}
 
-	var manifest BuildManifest
-	if err := json.Unmarshal(data, &manifest); err != nil {
+	var buildConfig BuildConfig
+	if err := json.Unmarshal(data, &buildConfig); err != nil {
 		return nil, fmt.Errorf("failed to parse build config: %w", err)
 	}
 
-	return &manifest, nil
+	return &buildConfig, nil
 }
 
 // SaveBuildConfig сохраняет конфигурацию сборки
-func (cm *ConfigManager) SaveBuildConfig(projectPath string, manifest *BuildManifest) error {
-	configPath := filepath.Join(projectPath, "build.json")
+func (cm *ConfigManager) SaveBuildConfig(projectPath string, buildConfig *BuildConfig) error {
+	configPath := filepath.Join(projectPath, BuildConfigName)
 
-	data, err := json.MarshalIndent(manifest, "", "  ")
+	data, err := json.MarshalIndent(buildConfig, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal build config: %w", err)
 	}
@@ -344,37 +776,36 @@ func (cm *ConfigManager) EnsureDirectories() error {
 	return nil
 }
 
-// loadConfig загружает конфигурацию из файла
-func (cm *ConfigManager) loadConfig() (*Config, error) {
-	if _, err := os.Stat(cm.configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("config file not found")
-	}
+// saveConfig сохраняет пользовательскую конфигурацию в файл и пересобирает итоговую
+func (cm *ConfigManager) saveConfig() error {
+	layer := cm.userLayer()
+	layer.data["config_version"] = CurrentConfigVersion
 
-	data, err := os.ReadFile(cm.configPath)
+	data, err := yaml.Marshal(layer.data)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config: %w", err)
+	if err := os.MkdirAll(filepath.Dir(cm.configPath), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
 	}
 
-	return &config, nil
-}
-
-// saveConfig сохраняет конфигурацию в файл
-func (cm *ConfigManager) saveConfig(config *Config) error {
-	data, err := yaml.Marshal(config)
-	if err != nil {
-		return fmt.Errorf("failed to marshal config: %w", err)
+	// Учетные данные, оставшиеся в конфигурации открытым текстом, не должны быть доступны другим
+	perm := os.FileMode(0644)
+	if repositories, err := cm.userRepositories(); err == nil {
+		for _, repo := range repositories {
+			if repo.AuthToken != "" || repo.Password != "" {
+				perm = 0600
+			}
+		}
 	}
 
-	if err := os.WriteFile(cm.configPath, data, 0644); err != nil {
+	// Запись через временный файл, чтобы сбой не оставил поврежденную конфигурацию
+	if err := writeFileAtomic(cm.configPath, data, perm); err != nil {
 		return fmt.Errorf("failed to write config file: %w", err)
 	}
 
-	return nil
+	return cm.rebuild()
 }
 
 // GetCachePath возвращает путь к кешу для пакета
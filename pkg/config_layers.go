@@ -0,0 +1,521 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SystemConfigPath системная конфигурация, общая для всех пользователей
+	SystemConfigPath = "/etc/criage/config.yaml"
+	// ProjectConfigDir директория конфигурации проекта
+	ProjectConfigDir = ".criage"
+	// EnvPrefix префикс переменных окружения, переопределяющих конфигурацию
+	EnvPrefix = "CRIAGE_"
+)
+
+// Слои конфигурации в порядке возрастания приоритета
+const (
+	LayerDefault = "default"
+	LayerSystem  = "system"
+	LayerUser    = "user"
+	LayerProject = "project"
+	LayerEnv     = "env"
+	LayerFlag    = "flag"
+)
+
+// configLayer файл конфигурации одного слоя. data хранит только явно
+// заданные в файле значения, чтобы слои не перекрывали друг друга значениями по умолчанию
+type configLayer struct {
+	name string
+	path string
+	data map[string]interface{}
+	// baseDir директория для относительных путей; пустая означает текущую директорию
+	baseDir string
+	// migratedFrom исходная версия мигрированного файла, иначе -1
+	migratedFrom int
+	// content исходное содержимое файла, используется для резервной копии при миграции
+	content []byte
+}
+
+// loadConfigLayer читает файл слоя. Отсутствующий файл дает пустой слой.
+// Файл, не прошедший проверку схемы, дает *ConfigValidationError; файл
+// старой версии мигрируется в памяти, migratedFrom указывает исходную версию
+func loadConfigLayer(name, path string) (*configLayer, error) {
+	layer := &configLayer{
+		name:         name,
+		path:         path,
+		data:         make(map[string]interface{}),
+		migratedFrom: -1,
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return layer, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	parsed, fieldErrors, err := parseConfigData(path, content)
+	if err != nil {
+		return nil, err
+	}
+	if len(fieldErrors) > 0 {
+		return nil, &ConfigValidationError{Path: path, Errors: fieldErrors}
+	}
+
+	layer.data = parsed.data
+	layer.migratedFrom = parsed.migratedFrom
+	layer.content = content
+	return layer, nil
+}
+
+// origin возвращает описание источника значений слоя
+func (l *configLayer) origin() string {
+	return l.name + ":" + l.path
+}
+
+// has возвращает true, если ключ задан в слое
+func (l *configLayer) has(key string) bool {
+	section, name := splitConfigKey(key)
+	if section == "" {
+		_, exists := l.data[name]
+		return exists
+	}
+
+	values, ok := l.data[section].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, exists := values[name]
+	return exists
+}
+
+// set записывает значение ключа в слой
+func (l *configLayer) set(key string, value interface{}) {
+	section, name := splitConfigKey(key)
+	if section == "" {
+		l.data[name] = value
+		return
+	}
+
+	values, ok := l.data[section].(map[string]interface{})
+	if !ok {
+		values = make(map[string]interface{})
+		l.data[section] = values
+	}
+	values[name] = value
+}
+
+// unset удаляет значение ключа из слоя
+func (l *configLayer) unset(key string) bool {
+	if !l.has(key) {
+		return false
+	}
+
+	section, name := splitConfigKey(key)
+	if section == "" {
+		delete(l.data, name)
+		return true
+	}
+
+	values := l.data[section].(map[string]interface{})
+	delete(values, name)
+	if len(values) == 0 {
+		delete(l.data, section)
+	}
+	return true
+}
+
+// decode декодирует значения слоя в структуру конфигурации
+func (l *configLayer) decode() (*Config, error) {
+	data, err := yaml.Marshal(l.data)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// repositories возвращает репозитории слоя и признак того, что список задан
+func (l *configLayer) repositories() ([]Repository, bool, error) {
+	if _, exists := l.data["repositories"]; !exists {
+		return nil, false, nil
+	}
+
+	config, err := l.decode()
+	if err != nil {
+		return nil, true, err
+	}
+	return config.Repositories, true, nil
+}
+
+// setRepositories заменяет список репозиториев слоя
+func (l *configLayer) setRepositories(repositories []Repository) {
+	if repositories == nil {
+		repositories = []Repository{}
+	}
+	l.data["repositories"] = repositories
+}
+
+// settings возвращает произвольные настройки слоя
+func (l *configLayer) settings() map[string]interface{} {
+	settings, _ := l.data["settings"].(map[string]interface{})
+	return settings
+}
+
+// apply накладывает значения слоя на конфигурацию и запоминает их источник.
+// reposDefined отмечает, что список репозиториев уже задан одним из файлов:
+// репозитории по умолчанию используются только если ни один файл их не задает,
+// а репозитории из разных файлов объединяются по имени
+func (l *configLayer) apply(config *Config, origins, baseDirs map[string]string, reposDefined *bool) error {
+	partial, err := l.decode()
+	if err != nil {
+		return err
+	}
+	if l.name == LayerProject {
+		if err := l.checkProjectRestrictions(config); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range configKeys {
+		if !l.has(key) {
+			continue
+		}
+		value, _ := configValue(partial, key)
+		if err := setConfigValue(config, key, value); err != nil {
+			return err
+		}
+		origins[key] = l.origin()
+		baseDirs[key] = l.baseDir
+	}
+
+	for name, value := range l.settings() {
+		if config.Settings == nil {
+			config.Settings = make(map[string]interface{})
+		}
+		config.Settings[name] = value
+		origins[settingsPrefix+name] = l.origin()
+	}
+
+	// Ограничения для отдельных репозиториев объединяются по имени репозитория
+	for name, limits := range partial.RateLimit.Repositories {
+		if config.RateLimit.Repositories == nil {
+			config.RateLimit.Repositories = make(map[string]RateLimitSettings)
+		}
+		config.RateLimit.Repositories[name] = limits
+		origins[rateLimitKey(name)] = l.origin()
+	}
+
+	repositories, defined, err := l.repositories()
+	if err != nil {
+		return err
+	}
+	if defined {
+		if !*reposDefined {
+			for _, repo := range config.Repositories {
+				delete(origins, repositoryKey(repo.Name))
+			}
+			config.Repositories = nil
+			*reposDefined = true
+		}
+		config.Repositories = mergeRepositories(config.Repositories, repositories)
+		for _, repo := range repositories {
+			origins[repositoryKey(repo.Name)] = l.origin()
+		}
+	}
+
+	return nil
+}
+
+// projectRestricted возвращает true для ключей, которые нельзя задать в конфигурации
+// проекта: проект приходит вместе с чужим репозиторием и не должен запускать
+// программы или ослаблять политику установки
+func projectRestricted(key string) bool {
+	return key == "credential_helper" || strings.HasPrefix(key, "install_policy.")
+}
+
+// checkProjectRestrictions проверяет, что конфигурация проекта не задает ключи
+// projectRestricted и не меняет адрес репозитория, заданного системой, пользователем
+// или значениями по умолчанию: иначе учетные данные ушли бы на другой сервер
+func (l *configLayer) checkProjectRestrictions(config *Config) error {
+	for _, key := range configKeys {
+		if projectRestricted(key) && l.has(key) {
+			return fmt.Errorf("%s cannot be set in the project config", key)
+		}
+	}
+
+	repositories, _, err := l.repositories()
+	if err != nil {
+		return err
+	}
+	for _, repo := range repositories {
+		for _, existing := range config.Repositories {
+			if existing.Name == repo.Name && normalizeRepositoryURL(existing.URL) != normalizeRepositoryURL(repo.URL) {
+				return fmt.Errorf("repository %s: url cannot be changed in the project config", repo.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeRepositories объединяет списки репозиториев по имени; записи из overrides заменяют base
+func mergeRepositories(base, overrides []Repository) []Repository {
+	merged := append([]Repository(nil), base...)
+	for _, repo := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].Name == repo.Name {
+				merged[i] = repo
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, repo)
+		}
+	}
+	return merged
+}
+
+// rateLimitKey ключ источника для ограничения частоты запросов репозитория
+func rateLimitKey(name string) string {
+	return "rate_limit.repositories." + name
+}
+
+// repositoryKey ключ источника для репозитория
+func repositoryKey(name string) string {
+	return "repositories." + name
+}
+
+// splitConfigKey разделяет ключ на секцию и имя: "compression.level" -> ("compression", "level")
+func splitConfigKey(key string) (string, string) {
+	if i := strings.Index(key, "."); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// configEnvName возвращает имя переменной окружения для ключа: compression.level -> CRIAGE_COMPRESSION_LEVEL
+func configEnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// typedConfigValue возвращает значение ключа с типом, в котором оно хранится в YAML
+func typedConfigValue(config *Config, key string) interface{} {
+	switch key {
+	case "compression.level":
+		return config.Compression.Level
+	case "parallel":
+		return config.Parallel
+	case "timeout":
+		return config.Timeout
+	case "retry_count":
+		return config.RetryCount
+	case "auto_update":
+		return config.AutoUpdate
+	case "verify_hashes":
+		return config.VerifyHashes
+	case "network.connect_timeout":
+		return config.Network.ConnectTimeout
+	case "network.download_timeout":
+		return config.Network.DownloadTimeout
+	case "network.max_connections":
+		return config.Network.MaxConnections
+	case "rate_limit.requests_per_second":
+		return config.RateLimit.RequestsPerSecond
+	case "rate_limit.burst":
+		return config.RateLimit.Burst
+	case "install_policy.require_provenance":
+		return config.InstallPolicy.RequireProvenance
+	case "install_policy.require_clean_tree":
+		return config.InstallPolicy.RequireCleanTree
+	}
+
+	value, _ := configValue(config, key)
+	return value
+}
+
+// findProjectConfig ищет .criage/config.yaml в директории dir и ее родителях
+func findProjectConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, ProjectConfigDir, ConfigFileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// layerPath файл конфигурации слоя
+type layerPath struct {
+	name string
+	path string
+}
+
+// configLayerPaths возвращает файлы слоев system, user и project (если найден)
+func configLayerPaths(userPath string) []layerPath {
+	paths := []layerPath{
+		{LayerSystem, SystemConfigPath},
+		{LayerUser, userPath},
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		if projectPath := findProjectConfig(cwd); projectPath != "" {
+			paths = append(paths, layerPath{LayerProject, projectPath})
+		}
+	}
+	return paths
+}
+
+// ConfigFiles возвращает существующие файлы конфигурации в порядке приоритета слоев.
+// Файлы не разбираются, поэтому функция работает и с поврежденной конфигурацией
+func ConfigFiles() ([]string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	var files []string
+	for _, p := range configLayerPaths(filepath.Join(homeDir, DefaultConfigDir, ConfigFileName)) {
+		if _, err := os.Stat(p.path); err == nil {
+			files = append(files, p.path)
+		}
+	}
+	return files, nil
+}
+
+// ParseConfigOverrides разбирает переопределения вида key=value из командной строки
+func ParseConfigOverrides(values []string) (map[string]string, error) {
+	overrides := make(map[string]string, len(values))
+	for _, value := range values {
+		key, setting, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid config override: %s (expected key=value)", value)
+		}
+		overrides[key] = setting
+	}
+	return overrides, nil
+}
+
+// rebuild вычисляет итоговую конфигурацию: значения по умолчанию, затем файлы
+// слоев, переменные окружения CRIAGE_* и переопределения из командной строки.
+// Относительные пути из конфигурации проекта разрешаются от корня проекта,
+// остальные - от текущей директории
+func (cm *ConfigManager) rebuild() error {
+	config, err := cm.defaultConfig()
+	if err != nil {
+		return err
+	}
+
+	origins := make(map[string]string)
+	for _, key := range configKeys {
+		origins[key] = LayerDefault
+	}
+	for _, repo := range config.Repositories {
+		origins[repositoryKey(repo.Name)] = LayerDefault
+	}
+
+	baseDirs := make(map[string]string)
+	reposDefined := false
+	for _, layer := range cm.layers {
+		if err := layer.apply(config, origins, baseDirs, &reposDefined); err != nil {
+			return fmt.Errorf("invalid config %s: %w", layer.path, err)
+		}
+	}
+
+	for _, key := range configKeys {
+		name := configEnvName(key)
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			continue
+		}
+		if err := setConfigValue(config, key, value); err != nil {
+			return fmt.Errorf("invalid environment variable %s: %w", name, err)
+		}
+		origins[key] = LayerEnv + ":" + name
+		delete(baseDirs, key)
+	}
+
+	keys := make([]string, 0, len(cm.overrides))
+	for key := range cm.overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := setConfigValue(config, key, cm.overrides[key]); err != nil {
+			return fmt.Errorf("invalid --config override: %w", err)
+		}
+		origins[key] = LayerFlag + ":--config"
+		delete(baseDirs, key)
+	}
+
+	// Пути раскрываются только в итоговой конфигурации, в файлах остается исходная запись
+	if err := normalizeConfigPaths(config, baseDirs); err != nil {
+		return err
+	}
+
+	cm.config = config
+	cm.origins = origins
+	return nil
+}
+
+// userLayer возвращает слой пользовательской конфигурации, в который записываются изменения
+func (cm *ConfigManager) userLayer() *configLayer {
+	for _, layer := range cm.layers {
+		if layer.name == LayerUser {
+			return layer
+		}
+	}
+
+	layer := &configLayer{name: LayerUser, path: cm.configPath, data: make(map[string]interface{}), migratedFrom: -1}
+	cm.layers = append(cm.layers, layer)
+	return layer
+}
+
+// userRepositories возвращает репозитории пользовательской конфигурации
+func (cm *ConfigManager) userRepositories() ([]Repository, error) {
+	repositories, _, err := cm.userLayer().repositories()
+	return repositories, err
+}
+
+// saveUserRepositories сохраняет репозитории пользовательской конфигурации
+func (cm *ConfigManager) saveUserRepositories(repositories []Repository) error {
+	cm.userLayer().setRepositories(repositories)
+	return cm.saveConfig()
+}
+
+// ListOrigins возвращает источник каждого значения конфигурации: "default",
+// "system:<path>", "user:<path>", "project:<path>", "env:<VAR>" или "flag:--config"
+func (cm *ConfigManager) ListOrigins() map[string]string {
+	origins := make(map[string]string, len(cm.origins))
+	for key, origin := range cm.origins {
+		origins[key] = origin
+	}
+	return origins
+}
+
+// Origin возвращает источник значения ключа конфигурации
+func (cm *ConfigManager) Origin(key string) string {
+	return cm.origins[key]
+}
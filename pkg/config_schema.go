@@ -0,0 +1,477 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion версия формата файла конфигурации
+const CurrentConfigVersion = 1
+
+// FieldError ошибка значения поля файла конфигурации
+type FieldError struct {
+	Field   string `json:"field"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ConfigValidationError файл конфигурации не соответствует схеме
+type ConfigValidationError struct {
+	Path   string
+	Errors []FieldError
+}
+
+func (e *ConfigValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors)+1)
+	lines = append(lines, fmt.Sprintf("invalid config %s:", e.Path))
+	for _, fieldErr := range e.Errors {
+		lines = append(lines, "  "+fieldErr.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// configMigration переводит данные файла конфигурации из версии from в from+1
+type configMigration struct {
+	from        int
+	description string
+	migrate     func(data map[string]interface{}) error
+}
+
+// configMigrations миграции в порядке версий
+var configMigrations = []configMigration{
+	{
+		from:        0,
+		description: "add config_version and drop empty settings",
+		migrate: func(data map[string]interface{}) error {
+			if settings, ok := data["settings"].(map[string]interface{}); data["settings"] == nil || (ok && len(settings) == 0) {
+				delete(data, "settings")
+			}
+			return nil
+		},
+	},
+}
+
+// fieldValidator проверяет значение поля
+type fieldValidator func(node *yaml.Node, field string) []FieldError
+
+// configSchema схема файла конфигурации
+var configSchema = map[string]fieldValidator{
+	"config_version":    intField(0, CurrentConfigVersion),
+	"global_path":       pathField,
+	"local_path":        pathField,
+	"cache_path":        pathField,
+	"temp_path":         pathField,
+	"parallel":          intField(1, 64),
+	"timeout":           intField(1, -1),
+	"retry_count":       intField(0, -1),
+	"auto_update":       boolField,
+	"verify_hashes":     boolField,
+	"credential_helper": stringField,
+	"compression": mappingField(map[string]fieldValidator{
+		"format": enumField("tar.zst", "tar.lz4", "tar.xz", "tar.gz", "zip"),
+		"level":  intField(1, 9),
+	}, nil),
+	"network": mappingField(map[string]fieldValidator{
+		"proxy":            proxyField,
+		"no_proxy":         stringField,
+		"ca_bundle":        optionalPathField,
+		"client_cert":      optionalPathField,
+		"client_key":       optionalPathField,
+		"connect_timeout":  intField(1, -1),
+		"download_timeout": intField(0, -1),
+		"max_connections":  intField(1, 256),
+		"user_agent":       stringField,
+	}, nil),
+	"rate_limit": mappingField(map[string]fieldValidator{
+		"requests_per_second": numberField(0),
+		"burst":               intField(1, -1),
+		"repositories":        mappingField(nil, mappingField(rateLimitSchema, nil)),
+	}, nil),
+	"install_policy": mappingField(map[string]fieldValidator{
+		"require_provenance": boolField,
+		"require_clean_tree": boolField,
+	}, nil),
+	"repositories": repositoriesField,
+	"settings":     settingsField,
+}
+
+// rateLimitSchema схема ограничения частоты запросов репозитория
+var rateLimitSchema = map[string]fieldValidator{
+	"requests_per_second": numberField(0),
+	"burst":               intField(1, -1),
+}
+
+// repositorySchema схема записи репозитория
+var repositorySchema = map[string]fieldValidator{
+	"name":      stringField,
+	"url":       urlField,
+	"priority":  intField(-1<<31, 1<<31-1),
+	"enabled":   boolField,
+	"authToken": stringField,
+	"username":  stringField,
+	"password":  stringField,
+}
+
+// ValidateConfigFile проверяет файл конфигурации и возвращает ошибки полей.
+// Ошибка возвращается, если файл не удалось прочитать или разобрать как YAML
+func ValidateConfigFile(path string) ([]FieldError, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	_, fieldErrors, err := parseConfigData(path, content)
+	if err != nil {
+		return nil, err
+	}
+	return fieldErrors, nil
+}
+
+// parsedConfig данные файла конфигурации после миграций
+type parsedConfig struct {
+	data map[string]interface{}
+	// migratedFrom исходная версия, если файл был мигрирован, иначе -1
+	migratedFrom int
+}
+
+// parseConfigData разбирает содержимое файла конфигурации, применяет миграции
+// и проверяет схему
+func parseConfigData(path string, content []byte) (*parsedConfig, []FieldError, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	parsed := &parsedConfig{data: make(map[string]interface{}), migratedFrom: -1}
+	if len(document.Content) == 0 {
+		// Пустой файл
+		return parsed, nil, nil
+	}
+
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, []FieldError{{Field: "(root)", Line: root.Line, Message: "expected a mapping"}}, nil
+	}
+
+	version := 0
+	if node := mappingValue(root, "config_version"); node != nil {
+		if errs := intField(0, -1)(node, "config_version"); len(errs) > 0 {
+			return nil, errs, nil
+		}
+		version, _ = strconv.Atoi(node.Value)
+	}
+	if version > CurrentConfigVersion {
+		return nil, nil, fmt.Errorf("config %s has version %d, but this criage supports up to %d; upgrade criage", path, version, CurrentConfigVersion)
+	}
+
+	if version < CurrentConfigVersion {
+		var data map[string]interface{}
+		if err := root.Decode(&data); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+		if err := migrateConfigData(data, version); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate config %s: %w", path, err)
+		}
+
+		var migrated yaml.Node
+		if err := migrated.Encode(data); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate config %s: %w", path, err)
+		}
+		root = &migrated
+		parsed.migratedFrom = version
+	}
+
+	if fieldErrors := validateMapping(root, "", configSchema, nil); len(fieldErrors) > 0 {
+		return nil, fieldErrors, nil
+	}
+
+	if err := root.Decode(&parsed.data); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return parsed, nil, nil
+}
+
+// migrateConfigData последовательно применяет миграции начиная с версии version
+func migrateConfigData(data map[string]interface{}, version int) error {
+	for version < CurrentConfigVersion {
+		applied := false
+		for _, migration := range configMigrations {
+			if migration.from != version {
+				continue
+			}
+			if err := migration.migrate(data); err != nil {
+				return fmt.Errorf("migration %d -> %d (%s): %w", version, version+1, migration.description, err)
+			}
+			applied = true
+			break
+		}
+		if !applied {
+			return fmt.Errorf("no migration from config version %d", version)
+		}
+		version++
+	}
+
+	data["config_version"] = CurrentConfigVersion
+	return nil
+}
+
+// backupConfigFile сохраняет копию файла конфигурации рядом с ним и возвращает путь копии
+func backupConfigFile(path string, content []byte, suffix string) (string, error) {
+	backupPath := fmt.Sprintf("%s.%s.bak", path, suffix)
+	if err := os.WriteFile(backupPath, content, 0600); err != nil {
+		return "", fmt.Errorf("failed to back up config %s: %w", path, err)
+	}
+	return backupPath, nil
+}
+
+// writeFileAtomic записывает файл через временный файл и переименование,
+// чтобы прерванная запись не повредила существующий файл
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tempPath := temp.Name()
+
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(tempPath)
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	if err := os.Chmod(tempPath, perm); err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+
+	return os.Rename(tempPath, path)
+}
+
+// mappingValue возвращает значение ключа узла-отображения
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// validateMapping проверяет поля отображения по схеме. Неизвестные поля считаются ошибкой,
+// если не задан validateUnknown
+func validateMapping(node *yaml.Node, prefix string, schema map[string]fieldValidator, validateUnknown fieldValidator) []FieldError {
+	if node.Kind != yaml.MappingNode {
+		return []FieldError{{Field: strings.TrimSuffix(prefix, "."), Line: node.Line, Message: "expected a mapping"}}
+	}
+
+	var errs []FieldError
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		field := prefix + keyNode.Value
+
+		if seen[keyNode.Value] {
+			errs = append(errs, FieldError{Field: field, Line: keyNode.Line, Message: "duplicate field"})
+			continue
+		}
+		seen[keyNode.Value] = true
+
+		validator, known := schema[keyNode.Value]
+		if !known {
+			validator = validateUnknown
+		}
+		if validator == nil {
+			errs = append(errs, FieldError{Field: field, Line: keyNode.Line, Message: "unknown field"})
+			continue
+		}
+		errs = append(errs, validator(valueNode, field)...)
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		return errs[i].Line < errs[j].Line
+	})
+	return errs
+}
+
+// mappingField проверяет вложенное отображение
+func mappingField(schema map[string]fieldValidator, validateUnknown fieldValidator) fieldValidator {
+	return func(node *yaml.Node, field string) []FieldError {
+		return validateMapping(node, field+".", schema, validateUnknown)
+	}
+}
+
+// stringField проверяет строковое значение
+func stringField(node *yaml.Node, field string) []FieldError {
+	if node.Kind != yaml.ScalarNode || node.Tag != "!!str" {
+		return []FieldError{{Field: field, Line: node.Line, Message: "expected a string"}}
+	}
+	return nil
+}
+
+// pathField проверяет путь: непустая строка с определенными переменными окружения
+func pathField(node *yaml.Node, field string) []FieldError {
+	if errs := stringField(node, field); errs != nil {
+		return errs
+	}
+	if strings.TrimSpace(node.Value) == "" {
+		return []FieldError{{Field: field, Line: node.Line, Message: "must not be empty"}}
+	}
+	if _, err := ExpandPath(node.Value, ""); err != nil {
+		return []FieldError{{Field: field, Line: node.Line, Message: err.Error()}}
+	}
+	return nil
+}
+
+// optionalPathField проверяет необязательный путь: пустая строка допустима
+func optionalPathField(node *yaml.Node, field string) []FieldError {
+	if errs := stringField(node, field); errs != nil || node.Value == "" {
+		return errs
+	}
+	return pathField(node, field)
+}
+
+// proxyField проверяет адрес прокси
+func proxyField(node *yaml.Node, field string) []FieldError {
+	if errs := stringField(node, field); errs != nil || node.Value == "" {
+		return errs
+	}
+	if _, err := parseProxyURL(node.Value); err != nil {
+		return []FieldError{{Field: field, Line: node.Line, Message: err.Error()}}
+	}
+	return nil
+}
+
+// urlField проверяет адрес репозитория
+func urlField(node *yaml.Node, field string) []FieldError {
+	if errs := stringField(node, field); errs != nil {
+		return errs
+	}
+	if !strings.HasPrefix(node.Value, "http://") && !strings.HasPrefix(node.Value, "https://") {
+		return []FieldError{{Field: field, Line: node.Line, Message: fmt.Sprintf("invalid URL %q (expected http:// or https://)", node.Value)}}
+	}
+	return nil
+}
+
+// boolField проверяет логическое значение
+func boolField(node *yaml.Node, field string) []FieldError {
+	if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+		return []FieldError{{Field: field, Line: node.Line, Message: "expected true or false"}}
+	}
+	return nil
+}
+
+// intField проверяет целое число в диапазоне [min, max]; max < min означает отсутствие верхней границы
+func intField(min, max int) fieldValidator {
+	return func(node *yaml.Node, field string) []FieldError {
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return []FieldError{{Field: field, Line: node.Line, Message: "expected an integer"}}
+		}
+		value, err := strconv.Atoi(node.Value)
+		if err != nil {
+			return []FieldError{{Field: field, Line: node.Line, Message: "expected an integer"}}
+		}
+		if value < min || (max >= min && value > max) {
+			message := fmt.Sprintf("must be at least %d", min)
+			if max >= min {
+				message = fmt.Sprintf("must be between %d and %d", min, max)
+			}
+			return []FieldError{{Field: field, Line: node.Line, Message: message}}
+		}
+		return nil
+	}
+}
+
+// numberField проверяет число (целое или дробное) не меньше min
+func numberField(min float64) fieldValidator {
+	return func(node *yaml.Node, field string) []FieldError {
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			return []FieldError{{Field: field, Line: node.Line, Message: "expected a number"}}
+		}
+		value, err := strconv.ParseFloat(node.Value, 64)
+		if err != nil {
+			return []FieldError{{Field: field, Line: node.Line, Message: "expected a number"}}
+		}
+		if value < min {
+			return []FieldError{{Field: field, Line: node.Line, Message: fmt.Sprintf("must be at least %g", min)}}
+		}
+		return nil
+	}
+}
+
+// enumField проверяет, что строка входит в список допустимых значений
+func enumField(values ...string) fieldValidator {
+	return func(node *yaml.Node, field string) []FieldError {
+		if errs := stringField(node, field); errs != nil {
+			return errs
+		}
+		for _, value := range values {
+			if node.Value == value {
+				return nil
+			}
+		}
+		return []FieldError{{Field: field, Line: node.Line, Message: fmt.Sprintf("invalid value %q (expected one of: %s)", node.Value, strings.Join(values, ", "))}}
+	}
+}
+
+// settingsField проверяет произвольные настройки: значения должны быть скалярами
+func settingsField(node *yaml.Node, field string) []FieldError {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	return validateMapping(node, field+".", nil, func(value *yaml.Node, name string) []FieldError {
+		if value.Kind != yaml.ScalarNode {
+			return []FieldError{{Field: name, Line: value.Line, Message: "expected a scalar value"}}
+		}
+		return nil
+	})
+}
+
+// repositoriesField проверяет список репозиториев
+func repositoriesField(node *yaml.Node, field string) []FieldError {
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+	if node.Kind != yaml.SequenceNode {
+		return []FieldError{{Field: field, Line: node.Line, Message: "expected a list"}}
+	}
+
+	var errs []FieldError
+	names := make(map[string]bool)
+	for i, item := range node.Content {
+		itemField := fmt.Sprintf("%s[%d]", field, i)
+		errs = append(errs, validateMapping(item, itemField+".", repositorySchema, nil)...)
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+
+		name := mappingValue(item, "name")
+		switch {
+		case name == nil || name.Value == "":
+			errs = append(errs, FieldError{Field: itemField + ".name", Line: item.Line, Message: "required"})
+		case names[name.Value]:
+			errs = append(errs, FieldError{Field: itemField + ".name", Line: name.Line, Message: fmt.Sprintf("duplicate repository %q", name.Value)})
+		default:
+			names[name.Value] = true
+		}
+		if mappingValue(item, "url") == nil {
+			errs = append(errs, FieldError{Field: itemField + ".url", Line: item.Line, Message: "required"})
+		}
+	}
+	return errs
+}
@@ -0,0 +1,254 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSetConfigValue проверяет проверку типов и неизвестные ключи
+func TestSetConfigValue(t *testing.T) {
+	tests := []struct {
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{"parallel", "8", false},
+		{"parallel", "0", true},
+		{"parallel", "many", true},
+		{"timeout", "-1", true},
+		{"retry_count", "0", false},
+		{"auto_update", "yes", true},
+		{"verify_hashes", "false", false},
+		{"compression.format", "tar.gz", false},
+		{"compression.format", "rar", true},
+		{"compression.level", "best", false},
+		{"compression.level", "42", true},
+		{"cache_path", "", true},
+		{"settings.editor", "vim", false},
+		{"settings.", "x", true},
+		{"unknown", "x", true},
+		{"credential_helper", "pass", false},
+		{"credential_helper", "/usr/local/bin/helper", false},
+		{"credential_helper", "./helper", true},
+	}
+
+	for _, tt := range tests {
+		config := DefaultConfig()
+		err := setConfigValue(config, tt.key, tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("setConfigValue(%q, %q) error = %v, wantErr %v", tt.key, tt.value, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		got, err := configValue(config, tt.key)
+		if err != nil {
+			t.Errorf("configValue(%q) failed: %v", tt.key, err)
+		}
+		if tt.key == "compression.level" {
+			if config.Compression.Level != CompressionBest {
+				t.Errorf("expected compression level %d, got %d", CompressionBest, config.Compression.Level)
+			}
+		} else if got != tt.value {
+			t.Errorf("configValue(%q) = %q, expected %q", tt.key, got, tt.value)
+		}
+	}
+}
+
+// TestConfigLayers проверяет порядок слоев конфигурации и источники значений
+func TestConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("CRIAGE_TIMEOUT", "15")
+
+	files := map[string]string{
+		"system.yaml":  "parallel: 2\ntimeout: 10\nrepositories:\n  - name: corp\n    url: https://corp.example.com\n    priority: 50\n    enabled: true\n",
+		"user.yaml":    "parallel: 3\nretry_count: 1\nrepositories:\n  - name: mine\n    url: https://mine.example.com\n    priority: 5\n    enabled: true\n",
+		"project.yaml": "retry_count: 7\nsettings:\n  editor: vim\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cm := &ConfigManager{
+		configPath: filepath.Join(dir, "user.yaml"),
+		overrides:  map[string]string{"compression.level": "best"},
+	}
+	for _, layer := range []struct{ name, file string }{
+		{LayerSystem, "system.yaml"},
+		{LayerUser, "user.yaml"},
+		{LayerProject, "project.yaml"},
+	} {
+		loaded, err := loadConfigLayer(layer.name, filepath.Join(dir, layer.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cm.layers = append(cm.layers, loaded)
+	}
+	if err := cm.rebuild(); err != nil {
+		t.Fatalf("rebuild failed: %v", err)
+	}
+
+	config := cm.GetConfig()
+	if config.Parallel != 3 || config.RetryCount != 7 || config.Timeout != 15 || config.Compression.Level != CompressionBest {
+		t.Errorf("unexpected layered values: parallel=%d retry_count=%d timeout=%d level=%d",
+			config.Parallel, config.RetryCount, config.Timeout, config.Compression.Level)
+	}
+	if len(config.Repositories) != 2 {
+		t.Errorf("expected repositories from system and user layers to be merged, got %+v", config.Repositories)
+	}
+
+	expectedOrigins := map[string]string{
+		"parallel":          "user:" + filepath.Join(dir, "user.yaml"),
+		"retry_count":       "project:" + filepath.Join(dir, "project.yaml"),
+		"timeout":           "env:CRIAGE_TIMEOUT",
+		"compression.level": "flag:--config",
+		"verify_hashes":     LayerDefault,
+		"settings.editor":   "project:" + filepath.Join(dir, "project.yaml"),
+		"repositories.corp": "system:" + filepath.Join(dir, "system.yaml"),
+	}
+	for key, expected := range expectedOrigins {
+		if origin := cm.Origin(key); origin != expected {
+			t.Errorf("origin of %s: expected %s, got %s", key, expected, origin)
+		}
+	}
+
+	// Изменения записываются только в пользовательский слой
+	if err := cm.SetValue("parallel", "5"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "user.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "timeout") || strings.Contains(string(data), "corp") {
+		t.Errorf("user config must not contain values from other layers:\n%s", data)
+	}
+	if cm.GetConfig().Parallel != 5 {
+		t.Errorf("expected parallel 5 after set, got %d", cm.GetConfig().Parallel)
+	}
+}
+
+// TestProjectConfigRestrictions проверяет, что конфигурация проекта не может задать
+// помощник учетных данных, политику установки и адрес уже заданного репозитория
+func TestProjectConfigRestrictions(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	tests := []struct {
+		name    string
+		project string
+		wantErr bool
+	}{
+		{"helper", "credential_helper: /tmp/helper\n", true},
+		{"policy", "install_policy:\n  require_provenance: false\n", true},
+		{"repository url", "repositories:\n  - name: corp\n    url: https://evil.example.com\n    enabled: true\n", true},
+		{"repository priority", "repositories:\n  - name: corp\n    url: https://corp.example.com/\n    priority: 1\n    enabled: true\n", false},
+		{"new repository", "repositories:\n  - name: team\n    url: https://team.example.com\n    enabled: true\n", false},
+	}
+
+	system := filepath.Join(dir, "system.yaml")
+	if err := os.WriteFile(system, []byte("repositories:\n  - name: corp\n    url: https://corp.example.com\n    enabled: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		project := filepath.Join(dir, "project.yaml")
+		if err := os.WriteFile(project, []byte(tt.project), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cm := &ConfigManager{configPath: filepath.Join(dir, "user.yaml")}
+		for _, layer := range []struct{ name, path string }{{LayerSystem, system}, {LayerProject, project}} {
+			loaded, err := loadConfigLayer(layer.name, layer.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cm.layers = append(cm.layers, loaded)
+		}
+		if err := cm.rebuild(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: rebuild error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// TestExpandPath проверяет раскрытие ~, переменных окружения и относительных путей
+func TestExpandPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("CRIAGE_TEST_DIR", "/srv/criage")
+
+	tests := []struct {
+		path     string
+		base     string
+		expected string
+		wantErr  bool
+	}{
+		{"~", "", home, false},
+		{"~/.cache/criage", "", filepath.Join(home, ".cache/criage"), false},
+		{"$CRIAGE_TEST_DIR/cache", "", "/srv/criage/cache", false},
+		{"${CRIAGE_TEST_DIR}/../tmp", "", "/srv/tmp", false},
+		{"./criage_modules", "/project", "/project/criage_modules", false},
+		{"/abs/path/", "/project", "/abs/path", false},
+		{"$CRIAGE_UNDEFINED_VAR/x", "", "", true},
+		{"~other/x", "", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ExpandPath(tt.path, tt.base)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ExpandPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ExpandPath(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+// TestParseConfigData проверяет ошибки схемы, миграцию и отказ от более новой версии
+func TestParseConfigData(t *testing.T) {
+	content := "config_version: 1\nparallel: 0\nfoo: bar\nrepositories:\n  - url: ftp://example.com\n"
+	_, fieldErrors, err := parseConfigData("config.yaml", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"line 2: parallel: must be between 1 and 64",
+		"line 3: foo: unknown field",
+		`line 5: repositories[0].url: invalid URL "ftp://example.com" (expected http:// or https://)`,
+		"line 5: repositories[0].name: required",
+	}
+	if len(fieldErrors) != len(expected) {
+		t.Fatalf("expected %d field errors, got %v", len(expected), fieldErrors)
+	}
+	for i, fieldErr := range fieldErrors {
+		if fieldErr.String() != expected[i] {
+			t.Errorf("field error %d: expected %q, got %q", i, expected[i], fieldErr.String())
+		}
+	}
+
+	parsed, fieldErrors, err := parseConfigData("config.yaml", []byte("parallel: 4\nsettings: {}\n"))
+	if err != nil || len(fieldErrors) > 0 {
+		t.Fatalf("unexpected errors: %v %v", err, fieldErrors)
+	}
+	if parsed.migratedFrom != 0 || parsed.data["config_version"] != CurrentConfigVersion {
+		t.Errorf("expected migration from version 0, got %d %v", parsed.migratedFrom, parsed.data)
+	}
+	if _, exists := parsed.data["settings"]; exists {
+		t.Errorf("expected empty settings to be dropped, got %v", parsed.data)
+	}
+
+	if _, _, err := parseConfigData("config.yaml", []byte("config_version: 99\n")); err == nil {
+		t.Error("expected error for newer config version")
+	}
+	if _, _, err := parseConfigData("config.yaml", []byte("parallel: [\n")); err == nil {
+		t.Error("expected error for malformed YAML")
+	}
+}
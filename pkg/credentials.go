@@ -0,0 +1,354 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// CredentialsFileName файл учетных данных репозиториев, доступный только владельцу
+	CredentialsFileName = "credentials.yaml"
+	// CredentialHelperPrefix префикс исполняемого файла внешнего хранилища учетных данных:
+	// credential_helper: pass -> criage-credential-pass
+	CredentialHelperPrefix = "criage-credential-"
+)
+
+// Credential учетные данные репозитория
+type Credential struct {
+	Token    string `yaml:"token,omitempty" json:"token,omitempty"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	// URL адрес репозитория на момент criage login; учетные данные не передаются
+	// репозиторию с тем же именем, но другим адресом
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+}
+
+// Empty возвращает true, если учетные данные не заданы
+func (c Credential) Empty() bool {
+	return c.Token == "" && c.Username == ""
+}
+
+// CredentialStore хранилище учетных данных репозиториев.
+// Get возвращает nil без ошибки, если для репозитория ничего не сохранено
+type CredentialStore interface {
+	Get(ctx context.Context, repo Repository) (*Credential, error)
+	Store(ctx context.Context, repo Repository, credential Credential) error
+	Erase(ctx context.Context, repo Repository) error
+}
+
+// NewCredentialStore возвращает хранилище учетных данных: внешний helper,
+// если он задан, иначе файл credentials.yaml рядом с пользовательской конфигурацией
+func NewCredentialStore(helper string) (CredentialStore, error) {
+	if helper != "" {
+		return &helperCredentialStore{command: credentialHelperCommand(helper)}, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return &fileCredentialStore{path: filepath.Join(homeDir, DefaultConfigDir, CredentialsFileName)}, nil
+}
+
+// credentialHelperCommand возвращает исполняемый файл helper: имя без пути дополняется префиксом
+func credentialHelperCommand(helper string) string {
+	if strings.ContainsRune(helper, filepath.Separator) {
+		return helper
+	}
+	return CredentialHelperPrefix + helper
+}
+
+// fileCredentialStore хранит учетные данные в YAML-файле с правами 0600, ключ - имя
+// репозитория. Вместе с учетными данными сохраняется адрес репозитория
+type fileCredentialStore struct {
+	path string
+}
+
+// load читает файл учетных данных. Файл с правами шире 0600 не читается
+func (s *fileCredentialStore) load() (map[string]Credential, error) {
+	credentials := make(map[string]Credential)
+
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		return credentials, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials %s: %w", s.path, err)
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("credentials file %s is accessible by other users (mode %04o); run chmod 600 %s", s.path, info.Mode().Perm(), s.path)
+	}
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials %s: %w", s.path, err)
+	}
+	if err := yaml.Unmarshal(data, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials %s: %w", s.path, err)
+	}
+	if credentials == nil {
+		credentials = make(map[string]Credential)
+	}
+	return credentials, nil
+}
+
+// save записывает файл учетных данных с правами 0600
+func (s *fileCredentialStore) save(credentials map[string]Credential) error {
+	data, err := yaml.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("failed to marshal credentials: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	if err := writeFileAtomic(s.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	return nil
+}
+
+func (s *fileCredentialStore) Get(ctx context.Context, repo Repository) (*Credential, error) {
+	credentials, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	credential, exists := credentials[repo.Name]
+	if !exists {
+		return nil, nil
+	}
+	if normalizeRepositoryURL(credential.URL) != normalizeRepositoryURL(repo.URL) {
+		fmt.Fprintf(os.Stderr, "Предупреждение: учетные данные %s сохранены для %s, а не %s; выполните criage login %s\n",
+			repo.Name, credential.URL, repo.URL, repo.Name)
+		return nil, nil
+	}
+	return &credential, nil
+}
+
+func (s *fileCredentialStore) Store(ctx context.Context, repo Repository, credential Credential) error {
+	credentials, err := s.load()
+	if err != nil {
+		return err
+	}
+	credential.URL = repo.URL
+	credentials[repo.Name] = credential
+	return s.save(credentials)
+}
+
+func (s *fileCredentialStore) Erase(ctx context.Context, repo Repository) error {
+	credentials, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, exists := credentials[repo.Name]; !exists {
+		return nil
+	}
+	delete(credentials, repo.Name)
+	return s.save(credentials)
+}
+
+// normalizeRepositoryURL приводит адрес репозитория к виду для сравнения: схема и
+// хост в нижнем регистре, без завершающего /
+func normalizeRepositoryURL(rawURL string) string {
+	rawURL = strings.TrimRight(strings.TrimSpace(rawURL), "/")
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Host == "" {
+		return rawURL
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	return parsed.String()
+}
+
+// helperCredentialStore обращается к внешнему исполняемому файлу.
+// Протокол: helper вызывается с аргументом get, store или erase и получает на stdin
+// строки key=value (repository, url, а для store также token, username, password),
+// завершенные пустой строкой. На get helper печатает строки key=value в том же формате;
+// пустой вывод означает отсутствие учетных данных
+type helperCredentialStore struct {
+	command string
+}
+
+// run вызывает helper с действием action
+func (s *helperCredentialStore) run(ctx context.Context, action string, fields map[string]string) ([]byte, error) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var input bytes.Buffer
+	for _, key := range keys {
+		if fields[key] != "" {
+			fmt.Fprintf(&input, "%s=%s\n", key, fields[key])
+		}
+	}
+	input.WriteString("\n")
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.command, action)
+	cmd.Stdin = &input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("credential helper %s not found in PATH", s.command)
+		}
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+		return nil, fmt.Errorf("credential helper %s %s failed: %s", s.command, action, message)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (s *helperCredentialStore) Get(ctx context.Context, repo Repository) (*Credential, error) {
+	output, err := s.run(ctx, "get", map[string]string{"repository": repo.Name, "url": repo.URL})
+	if err != nil {
+		return nil, err
+	}
+
+	var credential Credential
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "token":
+			credential.Token = value
+		case "username":
+			credential.Username = value
+		case "password":
+			credential.Password = value
+		}
+	}
+	if credential.Empty() {
+		return nil, nil
+	}
+	return &credential, nil
+}
+
+func (s *helperCredentialStore) Store(ctx context.Context, repo Repository, credential Credential) error {
+	_, err := s.run(ctx, "store", map[string]string{
+		"repository": repo.Name,
+		"url":        repo.URL,
+		"token":      credential.Token,
+		"username":   credential.Username,
+		"password":   credential.Password,
+	})
+	return err
+}
+
+func (s *helperCredentialStore) Erase(ctx context.Context, repo Repository) error {
+	_, err := s.run(ctx, "erase", map[string]string{"repository": repo.Name, "url": repo.URL})
+	return err
+}
+
+// sensitiveSettingWords части имен настроек, значения которых скрываются при выводе
+var sensitiveSettingWords = []string{"token", "password", "secret", "credential", "key"}
+
+// IsSensitiveKey возвращает true, если значение ключа конфигурации похоже на секрет
+func IsSensitiveKey(key string) bool {
+	name, ok := settingName(key)
+	if !ok {
+		return false
+	}
+	name = strings.ToLower(name)
+	for _, word := range sensitiveSettingWords {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskSecret скрывает значение секрета при выводе
+func MaskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return "****"
+}
+
+// credentialStore возвращает хранилище учетных данных согласно конфигурации
+func (pm *PackageManager) credentialStore() (CredentialStore, error) {
+	return NewCredentialStore(pm.configManager.GetConfig().CredentialHelper)
+}
+
+// storedCredential возвращает сохраненные учетные данные репозитория или nil
+func (pm *PackageManager) storedCredential(ctx context.Context, repo Repository) (*Credential, error) {
+	store, err := pm.credentialStore()
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(ctx, repo)
+}
+
+// HasCredentials возвращает true, если для репозитория заданы учетные данные
+// в конфигурации или в хранилище
+func (pm *PackageManager) HasCredentials(ctx context.Context, repo Repository) (bool, error) {
+	if repo.AuthToken != "" || repo.Username != "" {
+		return true, nil
+	}
+	credential, err := pm.storedCredential(ctx, repo)
+	return credential != nil, err
+}
+
+// Login сохраняет учетные данные репозитория в хранилище. Учетные данные,
+// ранее записанные открытым текстом в пользовательской конфигурации, удаляются из нее
+func (pm *PackageManager) Login(ctx context.Context, name string, credential Credential) error {
+	repo, exists := pm.configManager.GetRepository(name)
+	if !exists {
+		return fmt.Errorf("repository not found: %s", name)
+	}
+	if credential.Empty() {
+		return fmt.Errorf("token or username is required")
+	}
+
+	store, err := pm.credentialStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Store(ctx, *repo, credential); err != nil {
+		return err
+	}
+
+	_, err = pm.configManager.ClearRepositoryCredentials(name)
+	return err
+}
+
+// Logout удаляет учетные данные репозитория из хранилища и пользовательской конфигурации
+func (pm *PackageManager) Logout(ctx context.Context, name string) error {
+	repo, exists := pm.configManager.GetRepository(name)
+	if !exists {
+		return fmt.Errorf("repository not found: %s", name)
+	}
+
+	store, err := pm.credentialStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Erase(ctx, *repo); err != nil {
+		return err
+	}
+
+	_, err = pm.configManager.ClearRepositoryCredentials(name)
+	return err
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestFileCredentialStore проверяет сохранение, права файла и удаление учетных данных
+func TestFileCredentialStore(t *testing.T) {
+	store := &fileCredentialStore{path: filepath.Join(t.TempDir(), CredentialsFileName)}
+	repo := Repository{Name: "corp", URL: "https://corp.example.com"}
+
+	if credential, err := store.Get(context.Background(), repo); err != nil || credential != nil {
+		t.Fatalf("expected no credentials, got %v, %v", credential, err)
+	}
+
+	if err := store.Store(context.Background(), repo, Credential{Token: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(store.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %04o", info.Mode().Perm())
+	}
+
+	credential, err := store.Get(context.Background(), repo)
+	if err != nil || credential == nil || credential.Token != "secret" {
+		t.Fatalf("expected stored token, got %v, %v", credential, err)
+	}
+
+	// Репозиторий с тем же именем, но другим адресом не получает учетные данные
+	if credential, err := store.Get(context.Background(), Repository{Name: "corp", URL: "https://evil.example.com"}); err != nil || credential != nil {
+		t.Errorf("expected no credentials for another URL, got %v, %v", credential, err)
+	}
+	if credential, err := store.Get(context.Background(), Repository{Name: "corp", URL: "HTTPS://Corp.example.com/"}); err != nil || credential == nil {
+		t.Errorf("expected credentials for the same URL, got %v, %v", credential, err)
+	}
+
+	if err := os.Chmod(store.path, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(context.Background(), repo); err == nil {
+		t.Error("expected error for credentials file readable by others")
+	}
+	if err := os.Chmod(store.path, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Erase(context.Background(), repo); err != nil {
+		t.Fatal(err)
+	}
+	if credential, err := store.Get(context.Background(), repo); err != nil || credential != nil {
+		t.Errorf("expected credentials to be erased, got %v, %v", credential, err)
+	}
+}
+
+// TestIsSensitiveKey проверяет определение секретов среди настроек
+func TestIsSensitiveKey(t *testing.T) {
+	tests := map[string]bool{
+		"settings.api_token":     true,
+		"settings.DB_PASSWORD":   true,
+		"settings.editor":        false,
+		"timeout":                false,
+		"credential_helper":      false,
+		"settings.client_secret": true,
+	}
+	for key, expected := range tests {
+		if IsSensitiveKey(key) != expected {
+			t.Errorf("IsSensitiveKey(%q) = %t, expected %t", key, !expected, expected)
+		}
+	}
+}
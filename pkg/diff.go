@@ -0,0 +1,328 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ChangeKind тип изменения значения
+type ChangeKind string
+
+const (
+	ChangeAdded   ChangeKind = "added"
+	ChangeRemoved ChangeKind = "removed"
+	ChangeChanged ChangeKind = "changed"
+)
+
+// ValueChange изменение одного элемента манифеста между версиями
+type ValueChange struct {
+	Kind ChangeKind `json:"kind"`
+	Key  string     `json:"key"`
+	From string     `json:"from,omitempty"`
+	To   string     `json:"to,omitempty"`
+}
+
+// PackageDiff различия манифестов двух версий пакета
+type PackageDiff struct {
+	Name            string        `json:"name"`
+	FromVersion     string        `json:"from_version"`
+	ToVersion       string        `json:"to_version"`
+	FromSource      string        `json:"from_source"`
+	ToSource        string        `json:"to_source"`
+	Dependencies    []ValueChange `json:"dependencies"`
+	DevDependencies []ValueChange `json:"dev_dependencies"`
+	Hooks           []ValueChange `json:"hooks"`
+	Scripts         []ValueChange `json:"scripts"`
+	Files           []ValueChange `json:"files"`
+	Platforms       []ValueChange `json:"platforms"`
+	// Complete false, если для одной из версий доступны только данные репозитория
+	// и хуки, скрипты и файлы сравнить невозможно
+	Complete  bool   `json:"complete"`
+	Changelog string `json:"changelog,omitempty"`
+}
+
+// IsEmpty возвращает true, если различий не найдено
+func (d *PackageDiff) IsEmpty() bool {
+	return len(d.Dependencies) == 0 && len(d.DevDependencies) == 0 && len(d.Hooks) == 0 &&
+		len(d.Scripts) == 0 && len(d.Files) == 0 && len(d.Platforms) == 0
+}
+
+// manifestSnapshot данные манифеста одной версии пакета
+type manifestSnapshot struct {
+	source       string
+	dependencies map[string]string
+	devDeps      map[string]string
+	hooks        map[string]string
+	scripts      map[string]string
+	files        []string
+	platforms    []string
+	full         bool
+	changelog    string
+}
+
+// DiffPackage сравнивает две версии пакета. Пустая fromVersion означает установленную
+// версию, пустая toVersion - последнюю версию в репозитории. Хуки, скрипты и файлы
+// берутся из встроенных метаданных архива (кеш или fetch=true) или установленного пакета
+func (pm *PackageManager) DiffPackage(ctx context.Context, packageName, fromVersion, toVersion string, fetch bool) (*PackageDiff, error) {
+	installed, isInstalled := pm.getInstalledPackage(packageName)
+	if fromVersion == "" {
+		if !isInstalled {
+			return nil, fmt.Errorf("package not installed: %s (specify the version to compare)", packageName)
+		}
+		fromVersion = installed.Version
+	}
+
+	_, entry, err := pm.findPackageEntry(ctx, packageName)
+	if err != nil {
+		return nil, err
+	}
+
+	if toVersion == "" {
+		var versions []string
+		for _, v := range entry.Versions {
+			versions = append(versions, v.Version)
+		}
+		toVersion = MaxVersion(versions)
+		if toVersion == "" {
+			return nil, fmt.Errorf("no versions available for %s", packageName)
+		}
+	}
+
+	from, err := pm.loadSnapshot(ctx, packageName, fromVersion, entry, installed, fetch)
+	if err != nil {
+		return nil, err
+	}
+	to, err := pm.loadSnapshot(ctx, packageName, toVersion, entry, installed, fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &PackageDiff{
+		Name:            packageName,
+		FromVersion:     fromVersion,
+		ToVersion:       toVersion,
+		FromSource:      from.source,
+		ToSource:        to.source,
+		Dependencies:    diffMaps(from.dependencies, to.dependencies),
+		DevDependencies: diffMaps(from.devDeps, to.devDeps),
+		Platforms:       diffLists(from.platforms, to.platforms),
+		Complete:        from.full && to.full,
+	}
+
+	if diff.Complete {
+		diff.Hooks = diffMaps(from.hooks, to.hooks)
+		diff.Scripts = diffMaps(from.scripts, to.scripts)
+		diff.Files = diffLists(from.files, to.files)
+	}
+
+	// Новая версия содержит наиболее полный журнал изменений
+	changelog := to.changelog
+	if CompareVersions(fromVersion, toVersion) > 0 || changelog == "" {
+		if from.changelog != "" {
+			changelog = from.changelog
+		}
+	}
+	if changelog != "" {
+		diff.Changelog = ExtractChangelog(changelog, fromVersion, toVersion)
+	}
+
+	return diff, nil
+}
+
+// loadSnapshot собирает данные манифеста версии из установленного пакета,
+// архива в кеше или записи репозитория
+func (pm *PackageManager) loadSnapshot(ctx context.Context, packageName, version string, entry *PackageEntry, installed *PackageInfo, fetch bool) (*manifestSnapshot, error) {
+	snapshot := &manifestSnapshot{source: "repository"}
+
+	var versionEntry *VersionEntry
+	for i := range entry.Versions {
+		if entry.Versions[i].Version == version {
+			versionEntry = &entry.Versions[i]
+			break
+		}
+	}
+
+	if versionEntry != nil {
+		snapshot.dependencies = versionEntry.Dependencies
+		snapshot.devDeps = versionEntry.DevDeps
+		for _, file := range versionEntry.Files {
+			snapshot.platforms = append(snapshot.platforms, file.OS+"/"+file.Arch)
+		}
+	}
+
+	// Установленная версия: манифест в директории установки
+	if installed != nil && installed.Version == version && installed.InstallPath != "" {
+		if manifest, err := pm.loadManifestFromDir(installed.InstallPath); err == nil {
+			snapshot.applyManifest(manifest, "installed")
+			snapshot.changelog = readChangelog(installed.InstallPath, manifest)
+			return snapshot, nil
+		}
+	}
+
+	archivePath := pm.cachedArchivePath(packageName, version)
+	if _, err := os.Stat(archivePath); err != nil && fetch && versionEntry != nil {
+		resolved, err := pm.resolvePackage(ctx, packageName, version, runtime.GOARCH, runtime.GOOS)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pm.downloadPackage(ctx, resolved); err != nil {
+			return nil, err
+		}
+	}
+
+	// Архив в кеше: встроенные метаданные
+	if _, err := os.Stat(archivePath); err == nil {
+		format := pm.archiveManager.DetectFormat(archivePath)
+		metadata, err := pm.archiveManager.ExtractMetadataFromArchive(archivePath, format)
+		if err == nil && metadata.PackageManifest != nil {
+			snapshot.applyManifest(metadata.PackageManifest, "archive")
+			if changelogFile(metadata.PackageManifest) != "" {
+				if tempDir, err := pm.newWorkDir("diff_" + packageName); err == nil {
+					defer pm.removeWorkDir(tempDir)
+					if err := pm.archiveManager.ExtractArchive(archivePath, tempDir, format); err == nil {
+						snapshot.changelog = readChangelog(tempDir, metadata.PackageManifest)
+					}
+				}
+			}
+			return snapshot, nil
+		}
+	}
+
+	if versionEntry == nil {
+		return nil, fmt.Errorf("version %s not found", version)
+	}
+
+	return snapshot, nil
+}
+
+// applyManifest заполняет снимок данными манифеста
+func (s *manifestSnapshot) applyManifest(manifest *PackageManifest, source string) {
+	s.source = source
+	s.full = true
+	s.dependencies = manifest.Dependencies
+	s.devDeps = manifest.DevDeps
+	s.scripts = manifest.Scripts
+	s.files = manifest.Files
+	s.hooks = make(map[string]string)
+
+	if hooks := manifest.Hooks; hooks != nil {
+		stages := map[string][]string{
+			"preInstall":  hooks.PreInstall,
+			"postInstall": hooks.PostInstall,
+			"preRemove":   hooks.PreRemove,
+			"postRemove":  hooks.PostRemove,
+			"preUpdate":   hooks.PreUpdate,
+			"postUpdate":  hooks.PostUpdate,
+		}
+		for stage, commands := range stages {
+			if len(commands) > 0 {
+				s.hooks[stage] = strings.Join(commands, "; ")
+			}
+		}
+	}
+}
+
+// changelogFile возвращает путь к журналу изменений из metadata.changelog манифеста
+func changelogFile(manifest *PackageManifest) string {
+	if manifest == nil || manifest.Metadata == nil {
+		return ""
+	}
+	name, _ := manifest.Metadata["changelog"].(string)
+	return name
+}
+
+// readChangelog читает журнал изменений пакета из директории
+func readChangelog(dir string, manifest *PackageManifest) string {
+	name := changelogFile(manifest)
+	if name == "" {
+		return ""
+	}
+
+	path := filepath.Join(dir, filepath.Clean(name))
+	if !strings.HasPrefix(path, filepath.Clean(dir)+string(os.PathSeparator)) {
+		return ""
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// changelogHeading заголовок раздела версии: "## [1.2.0] - 2024-01-01", "## v1.2.0"
+var changelogHeading = regexp.MustCompile(`^#{1,3}\s*\[?v?(\d+(?:\.\d+){0,2}(?:-[0-9A-Za-z.-]+)?)\]?`)
+
+// ExtractChangelog возвращает разделы журнала изменений в формате Markdown для версий
+// в диапазоне (from, to]. Если from новее to, возвращаются разделы (to, from]
+func ExtractChangelog(changelog, from, to string) string {
+	lower, upper := from, to
+	if CompareVersions(lower, upper) > 0 {
+		lower, upper = upper, lower
+	}
+
+	var result []string
+	include := false
+	for _, line := range strings.Split(changelog, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "#") {
+			if matches := changelogHeading.FindStringSubmatch(trimmed); matches != nil {
+				version := matches[1]
+				include = CompareVersions(version, lower) > 0 && CompareVersions(version, upper) <= 0
+			} else if strings.HasPrefix(trimmed, "## ") || strings.HasPrefix(trimmed, "# ") {
+				// Разделы без версии (например, "Unreleased") не включаются
+				include = false
+			}
+		}
+
+		if include {
+			result = append(result, line)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(result, "\n"))
+}
+
+// diffMaps сравнивает два словаря и возвращает изменения, отсортированные по ключу
+func diffMaps(from, to map[string]string) []ValueChange {
+	var changes []ValueChange
+
+	for key, oldValue := range from {
+		newValue, exists := to[key]
+		switch {
+		case !exists:
+			changes = append(changes, ValueChange{Kind: ChangeRemoved, Key: key, From: oldValue})
+		case newValue != oldValue:
+			changes = append(changes, ValueChange{Kind: ChangeChanged, Key: key, From: oldValue, To: newValue})
+		}
+	}
+	for key, newValue := range to {
+		if _, exists := from[key]; !exists {
+			changes = append(changes, ValueChange{Kind: ChangeAdded, Key: key, To: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// diffLists сравнивает два списка как множества
+func diffLists(from, to []string) []ValueChange {
+	fromSet := make(map[string]string, len(from))
+	for _, item := range from {
+		fromSet[item] = item
+	}
+	toSet := make(map[string]string, len(to))
+	for _, item := range to {
+		toSet[item] = item
+	}
+	return diffMaps(fromSet, toSet)
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestExtractChangelog проверяет выбор разделов журнала изменений между версиями
+func TestExtractChangelog(t *testing.T) {
+	changelog := `# Changelog
+
+## [Unreleased]
+- work in progress
+
+## [2.0.0] - 2024-05-01
+### Changed
+- breaking change
+
+## v1.1.0
+- feature
+
+## 1.0.0
+- initial release
+`
+
+	got := ExtractChangelog(changelog, "1.0.0", "2.0.0")
+	for _, expected := range []string{"## [2.0.0]", "breaking change", "## v1.1.0", "feature"} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("expected changelog to contain %q, got:\n%s", expected, got)
+		}
+	}
+	for _, unexpected := range []string{"Unreleased", "initial release"} {
+		if strings.Contains(got, unexpected) {
+			t.Errorf("expected changelog not to contain %q, got:\n%s", unexpected, got)
+		}
+	}
+
+	if reversed := ExtractChangelog(changelog, "2.0.0", "1.0.0"); reversed != got {
+		t.Errorf("expected reversed range to produce the same entries, got:\n%s", reversed)
+	}
+}
+
+// TestDiffMaps проверяет вычисление изменений между словарями
+func TestDiffMaps(t *testing.T) {
+	from := map[string]string{"a": "^1.0.0", "b": "1.0.0", "c": "~2.0"}
+	to := map[string]string{"a": "^2.0.0", "c": "~2.0", "d": "*"}
+
+	changes := diffMaps(from, to)
+	expected := []ValueChange{
+		{Kind: ChangeChanged, Key: "a", From: "^1.0.0", To: "^2.0.0"},
+		{Kind: ChangeRemoved, Key: "b", From: "1.0.0"},
+		{Kind: ChangeAdded, Key: "d", To: "*"},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("change %d: expected %+v, got %+v", i, expected[i], changes[i])
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OutdatedPackage описывает установленный пакет, для которого доступна более новая версия
+type OutdatedPackage struct {
+	Name       string `json:"name"`
+	Current    string `json:"current"`
+	Wanted     string `json:"wanted"`
+	Latest     string `json:"latest"`
+	Constraint string `json:"constraint,omitempty"`
+	Repository string `json:"repository"`
+	Global     bool   `json:"global"`
+}
+
+// OutdatedCheckError ошибки проверки отдельных пакетов в ListOutdated (имя -> ошибка)
+type OutdatedCheckError struct {
+	Errors map[string]error
+}
+
+func (e *OutdatedCheckError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = fmt.Sprintf("%s: %v", name, e.Errors[name])
+	}
+	return fmt.Sprintf("failed to check %d package(s): %s", len(names), strings.Join(messages, "; "))
+}
+
+// ListOutdated возвращает установленные пакеты, для которых в репозиториях есть
+// более новые версии. Wanted - наибольшая версия, разрешенная ограничением из
+// criage.yaml текущего проекта, Latest - наибольшая доступная версия.
+// Последние версии запрашиваются параллельно. Если проверить удалось не все пакеты,
+// вместе с найденными возвращается *OutdatedCheckError
+func (pm *PackageManager) ListOutdated(ctx context.Context, global bool) ([]*OutdatedPackage, error) {
+	packages, err := pm.ListPackages(ctx, global, false)
+	if err != nil {
+		return nil, err
+	}
+
+	constraints := pm.projectConstraints()
+
+	workers := pm.configManager.GetConfig().Parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	var (
+		results []*OutdatedPackage
+		failed  = make(map[string]error)
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+	)
+	semaphore := make(chan struct{}, workers)
+
+	for _, info := range packages {
+		wg.Add(1)
+		go func(info *PackageInfo) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			outdated, err := pm.checkOutdated(ctx, info, constraints[info.Name])
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				failed[info.Name] = err
+				return
+			}
+			if outdated != nil {
+				results = append(results, outdated)
+			}
+		}(info)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+
+	if len(failed) > 0 {
+		return results, &OutdatedCheckError{Errors: failed}
+	}
+	return results, nil
+}
+
+// checkOutdated определяет current/wanted/latest для пакета.
+// Возвращает nil, если пакет актуален
+func (pm *PackageManager) checkOutdated(ctx context.Context, info *PackageInfo, constraint string) (*OutdatedPackage, error) {
+	repo, versions, err := pm.findAvailableVersions(ctx, info.Name, runtime.GOARCH, runtime.GOOS)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := MaxVersion(versions)
+	if latest == "" {
+		return nil, fmt.Errorf("no versions available for %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+
+	wanted := latest
+	if constraint != "" {
+		c, err := ParseConstraint(constraint)
+		if err != nil {
+			return nil, err
+		}
+		if v, ok := c.MaxSatisfying(versions); ok {
+			wanted = v
+		} else {
+			wanted = info.Version
+		}
+	}
+
+	if CompareVersions(info.Version, latest) >= 0 && CompareVersions(info.Version, wanted) >= 0 {
+		return nil, nil
+	}
+
+	return &OutdatedPackage{
+		Name:       info.Name,
+		Current:    info.Version,
+		Wanted:     wanted,
+		Latest:     latest,
+		Constraint: constraint,
+		Repository: repo.Name,
+		Global:     info.Global,
+	}, nil
+}
+
+// findAvailableVersions возвращает версии пакета, доступные для указанной платформы,
+// из репозитория с наивысшим приоритетом, в котором найден пакет
+func (pm *PackageManager) findAvailableVersions(ctx context.Context, packageName, arch, osName string) (*Repository, []string, error) {
+	repo, entry, err := pm.findPackageEntry(ctx, packageName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var versions []string
+	for _, v := range entry.Versions {
+		for _, file := range v.Files {
+			if file.OS == osName && file.Arch == arch {
+				versions = append(versions, v.Version)
+				break
+			}
+		}
+	}
+
+	return repo, versions, nil
+}
+
+// findPackageEntry возвращает запись о пакете из репозитория с наивысшим приоритетом,
+// в котором найден пакет
+func (pm *PackageManager) findPackageEntry(ctx context.Context, packageName string) (*Repository, *PackageEntry, error) {
+	repositories := append([]Repository(nil), pm.configManager.GetRepositories()...)
+
+	// Сортируем репозитории по приоритету
+	sort.Slice(repositories, func(i, j int) bool {
+		return repositories[i].Priority > repositories[j].Priority
+	})
+
+	for i := range repositories {
+		repo := repositories[i]
+		if !repo.Enabled {
+			continue
+		}
+
+		entry, err := pm.fetchPackageEntry(ctx, repo, packageName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil, ctx.Err()
+			}
+			continue
+		}
+
+		return &repo, entry, nil
+	}
+
+	return nil, nil, fmt.Errorf("package not found: %s", packageName)
+}
+
+// projectConstraints возвращает ограничения версий из criage.yaml текущего проекта
+func (pm *PackageManager) projectConstraints() map[string]string {
+	constraints := make(map[string]string)
+
+	manifest, err := pm.configManager.LoadLocalConfig(".")
+	if err != nil {
+		return constraints
+	}
+
+	for name, constraint := range manifest.DevDeps {
+		constraints[name] = constraint
+	}
+	for name, constraint := range manifest.Dependencies {
+		constraints[name] = constraint
+	}
+
+	return constraints
+}
+
+// listOutdatedInfos возвращает информацию об установленных пакетах, имеющих обновления.
+// Пакеты, которые не удалось проверить, пропускаются с предупреждением
+func (pm *PackageManager) listOutdatedInfos(ctx context.Context, global bool) ([]*PackageInfo, error) {
+	outdated, err := pm.ListOutdated(ctx, global)
+	var checkErr *OutdatedCheckError
+	if errors.As(err, &checkErr) {
+		fmt.Fprintf(os.Stderr, "Предупреждение: %v\n", err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	var packages []*PackageInfo
+	for _, entry := range outdated {
+		if info, exists := pm.getInstalledPackage(entry.Name); exists {
+			packages = append(packages, info)
+		}
+	}
+
+	return packages, nil
+}
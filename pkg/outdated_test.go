@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestListOutdated проверяет, что устаревшие пакеты возвращаются вместе с ошибкой
+// для пакетов, которые не удалось проверить, в том числе когда репозиторий недоступен
+func TestListOutdated(t *testing.T) {
+	pm := newPlanTestPackageManager(t)
+	t.Chdir(t.TempDir())
+	pm.installedPackages["app"] = &PackageInfo{Name: "app", Version: "1.0.0"}
+	pm.installedPackages["lib"] = &PackageInfo{Name: "lib", Version: "1.0.0"}
+
+	outdated, err := pm.ListOutdated(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outdated) != 1 || outdated[0].Name != "app" || outdated[0].Latest != "2.0.0" {
+		t.Errorf("unexpected outdated packages: %+v", outdated)
+	}
+
+	pm.installedPackages["ghost"] = &PackageInfo{Name: "ghost", Version: "1.0.0"}
+	outdated, err = pm.ListOutdated(context.Background(), false)
+	var checkErr *OutdatedCheckError
+	if !errors.As(err, &checkErr) || len(checkErr.Errors) != 1 || checkErr.Errors["ghost"] == nil {
+		t.Fatalf("expected check error for ghost, got %v", err)
+	}
+	if len(outdated) != 1 {
+		t.Errorf("expected outdated packages with the error, got %+v", outdated)
+	}
+
+	// Недоступный репозиторий - ошибка для каждого пакета, а не пустой список
+	pm.configManager.config.Repositories[0].URL = "http://127.0.0.1:1"
+	if _, err := pm.ListOutdated(context.Background(), false); !errors.As(err, &checkErr) || len(checkErr.Errors) != 3 {
+		t.Errorf("expected check errors for all packages, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// PackOptions параметры упаковки готовой директории
+type PackOptions struct {
+	// ManifestPath путь к манифесту пакета; пустое значение - criage.yaml упаковываемой
+	// директории, если он есть
+	ManifestPath string
+	// Name и Version переопределяют значения манифеста; без манифеста обязательны
+	Name    string
+	Version string
+	// Output путь к архиву; пустое значение - <name>-<version>-<os>-<arch>.criage
+	Output           string
+	Format           string
+	CompressionLevel int
+	// ExcludeFiles шаблоны в стиле .gitignore относительно упаковываемой директории
+	ExcludeFiles []string
+	// Reproducible создает воспроизводимый архив, как BuildOptions.Reproducible
+	Reproducible bool
+	// SBOMFormat формат встраиваемого SBOM, как BuildOptions.SBOMFormat
+	SBOMFormat string
+}
+
+// PackDirectory упаковывает уже собранную директорию dir в архив пакета со
+// встроенными метаданными без выполнения скрипта сборки и возвращает путь к архиву
+func (pm *PackageManager) PackDirectory(ctx context.Context, dir string, options PackOptions) (string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", dir)
+	}
+
+	manifest, err := pm.packManifest(dir, options)
+	if err != nil {
+		return "", err
+	}
+	if err := ValidateSBOMFormat(options.SBOMFormat); err != nil {
+		return "", err
+	}
+
+	target := BuildTargetConfig{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	output := targetOutputPath(options.Output, manifest.Name, manifest.Version, target, false)
+
+	var modTime time.Time
+	if _, set := os.LookupEnv("SOURCE_DATE_EPOCH"); options.Reproducible || set {
+		if modTime, err = sourceDateEpoch(os.Environ()); err != nil {
+			return "", err
+		}
+	}
+	createdAt := modTime
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	metadata := &PackageMetadata{
+		PackageManifest: manifest,
+		CompressionType: options.Format,
+		CreatedAt:       createdAt,
+		CreatedBy:       "criage",
+	}
+
+	// criage.yaml директории не попадает в архив, если манифест пакета взят из другого
+	// файла или изменен флагами: при установке используется манифест из метаданных
+	var skipPaths []string
+	if options.ManifestPath != "" || options.Name != "" || options.Version != "" {
+		skipPaths = append(skipPaths, filepath.Join(dir, LocalConfigName))
+	}
+
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	fmt.Printf("Упаковка директории %s...\n", dir)
+	if err := createPackageArchive(dir, output, metadata, archiveOptions{
+		format:       ArchiveFormat(options.Format),
+		level:        options.CompressionLevel,
+		includeFiles: manifest.Files,
+		excludeFiles: append(append([]string(nil), manifest.Exclude...), options.ExcludeFiles...),
+		skipPaths:    skipPaths,
+		modTime:      modTime,
+		sbom:         pm.newSBOMOptions(options.SBOMFormat, manifest),
+	}); err != nil {
+		return "", fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	fmt.Printf("Пакет собран с встроенными метаданными: %s\n", output)
+	return output, nil
+}
+
+// packManifest возвращает манифест упаковываемой директории: файл ManifestPath,
+// criage.yaml директории или пустой манифест, дополненные Name и Version
+func (pm *PackageManager) packManifest(dir string, options PackOptions) (*PackageManifest, error) {
+	manifestPath := options.ManifestPath
+	if manifestPath == "" {
+		if _, err := os.Stat(filepath.Join(dir, LocalConfigName)); err == nil {
+			manifestPath = filepath.Join(dir, LocalConfigName)
+		}
+	}
+
+	manifest := &PackageManifest{}
+	if manifestPath != "" {
+		loaded, err := pm.configManager.LoadManifestFile(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		manifest = loaded
+	}
+
+	if options.Name != "" {
+		manifest.Name = options.Name
+	}
+	if options.Version != "" {
+		manifest.Version = options.Version
+	}
+	if manifest.Name == "" || manifest.Version == "" {
+		return nil, fmt.Errorf("package name and version are required (use --manifest or --name and --version)")
+	}
+	if _, err := ParseVersion(manifest.Version); err != nil {
+		return nil, fmt.Errorf("invalid package version %q: %w", manifest.Version, err)
+	}
+	// Установка копирует только файлы из files, поэтому без списка упаковывается
+	// и устанавливается вся директория
+	if len(manifest.Files) == 0 {
+		manifest.Files = []string{"*"}
+	}
+	return manifest, nil
+}
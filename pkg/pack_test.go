@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	commonarchive "github.com/criage-oss/criage-common/archive"
+	commonconfig "github.com/criage-oss/criage-common/config"
+)
+
+// TestPackDirectory проверяет упаковку директории без манифеста и чтение манифеста
+// из метаданных после распаковки
+func TestPackDirectory(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	if err := os.MkdirAll(filepath.Join(source, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "bin", "tool"), []byte("tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newTestPackageManager(dir)
+	if _, err := pm.PackDirectory(context.Background(), source, PackOptions{Format: "tar.zst"}); err == nil {
+		t.Fatal("expected error without name and version")
+	}
+
+	output := filepath.Join(dir, "tool.tar.zst")
+	if _, err := pm.PackDirectory(context.Background(), source, PackOptions{
+		Name:    "tool",
+		Version: "1.2.3",
+		Output:  output,
+		Format:  "tar.zst",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	manager, err := commonarchive.NewManager(commonconfig.DefaultConfig(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer manager.Close()
+
+	dest := t.TempDir()
+	if err := manager.ExtractArchive(output, dest, "tar.zst"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "bin", "tool")); err != nil {
+		t.Errorf("bin/tool not packed: %v", err)
+	}
+
+	manifest, err := pm.loadManifestFromDir(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Name != "tool" || manifest.Version != "1.2.3" {
+		t.Errorf("manifest = %s@%s, want tool@1.2.3", manifest.Name, manifest.Version)
+	}
+
+	// Установка копирует файлы по списку files манифеста
+	installPath := filepath.Join(dir, "installed")
+	if err := pm.copyFiles(dest, installPath, manifest.Files); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(installPath, "bin", "tool")); err != nil {
+		t.Errorf("bin/tool not installed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(installPath, MetadataFileName)); !os.IsNotExist(err) {
+		t.Errorf("%s should not be installed", MetadataFileName)
+	}
+}
+
+// TestCopyFilesSymlinkLoop проверяет, что копирование не переходит по ссылкам на директории
+func TestCopyFilesSymlinkLoop(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on windows")
+	}
+	src, dst := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "lib", "data"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("..", filepath.Join(src, "lib", "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newTestPackageManager(t.TempDir())
+	if err := pm.copyFiles(src, dst, []string{"*"}); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := os.Readlink(filepath.Join(dst, "lib", "loop")); err != nil || target != ".." {
+		t.Errorf("loop link = %q, %v", target, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "lib", "data")); err != nil || string(data) != "data" {
+		t.Errorf("lib/data = %q, %v", data, err)
+	}
+}
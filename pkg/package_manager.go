@@ -1,12 +1,11 @@
 package pkg
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"sort"
@@ -14,79 +13,46 @@ import (
 	"time"
 )
 
-// RateLimiter простой rate limiter для HTTP запросов
-type RateLimiter struct {
-	ticker   *time.Ticker
-	requests chan struct{}
-}
-
-// NewRateLimiter создает новый rate limiter с заданной частотой запросов в секунду
-func NewRateLimiter(requestsPerSecond int) *RateLimiter {
-	if requestsPerSecond <= 0 {
-		requestsPerSecond = 10 // по умолчанию 10 запросов в секунду
-	}
-
-	interval := time.Second / time.Duration(requestsPerSecond)
-	ticker := time.NewTicker(interval)
-	requests := make(chan struct{}, requestsPerSecond)
-
-	// Изначально не заполняем канал, токены будут поступать по тикам
-
-	rl := &RateLimiter{
-		ticker:   ticker,
-		requests: requests,
-	}
-
-	// Запускаем горутину для пополнения буфера
-	go func() {
-		for range ticker.C {
-			select {
-			case requests <- struct{}{}:
-			default:
-				// буфер полон
-			}
-		}
-	}()
-
-	return rl
-}
-
-// Wait ждет разрешения на выполнение запроса
-func (rl *RateLimiter) Wait() {
-	<-rl.requests
-}
-
-// Close останавливает rate limiter
-func (rl *RateLimiter) Close() {
-	rl.ticker.Stop()
-}
-
 // PackageManager основной менеджер пакетов
 type PackageManager struct {
 	configManager  *ConfigManager
 	archiveManager interface {
 		DetectFormat(filename string) ArchiveFormat
 		ExtractArchive(archivePath, destDir string, format ArchiveFormat) error
-		CreateArchiveWithMetadata(sourceDir, outputPath string, format ArchiveFormat, includeFiles, excludeFiles []string, metadata *PackageMetadata) error
+		ExtractMetadataFromArchive(archivePath string, format ArchiveFormat) (*PackageMetadata, error)
 		Close() error
 	}
 	installedPackages map[string]*PackageInfo
 	packagesMutex     sync.RWMutex
 	httpClient        *http.Client
-	rateLimiter       *RateLimiter
+	// transferClient клиент для скачивания и загрузки файлов пакетов с отдельным таймаутом
+	transferClient *http.Client
+	// rateLimiters ограничители частоты запросов по репозиториям
+	rateLimiters      map[string]*RateLimiter
+	rateLimitersMutex sync.Mutex
+	// workDirs рабочие директории операций в TempPath, удаляемые при Close
+	workDirs      map[string]struct{}
+	workDirsMutex sync.Mutex
 }
 
 // NewPackageManager создает новый пакетный менеджер
 func NewPackageManager() (*PackageManager, error) {
-	configManager, err := NewConfigManager()
+	return NewPackageManagerWithOverrides(nil)
+}
+
+// NewPackageManagerWithOverrides создает пакетный менеджер с переопределениями
+// конфигурации из командной строки (ключ -> значение)
+func NewPackageManagerWithOverrides(overrides map[string]string) (*PackageManager, error) {
+	configManager, err := NewConfigManagerWithOverrides(overrides)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create config manager: %w", err)
 	}
 
-	// Получаем версию из переменной окружения или используем "1.0.0"
-	version := os.Getenv("CRIAGE_VERSION")
+	// Версию клиента можно переопределить переменной окружения. CRIAGE_VERSION
+	// занята версией пакета, которую получают скрипты
+	version := os.Getenv("CRIAGE_CLIENT_VERSION")
 	if version == "" {
-		version = "1.0.0"
+		version = BuildVersion
 	}
 
 	// Используем общий архивный менеджер напрямую
@@ -95,9 +61,13 @@ func NewPackageManager() (*PackageManager, error) {
 		return nil, fmt.Errorf("failed to create archive manager: %w", err)
 	}
 
-	// Настраиваем HTTP клиент
-	httpClient := &http.Client{
-		Timeout: time.Duration(configManager.GetConfig().Timeout) * time.Second,
+	// Настраиваем HTTP клиенты: прокси, TLS, таймауты и пул соединений из конфигурации
+	// Ошибка настройки (например, отсутствующий CA bundle) проявляется только при сетевых
+	// запросах, чтобы конфигурацию можно было исправить командой config
+	httpClient, transferClient, err := newHTTPClients(configManager.GetConfig(), version)
+	if err != nil {
+		httpClient = &http.Client{Transport: errorTransport{err: fmt.Errorf("failed to configure HTTP transport: %w", err)}}
+		transferClient = httpClient
 	}
 
 	pm := &PackageManager{
@@ -105,7 +75,9 @@ func NewPackageManager() (*PackageManager, error) {
 		archiveManager:    archiveManager,
 		installedPackages: make(map[string]*PackageInfo),
 		httpClient:        httpClient,
-		rateLimiter:       NewRateLimiter(5), // 5 запросов в секунду
+		transferClient:    transferClient,
+		rateLimiters:      make(map[string]*RateLimiter),
+		workDirs:          make(map[string]struct{}),
 	}
 
 	// Создаем необходимые директории
@@ -122,7 +94,7 @@ func NewPackageManager() (*PackageManager, error) {
 }
 
 // InstallPackage устанавливает пакет
-func (pm *PackageManager) InstallPackage(packageName, version string, global, force, dev bool, arch, osName string) error {
+func (pm *PackageManager) InstallPackage(ctx context.Context, packageName, version string, global, force, dev bool, arch, osName string) error {
 	fmt.Print(T("installing_package", packageName))
 
 	// Проверяем, не установлен ли уже пакет
@@ -144,26 +116,38 @@ func (pm *PackageManager) InstallPackage(packageName, version string, global, fo
 	}
 
 	// Поиск пакета в репозиториях
-	packageInfo, downloadURL, err := pm.findPackage(packageName, version, arch, osName)
+	resolved, err := pm.resolvePackage(ctx, packageName, version, arch, osName)
 	if err != nil {
 		return fmt.Errorf(T("error_failed_to_find"), err)
 	}
+	packageInfo := resolved.packageInfo()
 
 	// Скачиваем пакет
-	archivePath, err := pm.downloadPackage(downloadURL, packageName, packageInfo.Version)
+	archivePath, err := pm.downloadPackage(ctx, resolved)
 	if err != nil {
 		return fmt.Errorf(T("error_failed_to_download"), err)
 	}
 	defer os.Remove(archivePath)
 
 	// Извлекаем архив
-	tempDir := pm.configManager.GetTempPath(fmt.Sprintf("install_%s_%d", packageName, time.Now().Unix()))
-	defer os.RemoveAll(tempDir)
+	tempDir, err := pm.newWorkDir("install_" + packageName)
+	if err != nil {
+		return fmt.Errorf(T("error_failed_to_create"), err)
+	}
+	defer pm.removeWorkDir(tempDir)
 
 	format := pm.archiveManager.DetectFormat(archivePath)
 	if err := pm.archiveManager.ExtractArchive(archivePath, tempDir, format); err != nil {
 		return fmt.Errorf(T("error_failed_to_extract"), err)
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Проверяем происхождение пакета до выполнения его хуков
+	if err := checkInstallPolicy(pm.configManager.GetConfig().InstallPolicy, tempDir); err != nil {
+		return fmt.Errorf(T("error_install_policy"), err)
+	}
 
 	// Загружаем манифест пакета
 	manifest, err := pm.loadManifestFromDir(tempDir)
@@ -172,15 +156,20 @@ func (pm *PackageManager) InstallPackage(packageName, version string, global, fo
 	}
 
 	// Проверяем зависимости
-	if err := pm.checkDependencies(manifest, dev); err != nil {
+	if err := pm.checkDependencies(ctx, manifest, dev); err != nil {
 		return fmt.Errorf(T("error_dependency_check"), err)
 	}
 
 	// Выполняем пре-установочные хуки
-	if err := pm.executeHooks(manifest.Hooks, manifest.Hooks.PreInstall, tempDir); err != nil {
+	if err := pm.executeHooks(ctx, manifest.Hooks, manifest.Hooks.PreInstall, tempDir); err != nil {
 		return fmt.Errorf(T("error_pre_install_hooks"), err)
 	}
 
+	// После этой точки установка не прерывается, чтобы не оставить пакет установленным частично
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Определяем путь установки
 	installPath := pm.configManager.GetInstallPath(packageName, global)
 
@@ -220,6 +209,9 @@ func (pm *PackageManager) InstallPackage(packageName, version string, global, fo
 	if err := pm.savePackageInfo(packageInfo); err != nil {
 		return fmt.Errorf(T("error_failed_to_save"), err)
 	}
+	if err := saveInstalledSBOM(tempDir, installPath); err != nil {
+		return fmt.Errorf(T("error_failed_to_save"), err)
+	}
 
 	// Обновляем кеш установленных пакетов
 	pm.packagesMutex.Lock()
@@ -227,7 +219,7 @@ func (pm *PackageManager) InstallPackage(packageName, version string, global, fo
 	pm.packagesMutex.Unlock()
 
 	// Выполняем пост-установочные хуки
-	if err := pm.executeHooks(manifest.Hooks, manifest.Hooks.PostInstall, installPath); err != nil {
+	if err := pm.executeHooks(ctx, manifest.Hooks, manifest.Hooks.PostInstall, installPath); err != nil {
 		fmt.Print(T("error_post_install_hooks", err))
 	}
 
@@ -236,7 +228,7 @@ func (pm *PackageManager) InstallPackage(packageName, version string, global, fo
 }
 
 // UninstallPackage удаляет пакет
-func (pm *PackageManager) UninstallPackage(packageName string, global, purge bool) error {
+func (pm *PackageManager) UninstallPackage(ctx context.Context, packageName string, global, purge bool) error {
 	fmt.Print(T("uninstalling_package", packageName))
 
 	// Проверяем, установлен ли пакет
@@ -253,7 +245,7 @@ func (pm *PackageManager) UninstallPackage(packageName string, global, purge boo
 
 	// Выполняем пре-удаление хуки
 	if manifest != nil && manifest.Hooks != nil {
-		if err := pm.executeHooks(manifest.Hooks, manifest.Hooks.PreRemove, packageInfo.InstallPath); err != nil {
+		if err := pm.executeHooks(ctx, manifest.Hooks, manifest.Hooks.PreRemove, packageInfo.InstallPath); err != nil {
 			fmt.Print(T("warning_pre_remove_hooks", err))
 		}
 	}
@@ -275,7 +267,7 @@ func (pm *PackageManager) UninstallPackage(packageName string, global, purge boo
 
 	// Выполняем пост-удаление хуки
 	if manifest != nil && manifest.Hooks != nil {
-		if err := pm.executeHooks(manifest.Hooks, manifest.Hooks.PostRemove, ""); err != nil {
+		if err := pm.executeHooks(ctx, manifest.Hooks, manifest.Hooks.PostRemove, ""); err != nil {
 			fmt.Print(T("warning_post_remove_hooks", err))
 		}
 	}
@@ -285,7 +277,7 @@ func (pm *PackageManager) UninstallPackage(packageName string, global, purge boo
 }
 
 // UpdatePackage обновляет пакет
-func (pm *PackageManager) UpdatePackage(packageName string) error {
+func (pm *PackageManager) UpdatePackage(ctx context.Context, packageName string) error {
 	fmt.Printf("Обновление пакета %s...\n", packageName)
 
 	// Проверяем, установлен ли пакет
@@ -295,51 +287,27 @@ func (pm *PackageManager) UpdatePackage(packageName string) error {
 	}
 
 	// Ищем последнюю версию
-	latestInfo, _, err := pm.findPackage(packageName, "", runtime.GOARCH, runtime.GOOS)
+	latestInfo, _, err := pm.findPackage(ctx, packageName, "", runtime.GOARCH, runtime.GOOS)
 	if err != nil {
 		return fmt.Errorf("failed to find latest version: %w", err)
 	}
 
-	// Проверяем, нужно ли обновление
-	if latestInfo.Version == packageInfo.Version {
+	// Проверяем, нужно ли обновление: более старая версия в репозитории не считается обновлением
+	if CompareVersions(packageInfo.Version, latestInfo.Version) >= 0 {
 		fmt.Printf("Пакет %s уже имеет последнюю версию (%s)\n", packageName, packageInfo.Version)
 		return nil
 	}
 
 	// Выполняем обновление через переустановку
-	return pm.InstallPackage(packageName, latestInfo.Version, packageInfo.Global, true, false, "", "")
+	return pm.InstallPackage(ctx, packageName, latestInfo.Version, packageInfo.Global, true, false, "", "")
 }
 
-// SearchPackages ищет пакеты в репозиториях
-func (pm *PackageManager) SearchPackages(query string) ([]SearchResult, error) {
-	var results []SearchResult
-
-	repositories := pm.configManager.GetRepositories()
-
-	for _, repo := range repositories {
-		if !repo.Enabled {
-			continue
-		}
-
-		repoResults, err := pm.searchInRepository(repo, query)
-		if err != nil {
-			fmt.Printf("Предупреждение: failed to search in repository %s: %v\n", repo.Name, err)
-			continue
-		}
-
-		results = append(results, repoResults...)
+// ListPackages возвращает список установленных пакетов
+func (pm *PackageManager) ListPackages(ctx context.Context, global, outdated bool) ([]*PackageInfo, error) {
+	if outdated {
+		return pm.listOutdatedInfos(ctx, global)
 	}
 
-	// Сортируем по релевантности
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Score > results[j].Score
-	})
-
-	return results, nil
-}
-
-// ListPackages возвращает список установленных пакетов
-func (pm *PackageManager) ListPackages(global, outdated bool) ([]*PackageInfo, error) {
 	pm.packagesMutex.RLock()
 	defer pm.packagesMutex.RUnlock()
 
@@ -353,14 +321,6 @@ func (pm *PackageManager) ListPackages(global, outdated bool) ([]*PackageInfo, e
 			continue
 		}
 
-		if outdated {
-			// Проверяем, есть ли более новая версия
-			latestInfo, _, err := pm.findPackage(pkg.Name, "", runtime.GOARCH, runtime.GOOS)
-			if err != nil || latestInfo.Version == pkg.Version {
-				continue
-			}
-		}
-
 		packages = append(packages, pkg)
 	}
 
@@ -429,70 +389,8 @@ func (pm *PackageManager) CreatePackage(name, template, author, description stri
 	return nil
 }
 
-// BuildPackage собирает пакет с встроенными метаданными
-func (pm *PackageManager) BuildPackage(outputPath, format string, compressionLevel int) error {
-	fmt.Println("Сборка пакета...")
-
-	// Загружаем локальную конфигурацию
-	manifest, err := pm.configManager.LoadLocalConfig(".")
-	if err != nil {
-		return fmt.Errorf("failed to load local config: %w", err)
-	}
-
-	// Проверяем сборочную конфигурацию
-	buildManifest, err := pm.configManager.LoadBuildConfig(".")
-	if err != nil {
-		// Создаем базовую конфигурацию сборки
-		buildManifest = &BuildManifest{
-			Name:         manifest.Name,
-			Version:      manifest.Version,
-			BuildScript:  "make",
-			OutputDir:    "./build",
-			IncludeFiles: manifest.Files,
-			ExcludeFiles: manifest.Exclude,
-			Compression: CompressionConfig{
-				Format: format,
-				Level:  compressionLevel,
-			},
-			Targets: []BuildTarget{
-				{OS: runtime.GOOS, Arch: runtime.GOARCH},
-			},
-		}
-	}
-
-	// Выполняем скрипт сборки
-	if buildManifest.BuildScript != "" {
-		fmt.Printf("Выполнение скрипта сборки: %s\n", buildManifest.BuildScript)
-		if err := pm.executeBuildScript(buildManifest); err != nil {
-			return fmt.Errorf("build script failed: %w", err)
-		}
-	}
-
-	// Определяем выходной файл
-	if outputPath == "" {
-		outputPath = fmt.Sprintf("%s-%s.criage", manifest.Name, manifest.Version)
-	}
-
-	// Создаем структуру метаданных для встраивания в архив
-	metadata := &PackageMetadata{
-		PackageManifest: manifest,
-		BuildManifest:   buildManifest,
-		CompressionType: format,
-		CreatedBy:       "criage",
-	}
-
-	// Создаем архив с встроенными метаданными
-	archiveFormat := ArchiveFormat(format)
-	if err := pm.archiveManager.CreateArchiveWithMetadata(".", outputPath, archiveFormat, buildManifest.IncludeFiles, buildManifest.ExcludeFiles, metadata); err != nil {
-		return fmt.Errorf("failed to create archive: %w", err)
-	}
-
-	fmt.Printf("Пакет собран с встроенными метаданными: %s\n", outputPath)
-	return nil
-}
-
 // PublishPackage публикует пакет в репозитории
-func (pm *PackageManager) PublishPackage(registryURL, token string) error {
+func (pm *PackageManager) PublishPackage(ctx context.Context, registryURL, token string) error {
 	fmt.Println("Публикация пакета...")
 
 	// Загружаем локальную конфигурацию
@@ -501,16 +399,24 @@ func (pm *PackageManager) PublishPackage(registryURL, token string) error {
 		return fmt.Errorf("failed to load local config: %w", err)
 	}
 
-	// Собираем пакет
-	archivePath := fmt.Sprintf("%s-%s.tar.zst", manifest.Name, manifest.Version)
-	if err := pm.BuildPackage(archivePath, "tar.zst", CompressionNormal); err != nil {
+	// Собираем пакет для всех целей и публикуем каждый архив со своей платформой
+	archives, err := pm.buildPackage(ctx, BuildOptions{
+		Output:           fmt.Sprintf("%s-%s.tar.zst", manifest.Name, manifest.Version),
+		Format:           "tar.zst",
+		CompressionLevel: CompressionNormal,
+		SBOMFormat:       SBOMFormatSPDX,
+	})
+	if err != nil {
 		return fmt.Errorf("failed to build package: %w", err)
 	}
-	defer os.Remove(archivePath)
+	for _, archive := range archives {
+		defer os.Remove(archive.path)
+	}
 
-	// Публикуем пакет
-	if err := pm.uploadPackage(registryURL, token, archivePath, manifest); err != nil {
-		return fmt.Errorf("failed to upload package: %w", err)
+	for _, archive := range archives {
+		if err := pm.uploadPackage(ctx, registryURL, token, archive.path, manifest, archive.target); err != nil {
+			return fmt.Errorf("failed to upload package: %w", err)
+		}
 	}
 
 	fmt.Printf("Пакет %s версии %s успешно опубликован\n", manifest.Name, manifest.Version)
@@ -526,9 +432,40 @@ func (pm *PackageManager) getInstalledPackage(packageName string) (*PackageInfo,
 	return info, exists
 }
 
+// resolvedPackage результат поиска пакета в репозитории
+type resolvedPackage struct {
+	repository  Repository
+	entry       *PackageEntry
+	version     *VersionEntry
+	file        *FileEntry
+	downloadURL string
+}
+
+// packageInfo создает PackageInfo из найденной записи
+func (r *resolvedPackage) packageInfo() *PackageInfo {
+	return &PackageInfo{
+		Name:         r.entry.Name,
+		Version:      r.version.Version,
+		Description:  r.entry.Description,
+		Author:       r.entry.Author,
+		Dependencies: r.version.Dependencies,
+		Size:         r.file.Size,
+	}
+}
+
 // findPackage ищет пакет в репозиториях
-func (pm *PackageManager) findPackage(packageName, version, arch, osName string) (*PackageInfo, string, error) {
-	repositories := pm.configManager.GetRepositories()
+func (pm *PackageManager) findPackage(ctx context.Context, packageName, version, arch, osName string) (*PackageInfo, string, error) {
+	resolved, err := pm.resolvePackage(ctx, packageName, version, arch, osName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return resolved.packageInfo(), resolved.downloadURL, nil
+}
+
+// resolvePackage ищет пакет в репозиториях в порядке приоритета
+func (pm *PackageManager) resolvePackage(ctx context.Context, packageName, version, arch, osName string) (*resolvedPackage, error) {
+	repositories := append([]Repository(nil), pm.configManager.GetRepositories()...)
 
 	// Сортируем репозитории по приоритету
 	sort.Slice(repositories, func(i, j int) bool {
@@ -540,167 +477,177 @@ func (pm *PackageManager) findPackage(packageName, version, arch, osName string)
 			continue
 		}
 
-		packageInfo, downloadURL, err := pm.findInRepository(repo, packageName, version, arch, osName)
+		resolved, err := pm.findInRepository(ctx, repo, packageName, version, arch, osName)
 		if err == nil {
-			return packageInfo, downloadURL, nil
+			return resolved, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
 		}
 	}
 
-	return nil, "", fmt.Errorf("package not found: %s", packageName)
+	return nil, fmt.Errorf("package not found: %s", packageName)
 }
 
-// findInRepository ищет пакет в конкретном репозитории
-func (pm *PackageManager) findInRepository(repo Repository, packageName, version, arch, osName string) (*PackageInfo, string, error) {
-	// Используем API v1 criage-server
-	url := fmt.Sprintf("%s/api/v1/packages/%s", repo.URL, packageName)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, "", err
-	}
-
-	if repo.AuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+repo.AuthToken)
-	}
-
-	resp, err := pm.httpClient.Do(req)
+// findInRepository ищет пакет в конкретном репозитории.
+// version может быть точной версией или ограничением ("^1.2.0", ">=1.0 <2.0")
+func (pm *PackageManager) findInRepository(ctx context.Context, repo Repository, packageName, version, arch, osName string) (*resolvedPackage, error) {
+	packageEntry, err := pm.fetchPackageEntry(ctx, repo, packageName)
 	if err != nil {
-		return nil, "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("package not found in repository")
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	if !apiResp.Success {
-		return nil, "", fmt.Errorf("API error: %s", apiResp.Error)
-	}
-
-	// Преобразуем данные в PackageEntry
-	packageEntryData, ok := apiResp.Data.(map[string]interface{})
-	if !ok {
-		return nil, "", fmt.Errorf("unexpected API response format")
-	}
-
-	var packageEntry PackageEntry
-	packageEntryBytes, err := json.Marshal(packageEntryData)
-	if err != nil {
-		return nil, "", err
+	versionEntry := func(v string) *VersionEntry {
+		for i := range packageEntry.Versions {
+			if packageEntry.Versions[i].Version == v {
+				return &packageEntry.Versions[i]
+			}
+		}
+		return nil
 	}
-
-	if err := json.Unmarshal(packageEntryBytes, &packageEntry); err != nil {
-		return nil, "", err
+	var candidates []string
+	for _, v := range packageEntry.Versions {
+		candidates = append(candidates, v.Version)
 	}
 
 	// Выбираем версию
 	var selectedVersion *VersionEntry
 	if version == "" {
-		// Берем последнюю версию
-		if len(packageEntry.Versions) > 0 {
-			selectedVersion = &packageEntry.Versions[len(packageEntry.Versions)-1]
-		}
+		// Берем наибольшую версию независимо от порядка в ответе сервера
+		selectedVersion = versionEntry(MaxVersion(candidates))
 	} else {
 		// Ищем указанную версию
-		for _, v := range packageEntry.Versions {
-			if v.Version == version {
-				selectedVersion = &v
-				break
+		selectedVersion = versionEntry(version)
+
+		// Если точного совпадения нет, трактуем версию как ограничение
+		if selectedVersion == nil {
+			if constraint, err := ParseConstraint(version); err == nil {
+				if best, ok := constraint.MaxSatisfying(candidates); ok {
+					selectedVersion = versionEntry(best)
+				}
 			}
 		}
 	}
 
 	if selectedVersion == nil {
-		return nil, "", fmt.Errorf("version %s not found", version)
+		return nil, fmt.Errorf("version %s not found", version)
 	}
 
 	// Ищем подходящий файл
 	var selectedFile *FileEntry
-	for _, file := range selectedVersion.Files {
-		if file.OS == osName && file.Arch == arch {
-			selectedFile = &file
+	for i := range selectedVersion.Files {
+		if selectedVersion.Files[i].OS == osName && selectedVersion.Files[i].Arch == arch {
+			selectedFile = &selectedVersion.Files[i]
 			break
 		}
 	}
 
 	if selectedFile == nil {
-		return nil, "", fmt.Errorf("file for %s/%s not found", osName, arch)
+		return nil, fmt.Errorf("file for %s/%s not found", osName, arch)
 	}
 
-	// Создаем PackageInfo из PackageEntry
-	packageInfo := &PackageInfo{
-		Name:         packageEntry.Name,
-		Version:      selectedVersion.Version,
-		Description:  packageEntry.Description,
-		Author:       packageEntry.Author,
-		Dependencies: selectedVersion.Dependencies,
-		Size:         selectedFile.Size,
-	}
+	downloadURL := pm.repositoryClient(repo).DownloadURL(packageEntry.Name, selectedVersion.Version, selectedFile.Filename)
 
-	// Строим URL для скачивания используя API v1
-	downloadURL := fmt.Sprintf("%s/api/v1/download/%s/%s/%s",
-		repo.URL, packageEntry.Name, selectedVersion.Version, selectedFile.Filename)
+	return &resolvedPackage{
+		repository:  repo,
+		entry:       packageEntry,
+		version:     selectedVersion,
+		file:        selectedFile,
+		downloadURL: downloadURL,
+	}, nil
+}
 
-	return packageInfo, downloadURL, nil
+// fetchPackageEntry получает запись о пакете со всеми версиями из репозитория
+func (pm *PackageManager) fetchPackageEntry(ctx context.Context, repo Repository, packageName string) (*PackageEntry, error) {
+	entry, err := pm.repositoryClient(repo).GetPackage(ctx, packageName)
+	if IsNotFound(err) {
+		return nil, fmt.Errorf("package not found in repository")
+	}
+	return entry, err
 }
 
-// downloadPackage скачивает пакет
-func (pm *PackageManager) downloadPackage(url, packageName, version string) (string, error) {
-	cachePath := pm.configManager.GetCachePath(packageName, version)
-	if err := os.MkdirAll(cachePath, 0755); err != nil {
+// downloadPackage скачивает архив найденного пакета в кеш
+func (pm *PackageManager) downloadPackage(ctx context.Context, resolved *resolvedPackage) (string, error) {
+	packageName, version := resolved.entry.Name, resolved.version.Version
+
+	archivePath := pm.cachedArchivePath(packageName, version)
+	if err := os.MkdirAll(filepath.Dir(archivePath), 0755); err != nil {
 		return "", fmt.Errorf("failed to create cache directory: %w", err)
 	}
 
-	archivePath := filepath.Join(cachePath, "package.tar.zst")
-
 	// Проверяем, есть ли уже файл в кеше
 	if _, err := os.Stat(archivePath); err == nil {
 		fmt.Printf("Используется кешированная версия пакета\n")
 		return archivePath, nil
 	}
 
-	fmt.Printf("Скачивание пакета из %s\n", url)
+	fmt.Printf("Скачивание пакета из %s\n", resolved.downloadURL)
 
-	resp, err := pm.httpClient.Get(url)
+	// Архив скачивается во временный файл рядом с кешем и переименовывается только
+	// после успешного скачивания, чтобы прерванная загрузка не оставила битый архив в кеше
+	partPath := archivePath + ".part"
+	outFile, err := os.Create(partPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to download package: %w", err)
+		return "", fmt.Errorf("failed to create cache file: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to download package: HTTP %d", resp.StatusCode)
+	client := pm.repositoryClient(resolved.repository)
+	_, err = client.Download(ctx, packageName, version, resolved.file.Filename, outFile)
+	if closeErr := outFile.Close(); err == nil {
+		err = closeErr
 	}
-
-	outFile, err := os.Create(archivePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create cache file: %w", err)
+	if err == nil {
+		err = os.Rename(partPath, archivePath)
 	}
-	defer outFile.Close()
-
-	if _, err := io.Copy(outFile, resp.Body); err != nil {
-		return "", fmt.Errorf("failed to save package: %w", err)
+	if err != nil {
+		os.Remove(partPath)
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", fmt.Errorf("failed to download package: %w", err)
 	}
 
 	return archivePath, nil
 }
 
-// loadManifestFromDir загружает манифест из директории
+// cachedArchivePath возвращает путь к архиву пакета в кеше
+func (pm *PackageManager) cachedArchivePath(packageName, version string) string {
+	return filepath.Join(pm.configManager.GetCachePath(packageName, version), "package.tar.zst")
+}
+
+// loadManifestFromDir загружает манифест из директории. Если criage.yaml нет
+// (пакет создан criage pack без манифеста), используется манифест из метаданных архива
 func (pm *PackageManager) loadManifestFromDir(dir string) (*PackageManifest, error) {
 	manifestPath := filepath.Join(dir, "criage.yaml")
 	if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("manifest not found")
+		return loadManifestFromMetadata(filepath.Join(dir, MetadataFileName))
 	}
 
 	return pm.configManager.LoadLocalConfig(dir)
 }
 
+// loadManifestFromMetadata загружает манифест пакета из файла метаданных архива
+func loadManifestFromMetadata(metadataPath string) (*PackageManifest, error) {
+	data, err := os.ReadFile(metadataPath)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("manifest not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata PackageMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse package metadata: %w", err)
+	}
+	if metadata.PackageManifest == nil {
+		return nil, fmt.Errorf("manifest not found")
+	}
+	return metadata.PackageManifest, nil
+}
+
 // checkDependencies проверяет и устанавливает зависимости
-func (pm *PackageManager) checkDependencies(manifest *PackageManifest, dev bool) error {
+func (pm *PackageManager) checkDependencies(ctx context.Context, manifest *PackageManifest, dev bool) error {
 	dependencies := manifest.Dependencies
 	if dev {
 		for name, version := range manifest.DevDeps {
@@ -711,7 +658,7 @@ func (pm *PackageManager) checkDependencies(manifest *PackageManifest, dev bool)
 	for depName, depVersion := range dependencies {
 		if _, exists := pm.getInstalledPackage(depName); !exists {
 			fmt.Printf("Установка зависимости: %s@%s\n", depName, depVersion)
-			if err := pm.InstallPackage(depName, depVersion, false, false, false, "", ""); err != nil {
+			if err := pm.InstallPackage(ctx, depName, depVersion, false, false, false, "", ""); err != nil {
 				return fmt.Errorf("failed to install dependency %s: %w", depName, err)
 			}
 		}
@@ -721,18 +668,21 @@ func (pm *PackageManager) checkDependencies(manifest *PackageManifest, dev bool)
 }
 
 // executeHooks выполняет хуки жизненного цикла
-func (pm *PackageManager) executeHooks(hooks *PackageHooks, commands []string, workDir string) error {
+func (pm *PackageManager) executeHooks(ctx context.Context, hooks *PackageHooks, commands []string, workDir string) error {
 	if hooks == nil || len(commands) == 0 {
 		return nil
 	}
 
 	for _, command := range commands {
-		cmd := exec.Command("sh", "-c", command)
+		cmd := shellCommand(ctx, command)
 		if workDir != "" {
 			cmd.Dir = workDir
 		}
 
 		if err := cmd.Run(); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
 			return fmt.Errorf("hook command failed: %s: %w", command, err)
 		}
 	}
@@ -742,7 +692,15 @@ func (pm *PackageManager) executeHooks(hooks *PackageHooks, commands []string, w
 
 // Дополнительные методы для полноты реализации будут добавлены в следующих частях...
 
-// Close освобождает ресурсы
+// Close освобождает ресурсы и удаляет рабочие директории незавершенных операций
 func (pm *PackageManager) Close() error {
+	pm.rateLimitersMutex.Lock()
+	for _, limiter := range pm.rateLimiters {
+		limiter.Close()
+	}
+	pm.rateLimitersMutex.Unlock()
+
+	pm.closeWorkDirs()
+
 	return pm.archiveManager.Close()
 }
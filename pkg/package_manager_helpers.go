@@ -1,15 +1,14 @@
 package pkg
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
 // loadInstalledPackages загружает информацию об установленных пакетах
@@ -99,7 +98,15 @@ func (pm *PackageManager) removePackageInfo(packageName string) error {
 	return os.Remove(infoPath)
 }
 
-// copyFiles копирует файлы из исходной директории в целевую
+// embeddedFiles служебные файлы, которые упаковщик кладет в корень архива
+var embeddedFiles = map[string]bool{
+	MetadataFileName:   true,
+	SBOMFileName:       true,
+	ProvenanceFileName: true,
+}
+
+// copyFiles копирует файлы из исходной директории в целевую. Служебные файлы
+// архива не копируются, символические ссылки переносятся как ссылки
 func (pm *PackageManager) copyFiles(srcDir, dstDir string, files []string) error {
 	for _, pattern := range files {
 		matches, err := filepath.Glob(filepath.Join(srcDir, pattern))
@@ -107,38 +114,62 @@ func (pm *PackageManager) copyFiles(srcDir, dstDir string, files []string) error
 			return err
 		}
 
-		for _, src := range matches {
-			rel, err := filepath.Rel(srcDir, src)
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(src string, entry os.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				rel, err := filepath.Rel(srcDir, src)
+				if err != nil {
+					return err
+				}
+				if embeddedFiles[rel] {
+					return nil
+				}
+
+				return pm.copyFile(src, filepath.Join(dstDir, rel))
+			})
 			if err != nil {
 				return err
 			}
-
-			dst := filepath.Join(dstDir, rel)
-
-			if err := pm.copyFile(src, dst); err != nil {
-				return err
-			}
 		}
 	}
 
 	return nil
 }
 
-// copyFile копирует отдельный файл
+// copyFile копирует отдельный файл, создает директорию или повторяет
+// символическую ссылку, не переходя по ней
 func (pm *PackageManager) copyFile(src, dst string) error {
-	srcInfo, err := os.Stat(src)
+	srcInfo, err := os.Lstat(src)
 	if err != nil {
 		return err
 	}
 
 	if srcInfo.IsDir() {
-		return os.MkdirAll(dst, srcInfo.Mode())
+		return os.MkdirAll(dst, srcInfo.Mode().Perm()|0700)
 	}
 
 	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
 		return err
 	}
 
+	if srcInfo.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return os.Symlink(target, dst)
+	}
+
+	if !srcInfo.Mode().IsRegular() {
+		return fmt.Errorf("unsupported file type: %s", src)
+	}
+
 	srcFile, err := os.Open(src)
 	if err != nil {
 		return err
@@ -178,155 +209,114 @@ func (pm *PackageManager) calculateDirSize(dir string) int64 {
 	return size
 }
 
-// searchInRepository выполняет поиск в репозитории
-func (pm *PackageManager) searchInRepository(repo Repository, query string) ([]SearchResult, error) {
-	// Используем API v1 criage-server
-	url := fmt.Sprintf("%s/api/v1/search?q=%s", repo.URL, query)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	if repo.AuthToken != "" {
-		req.Header.Set("Authorization", "Bearer "+repo.AuthToken)
-	}
-
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
-
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("search failed: HTTP %d", resp.StatusCode)
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, err
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("API error: %s", apiResp.Error)
-	}
-
-	// Парсим данные поиска
-	searchData, ok := apiResp.Data.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected API response format")
-	}
-
-	resultsData, ok := searchData["results"].([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected search results format")
-	}
-
-	var results []SearchResult
-	for _, resultItem := range resultsData {
-		resultBytes, err := json.Marshal(resultItem)
-		if err != nil {
-			continue
-		}
-
-		var result SearchResult
-		if err := json.Unmarshal(resultBytes, &result); err != nil {
-			continue
-		}
-
-		// В current common/types SearchResult не содержит поля Repository
-		// Если нужно сохранять имя репозитория, можно расширить тип, но здесь пропускаем
-		results = append(results, result)
-	}
-
-	return results, nil
-}
-
-// executeBuildScript выполняет скрипт сборки
-func (pm *PackageManager) executeBuildScript(manifest *BuildManifest) error {
-	cmd := exec.Command("sh", "-c", manifest.BuildScript)
-
-	// Устанавливаем переменные окружения, если BuildManifest поддерживает Environment
-	env := os.Environ()
+// executeBuildScript выполняет скрипт сборки с окружением env
+func (pm *PackageManager) executeBuildScript(ctx context.Context, script string, env []string) error {
+	cmd := shellCommand(ctx, script)
 	cmd.Env = env
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	return cmd.Run()
-}
-
-// uploadPackage загружает пакет в репозиторий
-func (pm *PackageManager) uploadPackage(registryURL, token, archivePath string, manifest *PackageManifest) error {
-	// Используем API v1 criage-server
-	url := fmt.Sprintf("%s/api/v1/upload", registryURL)
-
-	// Открываем файл для загрузки
-	file, err := os.Open(archivePath)
-	if err != nil {
-		return fmt.Errorf("failed to open file: %w", err)
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
 	}
-	defer file.Close()
-
-	// Создаем multipart form
-	var body bytes.Buffer
-	writer := multipart.NewWriter(&body)
+	return nil
+}
 
-	// Добавляем файл в form
-	part, err := writer.CreateFormFile("package", filepath.Base(archivePath))
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
+// shellCommandWaitDelay время, которое команда получает на завершение после
+// сигнала прерывания, прежде чем будет остановлена принудительно
+const shellCommandWaitDelay = 5 * time.Second
+
+// shellCommand создает команду оболочки, прерываемую контекстом. При отмене
+// команда сначала получает os.Interrupt, чтобы успеть убрать за собой
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	cmd.Cancel = func() error {
+		if runtime.GOOS == "windows" {
+			return cmd.Process.Kill()
+		}
+		return cmd.Process.Signal(os.Interrupt)
 	}
+	cmd.WaitDelay = shellCommandWaitDelay
+	return cmd
+}
 
-	if _, err := io.Copy(part, file); err != nil {
-		return fmt.Errorf("failed to copy file: %w", err)
+// newWorkDir создает рабочую директорию операции в TempPath. Директория
+// удаляется removeWorkDir, а если операция была прервана - при Close
+func (pm *PackageManager) newWorkDir(prefix string) (string, error) {
+	tempPath := pm.configManager.GetConfig().TempPath
+	if err := os.MkdirAll(tempPath, 0755); err != nil {
+		return "", err
 	}
 
-	writer.Close()
-
-	req, err := http.NewRequest("POST", url, &body)
+	dir, err := os.MkdirTemp(tempPath, prefix+"_")
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Устанавливаем заголовки
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+		return "", err
 	}
 
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
+	pm.workDirsMutex.Lock()
+	pm.workDirs[dir] = struct{}{}
+	pm.workDirsMutex.Unlock()
+	return dir, nil
+}
 
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to upload: %w", err)
-	}
-	defer resp.Body.Close()
+// removeWorkDir удаляет рабочую директорию операции
+func (pm *PackageManager) removeWorkDir(dir string) {
+	os.RemoveAll(dir)
 
-	// Проверяем статус ответа
-	if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("invalid authorization token")
-	}
+	pm.workDirsMutex.Lock()
+	delete(pm.workDirs, dir)
+	pm.workDirsMutex.Unlock()
+}
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("upload failed: HTTP %d", resp.StatusCode)
+// uploadPackage загружает архив пакета для цели target в репозиторий. registry - имя
+// или адрес репозитория; для репозитория из конфигурации без явного токена
+// используются сохраненные учетные данные
+func (pm *PackageManager) uploadPackage(ctx context.Context, registry, token, archivePath string, manifest *PackageManifest, target BuildTargetConfig) error {
+	if registry == "" {
+		return fmt.Errorf("registry is not specified (use --registry)")
+	}
+
+	client := pm.clientFor(registry, token)
+
+	// Сессия загрузки по частям сохраняется в кеше, чтобы повторный publish продолжил ее
+	name := filepath.Base(archivePath)
+	if err := client.Upload(ctx, archivePath, UploadOptions{
+		Name:     manifest.Name,
+		Version:  manifest.Version,
+		OS:       target.OS,
+		Arch:     target.Arch,
+		Progress: uploadProgressPrinter(name),
+		StateDir: filepath.Join(pm.configManager.GetConfig().CachePath, uploadStateDir),
+	}); err != nil {
+		return fmt.Errorf("upload failed: %w", err)
 	}
+	return nil
+}
 
-	// Читаем ответ
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
-	}
+// uploadStateDir поддиректория кеша с сессиями загрузки по частям
+const uploadStateDir = "uploads"
 
-	if !apiResp.Success {
-		return fmt.Errorf("upload failed: %s", apiResp.Error)
+// uploadProgressPrinter выводит процент загрузки файла name при его изменении
+func uploadProgressPrinter(name string) UploadProgress {
+	last := -1
+	return func(sent, total int64) {
+		percent := 100
+		if total > 0 {
+			percent = int(sent * 100 / total)
+		}
+		if percent == last {
+			return
+		}
+		last = percent
+		fmt.Printf("\rЗагрузка %s: %d%%", name, percent)
+		if percent == 100 {
+			fmt.Println()
+		}
 	}
-
-	return nil
 }
 
 // GetConfigManager возвращает менеджер конфигурации
@@ -334,143 +324,41 @@ func (pm *PackageManager) GetConfigManager() *ConfigManager {
 	return pm.configManager
 }
 
-// GetRepositoryInfo получает информацию о репозитории
-func (pm *PackageManager) GetRepositoryInfo(repositoryURL string) (map[string]interface{}, error) {
-	url := fmt.Sprintf("%s/api/v1/", repositoryURL)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
-
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server error: %d", resp.StatusCode)
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("operation failed: %s", apiResp.Error)
-	}
-
-	infoData, ok := apiResp.Data.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected response format")
+// clientFor возвращает клиент репозитория repository - имени или адреса. Для
+// репозитория из конфигурации используются его учетные данные и rate limiter, token
+// их заменяет; для прочих адресов - только token
+func (pm *PackageManager) clientFor(repository, token string) *RepositoryClient {
+	for _, repo := range pm.configManager.GetRepositories() {
+		if repo.Name != repository && normalizeRepositoryURL(repo.URL) != normalizeRepositoryURL(repository) {
+			continue
+		}
+		if token != "" {
+			repo.AuthToken, repo.Username, repo.Password = token, "", ""
+		}
+		return pm.repositoryClient(repo)
 	}
-
-	return infoData, nil
+	client := NewRepositoryClient(repository, token, pm.httpClient, pm.rateLimiterFor(repository))
+	client.transferClient = pm.transferClient
+	return client
 }
 
-// GetRepositoryStats получает статистику репозитория
-func (pm *PackageManager) GetRepositoryStats(repositoryURL string) (*RepositoryStats, error) {
-	url := fmt.Sprintf("%s/api/v1/stats", repositoryURL)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
-
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server error: %d", resp.StatusCode)
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("operation failed: %s", apiResp.Error)
-	}
-
-	statsBytes, err := json.Marshal(apiResp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal stats: %w", err)
-	}
-
-	var stats RepositoryStats
-	if err := json.Unmarshal(statsBytes, &stats); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal stats: %w", err)
-	}
-
-	return &stats, nil
+// GetRepositoryInfo получает информацию о репозитории (имя или адрес)
+func (pm *PackageManager) GetRepositoryInfo(ctx context.Context, repository string) (map[string]interface{}, error) {
+	return pm.clientFor(repository, "").Info(ctx)
 }
 
-// RefreshRepositoryIndex обновляет индекс пакетов в репозитории
-func (pm *PackageManager) RefreshRepositoryIndex(repositoryURL, authToken string) error {
-	url := fmt.Sprintf("%s/api/v1/refresh", repositoryURL)
-
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	if authToken != "" {
-		req.Header.Set("Authorization", "Bearer "+authToken)
-	}
-	req.Header.Set("Content-Type", "application/json")
-
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
-
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusUnauthorized {
-		return fmt.Errorf("invalid authorization token")
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server error: %d", resp.StatusCode)
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResp.Success {
-		return fmt.Errorf("operation failed: %s", apiResp.Error)
-	}
-
-	return nil
+// GetRepositoryStats получает статистику репозитория (имя или адрес)
+func (pm *PackageManager) GetRepositoryStats(ctx context.Context, repository string) (*RepositoryStats, error) {
+	return pm.clientFor(repository, "").Stats(ctx)
 }
 
-// PackageListResponse структура ответа для списка пакетов
-type PackageListResponse struct {
-	Packages   []*PackageEntry `json:"packages"`
-	Total      int             `json:"total"`
-	Page       int             `json:"page"`
-	Limit      int             `json:"limit"`
-	TotalPages int             `json:"total_pages"`
+// RefreshRepositoryIndex обновляет индекс пакетов в репозитории (имя или адрес)
+func (pm *PackageManager) RefreshRepositoryIndex(ctx context.Context, repository, authToken string) error {
+	return pm.clientFor(repository, authToken).Refresh(ctx)
 }
 
 // ListRepositoryPackages получает список всех пакетов из репозитория с пагинацией
-func (pm *PackageManager) ListRepositoryPackages(repositoryURL string, page, limit int) (*PackageListResponse, error) {
+func (pm *PackageManager) ListRepositoryPackages(ctx context.Context, repository string, page, limit int) (*PackageListResponse, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -478,94 +366,25 @@ func (pm *PackageManager) ListRepositoryPackages(repositoryURL string, page, lim
 		limit = 20
 	}
 
-	url := fmt.Sprintf("%s/api/v1/packages?page=%d&limit=%d", repositoryURL, page, limit)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
-
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server error: %d", resp.StatusCode)
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("operation failed: %s", apiResp.Error)
-	}
-
-	// Парсим данные списка пакетов
-	responseBytes, err := json.Marshal(apiResp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal response data: %w", err)
-	}
-
-	var packageList PackageListResponse
-	if err := json.Unmarshal(responseBytes, &packageList); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal package list: %w", err)
-	}
-
-	return &packageList, nil
+	return pm.clientFor(repository, "").ListPackages(ctx, page, limit)
 }
 
 // GetPackageVersion получает информацию о конкретной версии пакета
-func (pm *PackageManager) GetPackageVersion(repositoryURL, packageName, version string) (*VersionEntry, error) {
-	url := fmt.Sprintf("%s/api/v1/packages/%s/%s", repositoryURL, packageName, version)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Применяем rate limiting
-	pm.rateLimiter.Wait()
-
-	resp, err := pm.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == http.StatusNotFound {
+func (pm *PackageManager) GetPackageVersion(ctx context.Context, repository, packageName, version string) (*VersionEntry, error) {
+	entry, err := pm.clientFor(repository, "").GetPackageVersion(ctx, packageName, version)
+	if IsNotFound(err) {
 		return nil, fmt.Errorf("package version not found: %s@%s", packageName, version)
 	}
+	return entry, err
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server error: %d", resp.StatusCode)
-	}
-
-	var apiResp ApiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
-	}
-
-	if !apiResp.Success {
-		return nil, fmt.Errorf("operation failed: %s", apiResp.Error)
-	}
-
-	// Парсим данные версии пакета
-	versionBytes, err := json.Marshal(apiResp.Data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal version data: %w", err)
-	}
+// closeWorkDirs удаляет рабочие директории прерванных операций
+func (pm *PackageManager) closeWorkDirs() {
+	pm.workDirsMutex.Lock()
+	defer pm.workDirsMutex.Unlock()
 
-	var versionEntry VersionEntry
-	if err := json.Unmarshal(versionBytes, &versionEntry); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal version entry: %w", err)
+	for dir := range pm.workDirs {
+		os.RemoveAll(dir)
+		delete(pm.workDirs, dir)
 	}
-
-	return &versionEntry, nil
 }
@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestPackageManager создает пакетный менеджер с кешем и временной директорией в dir
+func newTestPackageManager(dir string) *PackageManager {
+	return &PackageManager{
+		configManager: &ConfigManager{config: &Config{
+			CachePath: filepath.Join(dir, "cache"),
+			TempPath:  filepath.Join(dir, "temp"),
+		}},
+		installedPackages: make(map[string]*PackageInfo),
+		httpClient:        http.DefaultClient,
+		rateLimiters:      make(map[string]*RateLimiter),
+		workDirs:          make(map[string]struct{}),
+	}
+}
+
+// TestDownloadPackageCancel проверяет, что прерванное скачивание не оставляет файлов в кеше
+func TestDownloadPackageCancel(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial archive"))
+		w.(http.Flusher).Flush()
+		cancel()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	pm := newTestPackageManager(t.TempDir())
+	resolved := &resolvedPackage{
+		repository: Repository{Name: "test", URL: server.URL, Enabled: true},
+		entry:      &PackageEntry{Name: "pkg"},
+		version:    &VersionEntry{Version: "1.0.0"},
+		file:       &FileEntry{Filename: "pkg.tar.zst"},
+	}
+
+	if _, err := pm.downloadPackage(ctx, resolved); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	entries, err := os.ReadDir(pm.configManager.GetCachePath("pkg", "1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected empty cache directory, got %d entries", len(entries))
+	}
+}
+
+// TestFindInRepositoryLatest проверяет, что без версии выбирается наибольшая версия,
+// а не последняя в ответе сервера
+func TestFindInRepositoryLatest(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	files := []FileEntry{{OS: "linux", Arch: "amd64", Filename: "pkg.tar.zst"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ApiResponse{Success: true, Data: PackageEntry{Name: "pkg", Versions: []VersionEntry{
+			{Version: "2.0.0", Files: files},
+			{Version: "10.0.0", Files: files},
+			{Version: "9.0.0", Files: files},
+		}}})
+	}))
+	defer server.Close()
+
+	pm := newTestPackageManager(t.TempDir())
+	repo := Repository{Name: "test", URL: server.URL, Enabled: true}
+	for version, expected := range map[string]string{"": "10.0.0", "^9": "9.0.0", "2.0.0": "2.0.0"} {
+		resolved, err := pm.findInRepository(context.Background(), repo, "pkg", version, "amd64", "linux")
+		if err != nil {
+			t.Fatalf("findInRepository(%q) failed: %v", version, err)
+		}
+		if resolved.version.Version != expected {
+			t.Errorf("findInRepository(%q) = %s, expected %s", version, resolved.version.Version, expected)
+		}
+	}
+}
+
+// TestWorkDirCleanup проверяет удаление рабочих директорий при Close
+func TestWorkDirCleanup(t *testing.T) {
+	pm := newTestPackageManager(t.TempDir())
+
+	removed, err := pm.newWorkDir("install_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm.removeWorkDir(removed)
+
+	left, err := pm.newWorkDir("install_b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm.closeWorkDirs()
+
+	for _, dir := range []string{removed, left} {
+		if _, err := os.Stat(dir); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", dir)
+		}
+	}
+	if len(pm.workDirs) != 0 {
+		t.Errorf("expected no tracked work directories, got %d", len(pm.workDirs))
+	}
+}
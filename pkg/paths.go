@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configPathKeys ключи конфигурации, содержащие пути
+var configPathKeys = []string{
+	"global_path", "local_path", "cache_path", "temp_path",
+	"network.ca_bundle", "network.client_cert", "network.client_key",
+}
+
+// ExpandPath раскрывает в пути ~, $VAR и ${VAR} и делает его абсолютным
+// относительно baseDir (по умолчанию текущая директория)
+func ExpandPath(path, baseDir string) (string, error) {
+	var missing []string
+	expanded := os.Expand(path, func(name string) string {
+		value, exists := os.LookupEnv(name)
+		if !exists {
+			missing = append(missing, name)
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined environment variable %s in path %s", missing[0], path)
+	}
+
+	if expanded == "~" || strings.HasPrefix(expanded, "~/") {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		expanded = filepath.Join(homeDir, strings.TrimPrefix(expanded, "~"))
+	} else if strings.HasPrefix(expanded, "~") {
+		return "", fmt.Errorf("unsupported home directory reference in path %s", path)
+	}
+
+	if !filepath.IsAbs(expanded) {
+		if baseDir == "" {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return "", fmt.Errorf("failed to get working directory: %w", err)
+			}
+			baseDir = cwd
+		}
+		expanded = filepath.Join(baseDir, expanded)
+	}
+
+	return filepath.Clean(expanded), nil
+}
+
+// normalizeConfigPaths раскрывает все пути конфигурации. baseDirs задает директорию,
+// относительно которой разрешается относительный путь ключа (по умолчанию текущая)
+func normalizeConfigPaths(config *Config, baseDirs map[string]string) error {
+	for _, key := range configPathKeys {
+		field := configPathField(config, key)
+		if *field == "" {
+			continue
+		}
+		expanded, err := ExpandPath(*field, baseDirs[key])
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+		*field = expanded
+	}
+	return nil
+}
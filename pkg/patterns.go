@@ -0,0 +1,109 @@
+package pkg
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// filePattern шаблон пути в стиле .gitignore
+type filePattern struct {
+	// segments части шаблона между /; "**" соответствует любому числу директорий
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// patternList список шаблонов; при совпадении нескольких действует последний
+type patternList []filePattern
+
+// compilePatterns разбирает шаблоны в стиле .gitignore:
+//   - пустые строки и строки, начинающиеся с #, пропускаются;
+//   - ! в начале отменяет совпадение предыдущих шаблонов;
+//   - / в конце ограничивает шаблон директориями;
+//   - шаблон без / в начале или середине сопоставляется с именем на любой глубине,
+//     иначе - с путем от корня;
+//   - * и ? не совпадают с /, ** совпадает с любым числом директорий
+func compilePatterns(patterns []string) (patternList, error) {
+	var list patternList
+	for _, raw := range patterns {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var p filePattern
+		if strings.HasPrefix(line, "!") {
+			p.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		line = strings.TrimPrefix(line, "./")
+
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+		if line == "" {
+			return nil, fmt.Errorf("invalid pattern %q", raw)
+		}
+
+		p.segments = strings.Split(line, "/")
+		if !anchored {
+			p.segments = append([]string{"**"}, p.segments...)
+		}
+		for _, segment := range p.segments {
+			if _, err := path.Match(segment, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", raw, err)
+			}
+		}
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// match возвращает, выбран ли путь relPath (с разделителем /) списком шаблонов.
+// Если ни один шаблон не совпал с самим путем, действует результат родительской
+// директории inherited
+func (l patternList) match(relPath string, isDir, inherited bool) bool {
+	names := strings.Split(relPath, "/")
+	result := inherited
+	for _, p := range l {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, names) {
+			result = !p.negate
+		}
+	}
+	return result
+}
+
+// matchSegments сопоставляет части шаблона с частями пути
+func matchSegments(pattern, names []string) bool {
+	if len(pattern) == 0 {
+		return len(names) == 0
+	}
+
+	if pattern[0] == "**" {
+		// ** в конце шаблона соответствует содержимому директории, но не ей самой
+		if len(pattern) == 1 {
+			return len(names) > 0
+		}
+		for i := 0; i <= len(names); i++ {
+			if matchSegments(pattern[1:], names[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(names) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern[0], names[0]); !matched {
+		return false
+	}
+	return matchSegments(pattern[1:], names[1:])
+}
@@ -0,0 +1,46 @@
+package pkg
+
+import "testing"
+
+// TestPatternListMatch проверяет семантику шаблонов в стиле .gitignore
+func TestPatternListMatch(t *testing.T) {
+	tests := []struct {
+		patterns  []string
+		path      string
+		isDir     bool
+		inherited bool
+		want      bool
+	}{
+		{patterns: []string{"*.log"}, path: "debug.log", want: true},
+		{patterns: []string{"*.log"}, path: "logs/app/debug.log", want: true},
+		{patterns: []string{"/*.log"}, path: "logs/debug.log", want: false},
+		{patterns: []string{"/*.log"}, path: "debug.log", want: true},
+		{patterns: []string{"docs/*.md"}, path: "docs/a.md", want: true},
+		{patterns: []string{"docs/*.md"}, path: "docs/api/a.md", want: false},
+		{patterns: []string{"docs/**/*.md"}, path: "docs/api/v1/a.md", want: true},
+		{patterns: []string{"**/testdata"}, path: "pkg/testdata", isDir: true, want: true},
+		{patterns: []string{"bin/**"}, path: "bin", isDir: true, want: false},
+		{patterns: []string{"bin/**"}, path: "bin/app", want: true},
+		{patterns: []string{"build/"}, path: "build", isDir: true, want: true},
+		{patterns: []string{"build/"}, path: "build", want: false},
+		{patterns: []string{"*.log", "!keep.log"}, path: "keep.log", want: false},
+		{patterns: []string{"!keep.log", "*.log"}, path: "keep.log", want: true},
+		{patterns: []string{"# comment", "", "tmp"}, path: "tmp", want: true},
+		{patterns: []string{"*.txt"}, path: "bin/app", inherited: true, want: true},
+		{patterns: []string{"!bin/*.debug"}, path: "bin/app.debug", inherited: true, want: false},
+	}
+
+	for _, tt := range tests {
+		list, err := compilePatterns(tt.patterns)
+		if err != nil {
+			t.Fatalf("compilePatterns(%v): %v", tt.patterns, err)
+		}
+		if got := list.match(tt.path, tt.isDir, tt.inherited); got != tt.want {
+			t.Errorf("match(%v, %q, dir=%v) = %v, want %v", tt.patterns, tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if _, err := compilePatterns([]string{"[a-"}); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
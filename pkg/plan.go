@@ -0,0 +1,279 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+)
+
+// PlanAction тип действия над пакетом в плане
+type PlanAction string
+
+const (
+	PlanAdd       PlanAction = "add"
+	PlanUpgrade   PlanAction = "upgrade"
+	PlanDowngrade PlanAction = "downgrade"
+	PlanReinstall PlanAction = "reinstall"
+	PlanRemove    PlanAction = "remove"
+)
+
+// PlanHook команда хука, которая будет выполнена
+type PlanHook struct {
+	Stage   string `json:"stage"`
+	Command string `json:"command"`
+}
+
+// PlanStep одно действие над пакетом
+type PlanStep struct {
+	Action       PlanAction `json:"action"`
+	Name         string     `json:"name"`
+	FromVersion  string     `json:"from_version,omitempty"`
+	ToVersion    string     `json:"to_version,omitempty"`
+	Repository   string     `json:"repository,omitempty"`
+	DownloadURL  string     `json:"download_url,omitempty"`
+	DownloadSize int64      `json:"download_size"`
+	DiskDelta    int64      `json:"disk_delta"`
+	Cached       bool       `json:"cached"`
+	Global       bool       `json:"global"`
+	Dependency   bool       `json:"dependency"`
+	Hooks        []PlanHook `json:"hooks,omitempty"`
+	// HooksKnown false, если хуки нельзя определить без скачивания архива
+	HooksKnown bool `json:"hooks_known"`
+}
+
+// Plan описывает изменения, которые выполнит операция установки, обновления или удаления
+type Plan struct {
+	Operation    string      `json:"operation"`
+	Steps        []*PlanStep `json:"steps"`
+	DownloadSize int64       `json:"download_size"`
+	DiskDelta    int64       `json:"disk_delta"`
+}
+
+// NewPlan создает пустой план для операции
+func NewPlan(operation string) *Plan {
+	return &Plan{
+		Operation: operation,
+		Steps:     []*PlanStep{},
+	}
+}
+
+// AddStep добавляет действие в план и пересчитывает итоги
+func (p *Plan) AddStep(step *PlanStep) {
+	p.Steps = append(p.Steps, step)
+	p.DownloadSize += step.DownloadSize
+	p.DiskDelta += step.DiskDelta
+}
+
+// Merge добавляет в план действия другого плана
+func (p *Plan) Merge(other *Plan) {
+	for _, step := range other.Steps {
+		if p.Step(step.Name) == nil {
+			p.AddStep(step)
+		}
+	}
+}
+
+// Step возвращает действие над пакетом или nil
+func (p *Plan) Step(name string) *PlanStep {
+	for _, step := range p.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return nil
+}
+
+// StepsByAction возвращает действия указанного типа
+func (p *Plan) StepsByAction(action PlanAction) []*PlanStep {
+	var steps []*PlanStep
+	for _, step := range p.Steps {
+		if step.Action == action {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// IsEmpty возвращает true, если план не содержит изменений
+func (p *Plan) IsEmpty() bool {
+	return len(p.Steps) == 0
+}
+
+// JSON возвращает план в формате JSON
+func (p *Plan) JSON() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// PlanInstall строит план установки пакета и его зависимостей, ничего не скачивая и не изменяя
+func (pm *PackageManager) PlanInstall(ctx context.Context, packageName, version string, global, force, dev bool, arch, osName string) (*Plan, error) {
+	if arch == "" {
+		arch = runtime.GOARCH
+	}
+	if osName == "" {
+		osName = runtime.GOOS
+	}
+
+	plan := NewPlan("install")
+	if err := pm.planInstall(ctx, plan, packageName, version, global, force, dev, false, arch, osName); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// PlanUpdate строит план обновления пакета до последней версии
+func (pm *PackageManager) PlanUpdate(ctx context.Context, packageName string) (*Plan, error) {
+	packageInfo, exists := pm.getInstalledPackage(packageName)
+	if !exists {
+		return nil, fmt.Errorf("package not installed: %s", packageName)
+	}
+
+	resolved, err := pm.resolvePackage(ctx, packageName, "", runtime.GOARCH, runtime.GOOS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find latest version: %w", err)
+	}
+
+	plan := NewPlan("update")
+	if CompareVersions(packageInfo.Version, resolved.version.Version) >= 0 {
+		return plan, nil
+	}
+
+	if err := pm.planInstall(ctx, plan, packageName, resolved.version.Version, packageInfo.Global, true, false, false, runtime.GOARCH, runtime.GOOS); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// PlanUninstall строит план удаления пакета
+func (pm *PackageManager) PlanUninstall(ctx context.Context, packageName string, global bool) (*Plan, error) {
+	packageInfo, exists := pm.getInstalledPackage(packageName)
+	if !exists {
+		return nil, fmt.Errorf("%s", T("package_not_installed", packageName))
+	}
+
+	step := &PlanStep{
+		Action:      PlanRemove,
+		Name:        packageName,
+		FromVersion: packageInfo.Version,
+		DiskDelta:   -packageInfo.Size,
+		Global:      packageInfo.Global,
+	}
+
+	if manifest, err := pm.loadManifestFromDir(packageInfo.InstallPath); err == nil {
+		step.HooksKnown = true
+		if manifest.Hooks != nil {
+			step.Hooks = append(step.Hooks, planHooks("preRemove", manifest.Hooks.PreRemove)...)
+			step.Hooks = append(step.Hooks, planHooks("postRemove", manifest.Hooks.PostRemove)...)
+		}
+	}
+
+	plan := NewPlan("uninstall")
+	plan.AddStep(step)
+	return plan, nil
+}
+
+// planInstall добавляет в план установку пакета и рекурсивно его зависимостей
+// по тем же правилам, что и InstallPackage
+func (pm *PackageManager) planInstall(ctx context.Context, plan *Plan, packageName, version string, global, force, dev, dependency bool, arch, osName string) error {
+	if plan.Step(packageName) != nil {
+		return nil
+	}
+
+	installed, isInstalled := pm.getInstalledPackage(packageName)
+	if isInstalled && !force && (version == "" || installed.Version == version) {
+		return nil
+	}
+
+	resolved, err := pm.resolvePackage(ctx, packageName, version, arch, osName)
+	if err != nil {
+		return fmt.Errorf(T("error_failed_to_find"), err)
+	}
+
+	installSize := resolved.version.Size
+	if installSize == 0 {
+		installSize = resolved.file.Size
+	}
+
+	step := &PlanStep{
+		Action:       PlanAdd,
+		Name:         packageName,
+		ToVersion:    resolved.version.Version,
+		Repository:   resolved.repository.Name,
+		DownloadURL:  resolved.downloadURL,
+		DownloadSize: resolved.file.Size,
+		DiskDelta:    installSize,
+		Global:       global,
+		Dependency:   dependency,
+	}
+
+	if isInstalled {
+		step.FromVersion = installed.Version
+		step.DiskDelta = installSize - installed.Size
+		switch c := CompareVersions(resolved.version.Version, installed.Version); {
+		case c > 0:
+			step.Action = PlanUpgrade
+		case c < 0:
+			step.Action = PlanDowngrade
+		default:
+			step.Action = PlanReinstall
+		}
+	}
+
+	// Хуки известны только если архив уже есть в кеше
+	archivePath := pm.cachedArchivePath(packageName, resolved.version.Version)
+	if _, err := os.Stat(archivePath); err == nil {
+		step.Cached = true
+		step.DownloadSize = 0
+		format := pm.archiveManager.DetectFormat(archivePath)
+		if metadata, err := pm.archiveManager.ExtractMetadataFromArchive(archivePath, format); err == nil && metadata.PackageManifest != nil {
+			step.HooksKnown = true
+			if hooks := metadata.PackageManifest.Hooks; hooks != nil {
+				step.Hooks = append(step.Hooks, planHooks("preInstall", hooks.PreInstall)...)
+				step.Hooks = append(step.Hooks, planHooks("postInstall", hooks.PostInstall)...)
+			}
+		}
+	}
+
+	plan.AddStep(step)
+
+	dependencies := make(map[string]string)
+	for name, constraint := range resolved.version.Dependencies {
+		dependencies[name] = constraint
+	}
+	if dev {
+		for name, constraint := range resolved.version.DevDeps {
+			dependencies[name] = constraint
+		}
+	}
+
+	depNames := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		depNames = append(depNames, name)
+	}
+	sort.Strings(depNames)
+
+	// Зависимости устанавливаются только если они отсутствуют
+	for _, depName := range depNames {
+		if _, exists := pm.getInstalledPackage(depName); exists {
+			continue
+		}
+		if err := pm.planInstall(ctx, plan, depName, dependencies[depName], false, false, false, true, arch, osName); err != nil {
+			return fmt.Errorf("failed to resolve dependency %s: %w", depName, err)
+		}
+	}
+
+	return nil
+}
+
+// planHooks преобразует команды хука в элементы плана
+func planHooks(stage string, commands []string) []PlanHook {
+	hooks := make([]PlanHook, 0, len(commands))
+	for _, command := range commands {
+		hooks = append(hooks, PlanHook{Stage: stage, Command: command})
+	}
+	return hooks
+}
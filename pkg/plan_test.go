@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// newPlanTestPackageManager создает пакетный менеджер с репозиторием, в котором есть
+// пакет app 1.0.0 и 2.0.0 (2.0.0 зависит от lib) и пакет lib 1.0.0
+func newPlanTestPackageManager(t *testing.T) *PackageManager {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+
+	files := []FileEntry{{OS: runtime.GOOS, Arch: runtime.GOARCH, Filename: "pkg.tar.zst", Size: 100}}
+	entries := map[string]PackageEntry{
+		"app": {Name: "app", Versions: []VersionEntry{
+			{Version: "1.0.0", Files: files, Size: 300},
+			{Version: "2.0.0", Files: files, Size: 500, Dependencies: map[string]string{"lib": "^1.0.0"}},
+		}},
+		"lib": {Name: "lib", Versions: []VersionEntry{{Version: "1.0.0", Files: files, Size: 200}}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry, exists := entries[strings.TrimPrefix(r.URL.Path, "/api/v1/packages/")]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ApiResponse{Error: "package not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(ApiResponse{Success: true, Data: entry})
+	}))
+	t.Cleanup(server.Close)
+
+	pm := newTestPackageManager(t.TempDir())
+	pm.configManager.config.Repositories = []Repository{{Name: "main", URL: server.URL, Enabled: true}}
+	return pm
+}
+
+// TestPlanInstall проверяет план установки пакета с зависимостью
+func TestPlanInstall(t *testing.T) {
+	pm := newPlanTestPackageManager(t)
+
+	plan, err := pm.PlanInstall(context.Background(), "app", "", true, false, false, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, lib := plan.Step("app"), plan.Step("lib")
+	if app == nil || app.Action != PlanAdd || app.ToVersion != "2.0.0" || !app.Global || app.Dependency {
+		t.Errorf("unexpected app step: %+v", app)
+	}
+	if lib == nil || lib.Action != PlanAdd || !lib.Dependency || lib.Global {
+		t.Errorf("unexpected lib step: %+v", lib)
+	}
+	if plan.DownloadSize != 200 || plan.DiskDelta != 700 {
+		t.Errorf("unexpected totals: download %d, disk %d", plan.DownloadSize, plan.DiskDelta)
+	}
+}
+
+// TestPlanUpdate проверяет план обновления установленного пакета
+func TestPlanUpdate(t *testing.T) {
+	pm := newPlanTestPackageManager(t)
+	pm.installedPackages["app"] = &PackageInfo{Name: "app", Version: "1.0.0", Size: 300, Global: true}
+	pm.installedPackages["lib"] = &PackageInfo{Name: "lib", Version: "1.0.0", Size: 200}
+
+	plan, err := pm.PlanUpdate(context.Background(), "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 1 {
+		t.Fatalf("expected only app to be updated, got %d steps", len(plan.Steps))
+	}
+	step := plan.Steps[0]
+	if step.Action != PlanUpgrade || step.FromVersion != "1.0.0" || step.ToVersion != "2.0.0" || step.DiskDelta != 200 || !step.Global {
+		t.Errorf("unexpected update step: %+v", step)
+	}
+
+	for _, version := range []string{"2.0.0", "3.0.0"} {
+		pm.installedPackages["app"].Version = version
+		if plan, err = pm.PlanUpdate(context.Background(), "app"); err != nil || !plan.IsEmpty() {
+			t.Errorf("expected empty plan for installed %s, got %+v, %v", version, plan, err)
+		}
+	}
+}
+
+// TestPlanUninstall проверяет план удаления с хуками
+func TestPlanUninstall(t *testing.T) {
+	pm := newPlanTestPackageManager(t)
+	installPath := t.TempDir()
+	manifest := "name: app\nversion: 1.0.0\nhooks:\n  preRemove: [echo bye]\n"
+	if err := os.WriteFile(filepath.Join(installPath, LocalConfigName), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pm.installedPackages["app"] = &PackageInfo{Name: "app", Version: "1.0.0", Size: 300, Global: true, InstallPath: installPath}
+
+	plan, err := pm.PlanUninstall(context.Background(), "app", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := plan.Step("app")
+	if step == nil || step.Action != PlanRemove || step.DiskDelta != -300 || !step.HooksKnown ||
+		len(step.Hooks) != 1 || step.Hooks[0].Command != "echo bye" {
+		t.Errorf("unexpected uninstall step: %+v", step)
+	}
+
+	if _, err := pm.PlanUninstall(context.Background(), "tool", false); err == nil {
+		t.Error("expected error for a package that is not installed")
+	}
+}
@@ -0,0 +1,195 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ProvenanceFileName файл документа происхождения пакета в корне архива
+const ProvenanceFileName = ".criage-provenance.json"
+
+// ProvenanceBuildType тип сборки документа происхождения
+const ProvenanceBuildType = "https://criage.ru/provenance/build/v1"
+
+// Provenance документ происхождения пакета: из какого исходного кода, чем и как
+// был собран архив
+type Provenance struct {
+	BuildType string            `json:"buildType"`
+	Builder   ProvenanceBuilder `json:"builder"`
+	Source    ProvenanceSource  `json:"source"`
+	// BuildScript и BuildEnv скрипт сборки и переменные build_env с env цели
+	BuildScript string            `json:"buildScript,omitempty"`
+	BuildEnv    map[string]string `json:"buildEnv,omitempty"`
+	// Target цель архива; Targets все цели, собранные вместе с ним
+	Target       BuildTarget   `json:"target"`
+	Targets      []BuildTarget `json:"targets"`
+	Reproducible bool          `json:"reproducible"`
+	StartedAt    time.Time     `json:"startedAt"`
+	FinishedAt   time.Time     `json:"finishedAt"`
+	// Inputs файлы конфигурации проекта; Outputs файлы, упакованные в архив
+	Inputs  []ProvenanceDigest `json:"inputs"`
+	Outputs []ProvenanceDigest `json:"outputs"`
+}
+
+// ProvenanceBuilder программа, собравшая пакет
+type ProvenanceBuilder struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+}
+
+// ProvenanceSource состояние исходного кода перед сборкой. Пустой VCS означает,
+// что директория проекта не находится под управлением системы контроля версий
+type ProvenanceSource struct {
+	VCS        string `json:"vcs,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Revision   string `json:"revision,omitempty"`
+	Branch     string `json:"branch,omitempty"`
+	// Tree хеш дерева файлов ревизии
+	Tree string `json:"tree,omitempty"`
+	// Dirty рабочая копия содержала незафиксированные изменения
+	Dirty bool `json:"dirty"`
+}
+
+// ProvenanceDigest имя файла и его SHA-256
+type ProvenanceDigest struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// withOutputs возвращает копию документа с выходными файлами files
+func (p *Provenance) withOutputs(files []packedFile) *Provenance {
+	provenance := *p
+	provenance.Outputs = make([]ProvenanceDigest, len(files))
+	for i, file := range files {
+		provenance.Outputs[i] = ProvenanceDigest{Name: file.Name, SHA256: file.SHA256}
+	}
+	return &provenance
+}
+
+// marshal сериализует документ
+func (p *Provenance) marshal() ([]byte, error) {
+	return json.MarshalIndent(p, "", "  ")
+}
+
+// provenanceInputs файлы конфигурации проекта, хеши которых записываются как входные данные
+var provenanceInputs = []string{LocalConfigName, BuildConfigName}
+
+// provenanceInputDigests возвращает SHA-256 существующих файлов конфигурации проекта dir
+func provenanceInputDigests(dir string) ([]ProvenanceDigest, error) {
+	var digests []ProvenanceDigest
+	for _, name := range provenanceInputs {
+		digest, err := fileSHA256(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, ProvenanceDigest{Name: name, SHA256: digest})
+	}
+	return digests, nil
+}
+
+// detectSource определяет состояние исходного кода в директории dir. Если git
+// недоступен или dir не является рабочей копией, возвращается пустой источник
+func detectSource(ctx context.Context, dir string) ProvenanceSource {
+	git := func(args ...string) (string, error) {
+		var stdout bytes.Buffer
+		cmd := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...)
+		cmd.Stdout = &stdout
+		if err := cmd.Run(); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(stdout.String()), nil
+	}
+
+	revision, err := git("rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return ProvenanceSource{}
+	}
+
+	source := ProvenanceSource{VCS: "git", Revision: revision}
+	source.Tree, _ = git("rev-parse", "HEAD^{tree}")
+	if branch, err := git("symbolic-ref", "--short", "-q", "HEAD"); err == nil {
+		source.Branch = branch
+	}
+	if remote, err := git("config", "--get", "remote.origin.url"); err == nil {
+		source.Repository = redactRepositoryURL(remote)
+	}
+
+	// Неотслеживаемые файлы, не указанные в .gitignore, тоже считаются изменениями
+	status, err := git("status", "--porcelain")
+	source.Dirty = err != nil || status != ""
+	return source
+}
+
+// redactRepositoryURL удаляет учетные данные из адреса репозитория
+func redactRepositoryURL(remote string) string {
+	parsed, err := url.Parse(remote)
+	if err != nil || parsed.User == nil || parsed.Scheme == "" {
+		return remote
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// ReadProvenance возвращает документ происхождения из архива пакета или nil,
+// если архив собран без него
+func ReadProvenance(archivePath string, format ArchiveFormat) (*Provenance, error) {
+	data, err := readArchiveFile(archivePath, format, ProvenanceFileName)
+	if errors.Is(err, errArchiveFileNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseProvenance(data)
+}
+
+// parseProvenance разбирает документ происхождения
+func parseProvenance(data []byte) (*Provenance, error) {
+	var provenance Provenance
+	if err := json.Unmarshal(data, &provenance); err != nil {
+		return nil, fmt.Errorf("failed to parse provenance: %w", err)
+	}
+	return &provenance, nil
+}
+
+// checkInstallPolicy проверяет распакованный пакет extractDir на соответствие
+// политике установки install_policy
+func checkInstallPolicy(policy InstallPolicyConfig, extractDir string) error {
+	if !policy.RequireProvenance && !policy.RequireCleanTree {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(extractDir, ProvenanceFileName))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("package has no provenance (install_policy.require_provenance)")
+	}
+	if err != nil {
+		return err
+	}
+	provenance, err := parseProvenance(data)
+	if err != nil {
+		return err
+	}
+
+	if policy.RequireCleanTree {
+		if provenance.Source.Revision == "" {
+			return fmt.Errorf("package was not built from a VCS revision (install_policy.require_clean_tree)")
+		}
+		if provenance.Source.Dirty {
+			return fmt.Errorf("package was built from a modified tree at %s (install_policy.require_clean_tree)", provenance.Source.Revision)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimiterClosed возвращается Wait после закрытия rate limiter
+var ErrRateLimiterClosed = errors.New("rate limiter is closed")
+
+// maxRetryAfter наибольшая пауза Retry-After, которую клиент выжидает сам;
+// при большей паузе запрос завершается ошибкой
+const maxRetryAfter = time.Minute
+
+// RateLimiter ограничивает частоту запросов по алгоритму token bucket: корзина
+// вмещает burst токенов и пополняется со скоростью rate токенов в секунду.
+// Ответы 429 временно снижают скорость и приостанавливают запросы на Retry-After;
+// успешные ответы постепенно возвращают скорость к заданной
+type RateLimiter struct {
+	mu       sync.Mutex
+	baseRate float64
+	rate     float64
+	burst    float64
+	tokens   float64
+	last     time.Time
+	// pausedUntil время, до которого запросы не выполняются (Retry-After)
+	pausedUntil time.Time
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+// NewRateLimiter создает rate limiter на requestsPerSecond запросов в секунду
+// с корзиной на burst запросов. requestsPerSecond <= 0 отключает ограничение
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		baseRate: requestsPerSecond,
+		rate:     requestsPerSecond,
+		burst:    float64(burst),
+		tokens:   float64(burst),
+		last:     time.Now(),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Wait ждет разрешения на выполнение запроса. Возвращает ошибку контекста при
+// отмене ожидания и ErrRateLimiterClosed после Close
+func (rl *RateLimiter) Wait(ctx context.Context) error {
+	select {
+	case <-rl.closed:
+		return ErrRateLimiterClosed
+	default:
+	}
+
+	delay := rl.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		rl.cancelReservation()
+		return ctx.Err()
+	case <-rl.closed:
+		rl.cancelReservation()
+		return ErrRateLimiterClosed
+	}
+}
+
+// reserve забирает токен и возвращает время ожидания до его появления.
+// Баланс может стать отрицательным: так ожидающие запросы выстраиваются в очередь
+func (rl *RateLimiter) reserve() time.Duration {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	var delay time.Duration
+	if rl.pausedUntil.After(now) {
+		delay = rl.pausedUntil.Sub(now)
+	}
+	if rl.rate <= 0 {
+		return delay
+	}
+
+	rl.refill(now)
+	rl.tokens--
+	if rl.tokens < 0 {
+		delay += time.Duration(-rl.tokens / rl.rate * float64(time.Second))
+	}
+	return delay
+}
+
+// cancelReservation возвращает токен, если ожидание было прервано
+func (rl *RateLimiter) cancelReservation() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.rate > 0 {
+		rl.tokens = math.Min(rl.tokens+1, rl.burst)
+	}
+}
+
+// refill пополняет корзину за прошедшее время
+func (rl *RateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(rl.last).Seconds()
+	if elapsed > 0 {
+		rl.tokens = math.Min(rl.burst, rl.tokens+elapsed*rl.rate)
+		rl.last = now
+	}
+}
+
+// Throttle обрабатывает ответ 429: приостанавливает запросы на retryAfter
+// (или на интервал между запросами, если сервер его не указал) и вдвое снижает скорость
+func (rl *RateLimiter) Throttle(retryAfter time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	if rl.rate > 0 {
+		rl.refill(now)
+		rl.rate = math.Max(rl.rate/2, rl.baseRate/16)
+		if retryAfter <= 0 {
+			retryAfter = time.Duration(float64(time.Second) / rl.rate)
+		}
+	}
+	if until := now.Add(retryAfter); until.After(rl.pausedUntil) {
+		rl.pausedUntil = until
+	}
+}
+
+// Success отмечает успешный ответ и постепенно возвращает скорость к заданной
+func (rl *RateLimiter) Success() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.rate > 0 && rl.rate < rl.baseRate {
+		rl.refill(time.Now())
+		rl.rate = math.Min(rl.baseRate, rl.rate+rl.baseRate/10)
+	}
+}
+
+// Rate возвращает текущую скорость с учетом адаптации к ответам 429
+func (rl *RateLimiter) Rate() float64 {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.rate
+}
+
+// Close прерывает ожидающие вызовы Wait; последующие вызовы Wait возвращают ошибку
+func (rl *RateLimiter) Close() {
+	rl.closeOnce.Do(func() {
+		close(rl.closed)
+	})
+}
+
+// parseRetryAfter разбирает заголовок Retry-After: число секунд или HTTP-дату
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// rateLimiterFor возвращает rate limiter репозитория, создавая его по настройкам
+// rate_limit. key - имя репозитория или адрес для репозиториев вне конфигурации
+func (pm *PackageManager) rateLimiterFor(key string) *RateLimiter {
+	pm.rateLimitersMutex.Lock()
+	defer pm.rateLimitersMutex.Unlock()
+
+	if limiter, exists := pm.rateLimiters[key]; exists {
+		return limiter
+	}
+
+	limits := pm.configManager.GetConfig().RateLimit.ForRepository(key)
+	limiter := NewRateLimiter(limits.RequestsPerSecond, limits.Burst)
+	pm.rateLimiters[key] = limiter
+	return limiter
+}
@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestRateLimiterBurstAndCancel проверяет burst, отмену ожидания и закрытие
+func TestRateLimiterBurstAndCancel(t *testing.T) {
+	rl := NewRateLimiter(1, 3)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := rl.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("burst of 3 requests should not wait, took %v", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := rl.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- rl.Wait(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	rl.Close()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrRateLimiterClosed) {
+			t.Errorf("expected ErrRateLimiterClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Close")
+	}
+	if err := rl.Wait(context.Background()); !errors.Is(err, ErrRateLimiterClosed) {
+		t.Errorf("expected ErrRateLimiterClosed after Close, got %v", err)
+	}
+}
+
+// TestRateLimiterRetryAfter проверяет повтор запроса после 429 и снижение скорости
+func TestRateLimiterRetryAfter(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":{}}`))
+	}))
+	defer server.Close()
+
+	rl := NewRateLimiter(100, 10)
+	defer rl.Close()
+
+	start := time.Now()
+	if _, err := NewRepositoryClient(server.URL, "", server.Client(), rl).Info(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("expected client to wait for Retry-After, took %v", elapsed)
+	}
+	if atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("expected 2 requests, got %d", requests)
+	}
+	if rate := rl.Rate(); rate >= 100 {
+		t.Errorf("expected rate to be reduced after 429, got %v", rate)
+	}
+
+	if d := parseRetryAfter("Wed, 21 Oct 2015 07:28:00 GMT", time.Date(2015, 10, 21, 7, 27, 30, 0, time.UTC)); d != 30*time.Second {
+		t.Errorf("expected 30s from HTTP date, got %v", d)
+	}
+}
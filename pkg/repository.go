@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SupportedAPIVersion основная версия API criage-server, с которой работает клиент
+const SupportedAPIVersion = "1"
+
+// RepositoryPing результат проверки доступности репозитория
+type RepositoryPing struct {
+	Name       string        `json:"name"`
+	URL        string        `json:"url"`
+	Latency    time.Duration `json:"latency_ns"`
+	APIVersion string        `json:"api_version,omitempty"`
+	Compatible bool          `json:"compatible"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// RepositoryDetails конфигурация репозитория вместе с информацией сервера
+type RepositoryDetails struct {
+	Repository Repository             `json:"repository"`
+	Server     map[string]interface{} `json:"server,omitempty"`
+	Stats      *RepositoryStats       `json:"stats,omitempty"`
+	RateLimit  RateLimitSettings      `json:"rate_limit"`
+}
+
+// PingRepository измеряет задержку ответа репозитория и проверяет версию API
+func (pm *PackageManager) PingRepository(ctx context.Context, name string) (*RepositoryPing, error) {
+	repo, exists := pm.configManager.GetRepository(name)
+	if !exists {
+		return nil, fmt.Errorf("repository not found: %s", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(pm.configManager.GetConfig().Timeout)*time.Second)
+	defer cancel()
+
+	// Ожидание rate limiter и запуск хранилища учетных данных не должны попадать
+	// в измеренную задержку
+	client := pm.repositoryClient(*repo)
+	client.rateLimiter = nil
+
+	ping := &RepositoryPing{Name: repo.Name, URL: repo.URL}
+	if err := client.resolveCredentials(ctx); err != nil {
+		ping.Error = err.Error()
+		return ping, nil
+	}
+
+	start := time.Now()
+	info, err := client.Info(ctx)
+	ping.Latency = time.Since(start)
+	if err != nil {
+		ping.Error = err.Error()
+		return ping, nil
+	}
+
+	ping.APIVersion = apiVersion(info)
+	ping.Compatible = ping.APIVersion == "" || majorVersion(ping.APIVersion) == SupportedAPIVersion
+	return ping, nil
+}
+
+// GetRepositoryDetails возвращает конфигурацию, информацию и статистику репозитория
+func (pm *PackageManager) GetRepositoryDetails(ctx context.Context, name string) (*RepositoryDetails, error) {
+	repo, exists := pm.configManager.GetRepository(name)
+	if !exists {
+		return nil, fmt.Errorf("repository not found: %s", name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(pm.configManager.GetConfig().Timeout)*time.Second)
+	defer cancel()
+
+	client := pm.repositoryClient(*repo)
+
+	info, err := client.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository info: %w", err)
+	}
+
+	stats, err := client.Stats(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository stats: %w", err)
+	}
+
+	return &RepositoryDetails{
+		Repository: *repo,
+		Server:     info,
+		Stats:      stats,
+		RateLimit:  pm.configManager.GetConfig().RateLimit.ForRepository(name),
+	}, nil
+}
+
+// apiVersion извлекает версию API из ответа GET /api/v1/
+func apiVersion(info map[string]interface{}) string {
+	for _, key := range []string{"api_version", "apiVersion", "version"} {
+		if value, ok := info[key]; ok {
+			return fmt.Sprintf("%v", value)
+		}
+	}
+	return ""
+}
+
+// majorVersion возвращает основную часть версии: "v1.2" -> "1"
+func majorVersion(version string) string {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.Index(version, "."); i >= 0 {
+		version = version[:i]
+	}
+	return version
+}
@@ -0,0 +1,370 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiPrefix префикс API v1 criage-server
+const apiPrefix = "/api/v1"
+
+// RepositoryClient типизированный клиент API v1 criage-server
+type RepositoryClient struct {
+	baseURL     string
+	authToken   string
+	username    string
+	password    string
+	httpClient  *http.Client
+	rateLimiter *RateLimiter
+	// transferClient используется для скачивания и загрузки файлов; nil - httpClient
+	transferClient *http.Client
+
+	// credentials возвращает учетные данные из хранилища; вызывается при первом
+	// запросе, если токен и имя пользователя не заданы явно
+	credentials     func(ctx context.Context) (*Credential, error)
+	credentialsOnce sync.Once
+	credentialsErr  error
+}
+
+// NewRepositoryClient создает клиент для репозитория по адресу baseURL.
+// rateLimiter может быть nil
+func NewRepositoryClient(baseURL, authToken string, httpClient *http.Client, rateLimiter *RateLimiter) *RepositoryClient {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &RepositoryClient{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		authToken:   authToken,
+		httpClient:  httpClient,
+		rateLimiter: rateLimiter,
+	}
+}
+
+// repositoryClient создает клиент для репозитория из конфигурации. Учетные данные,
+// записанные в конфигурации, имеют приоритет; иначе они запрашиваются у хранилища
+// учетных данных при первом запросе к репозиторию
+func (pm *PackageManager) repositoryClient(repo Repository) *RepositoryClient {
+	client := NewRepositoryClient(repo.URL, repo.AuthToken, pm.httpClient, pm.rateLimiterFor(repo.Name))
+	client.username = repo.Username
+	client.password = repo.Password
+	client.transferClient = pm.transferClient
+	client.credentials = func(ctx context.Context) (*Credential, error) {
+		return pm.storedCredential(ctx, repo)
+	}
+	return client
+}
+
+// APIError ошибка, возвращенная сервером репозитория
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case e.StatusCode == http.StatusUnauthorized:
+		return "invalid authorization token"
+	case e.StatusCode == http.StatusTooManyRequests:
+		return "too many requests: rate limited by the repository server"
+	case e.Message != "" && e.StatusCode != 0:
+		return fmt.Sprintf("server error %d: %s", e.StatusCode, e.Message)
+	case e.Message != "":
+		return fmt.Sprintf("API error: %s", e.Message)
+	default:
+		return fmt.Sprintf("server error: %d", e.StatusCode)
+	}
+}
+
+// IsNotFound возвращает true, если сервер ответил 404
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// apiEnvelope ответ API с отложенным декодированием данных
+type apiEnvelope struct {
+	Success bool            `json:"success"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Message string          `json:"message,omitempty"`
+}
+
+// PackageListResponse структура ответа для списка пакетов
+type PackageListResponse struct {
+	Packages   []*PackageEntry `json:"packages"`
+	Total      int             `json:"total"`
+	Page       int             `json:"page"`
+	Limit      int             `json:"limit"`
+	TotalPages int             `json:"total_pages"`
+}
+
+// SearchResponse страница результатов эндпоинта поиска и общее число найденных пакетов
+type SearchResponse struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+}
+
+// endpoint строит URL эндпоинта, экранируя каждый сегмент пути
+func (c *RepositoryClient) endpoint(query url.Values, segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+
+	endpoint := c.baseURL + apiPrefix + "/" + strings.Join(escaped, "/")
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	return endpoint
+}
+
+// newRequest создает запрос с заголовками авторизации
+func (c *RepositoryClient) newRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if err := c.resolveCredentials(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return req, nil
+}
+
+// resolveCredentials однократно запрашивает учетные данные у хранилища
+func (c *RepositoryClient) resolveCredentials(ctx context.Context) error {
+	if c.credentials == nil {
+		return nil
+	}
+
+	c.credentialsOnce.Do(func() {
+		if c.authToken != "" || c.username != "" {
+			return
+		}
+		credential, err := c.credentials(ctx)
+		if err != nil {
+			c.credentialsErr = fmt.Errorf("failed to get credentials: %w", err)
+			return
+		}
+		if credential != nil {
+			c.authToken = credential.Token
+			c.username = credential.Username
+			c.password = credential.Password
+		}
+	})
+	return c.credentialsErr
+}
+
+// send выполняет запрос к API с учетом rate limiting
+func (c *RepositoryClient) send(req *http.Request) (*http.Response, error) {
+	return c.sendWith(c.httpClient, req)
+}
+
+// sendTransfer выполняет запрос на передачу файла пакета: таймаут API к нему не применяется
+func (c *RepositoryClient) sendTransfer(req *http.Request) (*http.Response, error) {
+	if c.transferClient != nil {
+		return c.sendWith(c.transferClient, req)
+	}
+	return c.sendWith(c.httpClient, req)
+}
+
+// maxRateLimitRetries число повторов запроса после ответа 429
+const maxRateLimitRetries = 3
+
+// sendWith выполняет запрос клиентом client с учетом rate limiting. На ответ 429
+// rate limiter снижает скорость и выжидает Retry-After, после чего запрос повторяется,
+// если его тело можно отправить повторно
+func (c *RepositoryClient) sendWith(client *http.Client, req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if c.rateLimiter != nil {
+			if err := c.rateLimiter.Wait(req.Context()); err != nil {
+				return nil, fmt.Errorf("failed to wait for rate limiter: %w", err)
+			}
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute request: %w", err)
+		}
+		if c.rateLimiter == nil {
+			return resp, nil
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			c.rateLimiter.Success()
+			return resp, nil
+		}
+
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		c.rateLimiter.Throttle(retryAfter)
+		if attempt >= maxRateLimitRetries || retryAfter > maxRetryAfter || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to retry request: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// do выполняет запрос к API и декодирует поле data ответа в out (если out не nil)
+func (c *RepositoryClient) do(req *http.Request, out interface{}) error {
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, out)
+}
+
+// decodeResponse проверяет статус ответа API и декодирует поле data в out (если out не nil)
+func decodeResponse(resp *http.Response, out interface{}) error {
+	var envelope apiEnvelope
+	decodeErr := json.NewDecoder(resp.Body).Decode(&envelope)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &APIError{StatusCode: resp.StatusCode, Message: envelope.Error}
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("failed to decode response: %w", decodeErr)
+	}
+	if !envelope.Success {
+		return &APIError{Message: envelope.Error}
+	}
+
+	if out != nil && len(envelope.Data) > 0 {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			return fmt.Errorf("unexpected API response format: %w", err)
+		}
+	}
+	return nil
+}
+
+// get выполняет GET-запрос к эндпоинту
+func (c *RepositoryClient) get(ctx context.Context, query url.Values, out interface{}, segments ...string) error {
+	req, err := c.newRequest(ctx, http.MethodGet, c.endpoint(query, segments...), nil)
+	if err != nil {
+		return err
+	}
+	return c.do(req, out)
+}
+
+// Info возвращает информацию о сервере (GET /api/v1/)
+func (c *RepositoryClient) Info(ctx context.Context) (map[string]interface{}, error) {
+	var info map[string]interface{}
+	if err := c.get(ctx, nil, &info, ""); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Stats возвращает статистику репозитория (GET /api/v1/stats)
+func (c *RepositoryClient) Stats(ctx context.Context) (*RepositoryStats, error) {
+	var stats RepositoryStats
+	if err := c.get(ctx, nil, &stats, "stats"); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Refresh перестраивает индекс пакетов на сервере (POST /api/v1/refresh)
+func (c *RepositoryClient) Refresh(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodPost, c.endpoint(nil, "refresh"), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.do(req, nil)
+}
+
+// ListPackages возвращает страницу списка пакетов (GET /api/v1/packages)
+func (c *RepositoryClient) ListPackages(ctx context.Context, page, limit int) (*PackageListResponse, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("limit", strconv.Itoa(limit))
+
+	var list PackageListResponse
+	if err := c.get(ctx, query, &list, "packages"); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetPackage возвращает запись о пакете со всеми версиями (GET /api/v1/packages/{name})
+func (c *RepositoryClient) GetPackage(ctx context.Context, name string) (*PackageEntry, error) {
+	var entry PackageEntry
+	if err := c.get(ctx, nil, &entry, "packages", name); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetPackageVersion возвращает информацию о версии пакета (GET /api/v1/packages/{name}/{version})
+func (c *RepositoryClient) GetPackageVersion(ctx context.Context, name, version string) (*VersionEntry, error) {
+	var entry VersionEntry
+	if err := c.get(ctx, nil, &entry, "packages", name, version); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Search ищет пакеты (GET /api/v1/search) и возвращает результаты вместе с общим
+// числом найденных пакетов. params содержит дополнительные параметры запроса
+// (фильтры, сортировка, страница), q задается отдельно
+func (c *RepositoryClient) Search(ctx context.Context, query string, params url.Values) (*SearchResponse, error) {
+	values := url.Values{}
+	for key, value := range params {
+		values[key] = value
+	}
+	values.Set("q", query)
+
+	var response SearchResponse
+	if err := c.get(ctx, values, &response, "search"); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DownloadURL возвращает адрес файла пакета (GET /api/v1/download/{name}/{version}/{filename})
+func (c *RepositoryClient) DownloadURL(name, version, filename string) string {
+	return c.endpoint(nil, "download", name, version, filename)
+}
+
+// Download скачивает файл пакета в w и возвращает число записанных байт
+func (c *RepositoryClient) Download(ctx context.Context, name, version, filename string, w io.Writer) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, c.DownloadURL(name, version, filename), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.sendTransfer(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, &APIError{StatusCode: resp.StatusCode}
+	}
+
+	return io.Copy(w, resp.Body)
+}
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestRepositoryClientEscaping проверяет экранирование пути и параметров запроса
+func TestRepositoryClientEscaping(t *testing.T) {
+	var gotPath, gotQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotQuery = r.URL.Query().Get("q")
+		json.NewEncoder(w).Encode(ApiResponse{Success: true, Data: map[string]interface{}{
+			"results": []SearchResult{{Name: "a&b", Version: "1.0.0"}},
+		}})
+	}))
+	defer server.Close()
+
+	client := NewRepositoryClient(server.URL+"/", "", server.Client(), nil)
+
+	results, err := client.Search(context.Background(), "a&b c/d?", nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if gotQuery != "a&b c/d?" {
+		t.Errorf("expected query to be preserved, got %q", gotQuery)
+	}
+	if len(results.Results) != 1 || results.Results[0].Name != "a&b" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	if _, err := client.GetPackageVersion(context.Background(), "pkg/../x", "1.0 beta"); err != nil {
+		t.Fatalf("GetPackageVersion failed: %v", err)
+	}
+	if gotPath != "/api/v1/packages/pkg%2F..%2Fx/1.0%20beta" {
+		t.Errorf("unexpected escaped path: %s", gotPath)
+	}
+}
+
+// TestRepositoryClientErrors проверяет обработку ошибок API
+func TestRepositoryClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/packages/missing":
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ApiResponse{Error: "package not found"})
+		case "/api/v1/refresh":
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(ApiResponse{Success: true})
+		default:
+			json.NewEncoder(w).Encode(ApiResponse{Success: false, Error: "broken"})
+		}
+	}))
+	defer server.Close()
+
+	client := NewRepositoryClient(server.URL, "", server.Client(), nil)
+
+	if _, err := client.GetPackage(context.Background(), "missing"); !IsNotFound(err) {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if err := client.Refresh(context.Background()); err == nil || err.Error() != "invalid authorization token" {
+		t.Errorf("expected authorization error, got %v", err)
+	}
+	if err := NewRepositoryClient(server.URL, "secret", server.Client(), nil).Refresh(context.Background()); err != nil {
+		t.Errorf("expected refresh with token to succeed, got %v", err)
+	}
+	if _, err := client.Stats(context.Background()); err == nil || err.Error() != "API error: broken" {
+		t.Errorf("expected API error, got %v", err)
+	}
+}
+
+// TestClientForConfiguredRepository проверяет, что запрос по адресу репозитория из
+// конфигурации использует его учетные данные
+func TestClientForConfiguredRepository(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		json.NewEncoder(w).Encode(ApiResponse{Success: true, Data: map[string]interface{}{}})
+	}))
+	defer server.Close()
+
+	pm := newTestPackageManager(t.TempDir())
+	pm.configManager.config.Repositories = []Repository{{Name: "corp", URL: server.URL, AuthToken: "secret", Enabled: true}}
+
+	if _, err := pm.GetRepositoryInfo(context.Background(), server.URL+"/"); err != nil {
+		t.Fatalf("GetRepositoryInfo failed: %v", err)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("expected configured token, got %q", auth)
+	}
+	if _, exists := pm.rateLimiters["corp"]; !exists {
+		t.Error("rate limiter must be keyed by repository name")
+	}
+}
@@ -0,0 +1,505 @@
+package pkg
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SBOMFileName файл SBOM пакета в корне архива
+const SBOMFileName = ".criage-sbom.json"
+
+// Форматы SBOM
+const (
+	SBOMFormatSPDX      = "spdx"
+	SBOMFormatCycloneDX = "cyclonedx"
+	SBOMFormatNone      = "none"
+)
+
+// sbomOptions параметры SBOM, встраиваемого в архив
+type sbomOptions struct {
+	format       string
+	dependencies []sbomDependency
+}
+
+// sbomDependency зависимость пакета. Version - установленная версия, удовлетворяющая
+// ограничению; пустая, если зависимость не установлена
+type sbomDependency struct {
+	Name       string
+	Constraint string
+	Version    string
+}
+
+// packedFile файл пакета с контрольными суммами
+type packedFile struct {
+	Name   string
+	SHA1   string
+	SHA256 string
+}
+
+// ValidateSBOMFormat проверяет формат SBOM; пустая строка означает none
+func ValidateSBOMFormat(format string) error {
+	switch format {
+	case "", SBOMFormatSPDX, SBOMFormatCycloneDX, SBOMFormatNone:
+		return nil
+	default:
+		return fmt.Errorf("unsupported SBOM format: %s (expected %s, %s or %s)", format, SBOMFormatSPDX, SBOMFormatCycloneDX, SBOMFormatNone)
+	}
+}
+
+// newSBOMOptions возвращает параметры SBOM для формата format или nil без SBOM.
+// Версии зависимостей берутся из установленных пакетов
+func (pm *PackageManager) newSBOMOptions(format string, manifest *PackageManifest) *sbomOptions {
+	if format == "" || format == SBOMFormatNone {
+		return nil
+	}
+
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	options := &sbomOptions{format: format}
+	for _, name := range names {
+		dependency := sbomDependency{Name: name, Constraint: manifest.Dependencies[name]}
+		if info, ok := pm.getInstalledPackage(name); ok && satisfiesConstraint(info.Version, dependency.Constraint) {
+			dependency.Version = info.Version
+		}
+		options.dependencies = append(options.dependencies, dependency)
+	}
+	return options
+}
+
+// ReadSBOM возвращает SBOM из архива пакета или установленного пакета target
+func (pm *PackageManager) ReadSBOM(target string) ([]byte, error) {
+	if info, err := os.Stat(target); err == nil && info.Mode().IsRegular() {
+		data, err := readArchiveFile(target, pm.archiveManager.DetectFormat(target), SBOMFileName)
+		if errors.Is(err, errArchiveFileNotFound) {
+			return nil, fmt.Errorf("archive has no SBOM: %s", target)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		return data, nil
+	}
+
+	info, exists := pm.getInstalledPackage(target)
+	if !exists {
+		return nil, fmt.Errorf("package is not installed and no such archive: %s", target)
+	}
+	data, err := os.ReadFile(installedSBOMPath(info.InstallPath))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("package has no SBOM: %s", target)
+	}
+	return data, err
+}
+
+// installedSBOMPath возвращает путь к SBOM установленного пакета
+func installedSBOMPath(installPath string) string {
+	return filepath.Join(installPath, ".criage", "sbom.json")
+}
+
+// saveInstalledSBOM сохраняет SBOM из распакованного архива extractDir рядом с
+// информацией об установленном пакете. Пакеты без SBOM пропускаются
+func saveInstalledSBOM(extractDir, installPath string) error {
+	data, err := os.ReadFile(filepath.Join(extractDir, SBOMFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	sbomPath := installedSBOMPath(installPath)
+	if err := os.MkdirAll(filepath.Dir(sbomPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(sbomPath, data, 0644)
+}
+
+// satisfiesConstraint проверяет версию на соответствие ограничению
+func satisfiesConstraint(version, constraint string) bool {
+	c, err := ParseConstraint(constraint)
+	if err != nil {
+		return false
+	}
+	v, err := ParseVersion(version)
+	if err != nil {
+		return false
+	}
+	return c.Check(v)
+}
+
+// generateSBOM создает SBOM пакета в формате options.format со списком файлов files
+func generateSBOM(options *sbomOptions, metadata *PackageMetadata, files []packedFile) ([]byte, error) {
+	if metadata.PackageManifest == nil {
+		return nil, fmt.Errorf("package manifest is missing")
+	}
+
+	var document any
+	switch options.format {
+	case SBOMFormatSPDX:
+		document = newSPDXDocument(metadata.PackageManifest, options.dependencies, files, metadata.CreatedAt)
+	case SBOMFormatCycloneDX:
+		document = newCycloneDXDocument(metadata.PackageManifest, options.dependencies, files, metadata.CreatedAt)
+	default:
+		return nil, fmt.Errorf("unsupported SBOM format: %s", options.format)
+	}
+	return json.MarshalIndent(document, "", "  ")
+}
+
+// hashPackedFiles вычисляет SHA-1 и SHA-256 файлов entries; директории пропускаются
+func hashPackedFiles(entries []archiveEntry) ([]packedFile, error) {
+	var files []packedFile
+	for _, entry := range entries {
+		if entry.info.IsDir() {
+			continue
+		}
+		file, err := hashPackedFile(entry)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// hashPackedFile вычисляет SHA-1 и SHA-256 файла пакета; для символической ссылки -
+// ее цели, как она записана в архив
+func hashPackedFile(entry archiveEntry) (packedFile, error) {
+	sha1Hash, sha256Hash := sha1.New(), sha256.New()
+	if entry.link != "" {
+		io.WriteString(io.MultiWriter(sha1Hash, sha256Hash), filepath.ToSlash(entry.link))
+	} else {
+		file, err := os.Open(entry.path)
+		if err != nil {
+			return packedFile{}, err
+		}
+		defer file.Close()
+
+		if _, err := io.Copy(io.MultiWriter(sha1Hash, sha256Hash), file); err != nil {
+			return packedFile{}, err
+		}
+	}
+	return packedFile{
+		Name:   entry.name,
+		SHA1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+	}, nil
+}
+
+// sbomDigest возвращает хеш содержимого пакета, из которого строятся идентификаторы
+// документа: одинаковые пакеты получают одинаковый SBOM
+func sbomDigest(manifest *PackageManifest, files []packedFile) []byte {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s@%s\n", manifest.Name, manifest.Version)
+	for _, file := range files {
+		fmt.Fprintf(hash, "%s %s\n", file.SHA256, file.Name)
+	}
+	return hash.Sum(nil)
+}
+
+// sbomPURL возвращает package URL пакета criage
+func sbomPURL(name, version string) string {
+	purl := "pkg:generic/" + name
+	if version != "" {
+		purl += "@" + version
+	}
+	return purl
+}
+
+// sbomTool возвращает имя и версию criage для поля создателя SBOM
+func sbomTool() string {
+	if BuildVersion == "" {
+		return "criage"
+	}
+	return "criage-" + BuildVersion
+}
+
+// spdxDocument документ SPDX 2.3 в формате JSON
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Files             []spdxFile         `json:"files,omitempty"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string                `json:"SPDXID"`
+	Name             string                `json:"name"`
+	VersionInfo      string                `json:"versionInfo,omitempty"`
+	DownloadLocation string                `json:"downloadLocation"`
+	FilesAnalyzed    bool                  `json:"filesAnalyzed"`
+	VerificationCode *spdxVerificationCode `json:"packageVerificationCode,omitempty"`
+	LicenseConcluded string                `json:"licenseConcluded"`
+	LicenseDeclared  string                `json:"licenseDeclared"`
+	CopyrightText    string                `json:"copyrightText"`
+	Supplier         string                `json:"supplier,omitempty"`
+	Homepage         string                `json:"homepage,omitempty"`
+	Comment          string                `json:"comment,omitempty"`
+	ExternalRefs     []spdxExternalRef     `json:"externalRefs,omitempty"`
+}
+
+type spdxVerificationCode struct {
+	Value string `json:"packageVerificationCodeValue"`
+}
+
+type spdxExternalRef struct {
+	Category string `json:"referenceCategory"`
+	Type     string `json:"referenceType"`
+	Locator  string `json:"referenceLocator"`
+}
+
+type spdxFile struct {
+	SPDXID           string         `json:"SPDXID"`
+	FileName         string         `json:"fileName"`
+	Checksums        []spdxChecksum `json:"checksums"`
+	LicenseConcluded string         `json:"licenseConcluded"`
+	CopyrightText    string         `json:"copyrightText"`
+}
+
+type spdxChecksum struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"checksumValue"`
+}
+
+type spdxRelationship struct {
+	Element string `json:"spdxElementId"`
+	Type    string `json:"relationshipType"`
+	Related string `json:"relatedSpdxElement"`
+}
+
+// spdxNoAssertion значение SPDX для неизвестных полей
+const spdxNoAssertion = "NOASSERTION"
+
+// newSPDXDocument создает документ SPDX: пакет, его файлы и зависимости
+func newSPDXDocument(manifest *PackageManifest, dependencies []sbomDependency, files []packedFile, created time.Time) *spdxDocument {
+	license := manifest.License
+	if license == "" {
+		license = spdxNoAssertion
+	}
+
+	root := spdxPackage{
+		SPDXID:           "SPDXRef-Package",
+		Name:             manifest.Name,
+		VersionInfo:      manifest.Version,
+		DownloadLocation: spdxNoAssertion,
+		FilesAnalyzed:    true,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  license,
+		CopyrightText:    spdxNoAssertion,
+		Homepage:         manifest.Homepage,
+		ExternalRefs: []spdxExternalRef{{
+			Category: "PACKAGE-MANAGER",
+			Type:     "purl",
+			Locator:  sbomPURL(manifest.Name, manifest.Version),
+		}},
+	}
+	if manifest.Author != "" {
+		root.Supplier = "Person: " + manifest.Author
+	}
+
+	document := &spdxDocument{
+		SPDXVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              manifest.Name + "-" + manifest.Version,
+		DocumentNamespace: fmt.Sprintf("https://criage.ru/spdx/%s-%s-%x", manifest.Name, manifest.Version, sbomDigest(manifest, files)[:16]),
+		CreationInfo: spdxCreationInfo{
+			Created:  created.UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: " + sbomTool()},
+		},
+		Relationships: []spdxRelationship{{Element: "SPDXRef-DOCUMENT", Type: "DESCRIBES", Related: root.SPDXID}},
+	}
+
+	// Код проверки пакета - SHA-1 от отсортированных SHA-1 файлов (SPDX 2.3, 7.9)
+	fileHashes := make([]string, 0, len(files))
+	for i, file := range files {
+		id := fmt.Sprintf("SPDXRef-File-%d", i+1)
+		document.Files = append(document.Files, spdxFile{
+			SPDXID:   id,
+			FileName: "./" + file.Name,
+			Checksums: []spdxChecksum{
+				{Algorithm: "SHA1", Value: file.SHA1},
+				{Algorithm: "SHA256", Value: file.SHA256},
+			},
+			LicenseConcluded: spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+		})
+		document.Relationships = append(document.Relationships, spdxRelationship{Element: root.SPDXID, Type: "CONTAINS", Related: id})
+		fileHashes = append(fileHashes, file.SHA1)
+	}
+	sort.Strings(fileHashes)
+	verificationCode := sha1.Sum([]byte(strings.Join(fileHashes, "")))
+	root.VerificationCode = &spdxVerificationCode{Value: hex.EncodeToString(verificationCode[:])}
+
+	document.Packages = append(document.Packages, root)
+	for i, dependency := range dependencies {
+		id := fmt.Sprintf("SPDXRef-Dependency-%d", i+1)
+		dependencyPackage := spdxPackage{
+			SPDXID:           id,
+			Name:             dependency.Name,
+			VersionInfo:      dependency.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  spdxNoAssertion,
+			CopyrightText:    spdxNoAssertion,
+			Comment:          "version constraint: " + dependency.Constraint,
+		}
+		if dependency.Version != "" {
+			dependencyPackage.ExternalRefs = []spdxExternalRef{{
+				Category: "PACKAGE-MANAGER",
+				Type:     "purl",
+				Locator:  sbomPURL(dependency.Name, dependency.Version),
+			}}
+		}
+		document.Packages = append(document.Packages, dependencyPackage)
+		document.Relationships = append(document.Relationships, spdxRelationship{Element: root.SPDXID, Type: "DEPENDS_ON", Related: id})
+	}
+
+	return document
+}
+
+// cycloneDXDocument документ CycloneDX 1.5 в формате JSON
+type cycloneDXDocument struct {
+	BOMFormat    string                `json:"bomFormat"`
+	SpecVersion  string                `json:"specVersion"`
+	SerialNumber string                `json:"serialNumber"`
+	Version      int                   `json:"version"`
+	Metadata     cycloneDXMetadata     `json:"metadata"`
+	Components   []cycloneDXComponent  `json:"components,omitempty"`
+	Dependencies []cycloneDXDependency `json:"dependencies"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     cycloneDXTools     `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTools struct {
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXComponent struct {
+	Type       string              `json:"type"`
+	BOMRef     string              `json:"bom-ref,omitempty"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Author     string              `json:"author,omitempty"`
+	PURL       string              `json:"purl,omitempty"`
+	Hashes     []cycloneDXHash     `json:"hashes,omitempty"`
+	Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+	Properties []cycloneDXProperty `json:"properties,omitempty"`
+}
+
+type cycloneDXHash struct {
+	Algorithm string `json:"alg"`
+	Content   string `json:"content"`
+}
+
+type cycloneDXLicense struct {
+	License cycloneDXLicenseChoice `json:"license"`
+}
+
+type cycloneDXLicenseChoice struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type cycloneDXDependency struct {
+	Ref       string   `json:"ref"`
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// newCycloneDXDocument создает документ CycloneDX: пакет, его зависимости и файлы
+func newCycloneDXDocument(manifest *PackageManifest, dependencies []sbomDependency, files []packedFile, created time.Time) *cycloneDXDocument {
+	root := cycloneDXComponent{
+		Type:    "application",
+		BOMRef:  sbomPURL(manifest.Name, manifest.Version),
+		Name:    manifest.Name,
+		Version: manifest.Version,
+		Author:  manifest.Author,
+		PURL:    sbomPURL(manifest.Name, manifest.Version),
+	}
+	if manifest.License != "" {
+		root.Licenses = []cycloneDXLicense{{License: cycloneDXLicenseChoice{Name: manifest.License}}}
+	}
+
+	// Серийный номер - UUID версии 5 из хеша содержимого пакета
+	digest := sbomDigest(manifest, files)
+	digest[6] = digest[6]&0x0f | 0x50
+	digest[8] = digest[8]&0x3f | 0x80
+
+	document := &cycloneDXDocument{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", digest[0:4], digest[4:6], digest[6:8], digest[8:10], digest[10:16]),
+		Version:      1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: created.UTC().Format(time.RFC3339),
+			Tools: cycloneDXTools{Components: []cycloneDXComponent{{
+				Type:    "application",
+				Name:    "criage",
+				Version: BuildVersion,
+			}}},
+			Component: root,
+		},
+	}
+
+	rootDependency := cycloneDXDependency{Ref: root.BOMRef}
+	for _, dependency := range dependencies {
+		component := cycloneDXComponent{
+			Type:       "library",
+			BOMRef:     sbomPURL(dependency.Name, dependency.Version),
+			Name:       dependency.Name,
+			Version:    dependency.Version,
+			Properties: []cycloneDXProperty{{Name: "criage:constraint", Value: dependency.Constraint}},
+		}
+		if dependency.Version != "" {
+			component.PURL = component.BOMRef
+		}
+		document.Components = append(document.Components, component)
+		document.Dependencies = append(document.Dependencies, cycloneDXDependency{Ref: component.BOMRef})
+		rootDependency.DependsOn = append(rootDependency.DependsOn, component.BOMRef)
+	}
+	document.Dependencies = append([]cycloneDXDependency{rootDependency}, document.Dependencies...)
+
+	for _, file := range files {
+		document.Components = append(document.Components, cycloneDXComponent{
+			Type:   "file",
+			BOMRef: "file:" + file.Name,
+			Name:   file.Name,
+			Hashes: []cycloneDXHash{
+				{Algorithm: "SHA-1", Content: file.SHA1},
+				{Algorithm: "SHA-256", Content: file.SHA256},
+			},
+		})
+	}
+
+	return document
+}
@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestGenerateSBOM проверяет зависимости и контрольные суммы файлов в SBOM обоих форматов
+func TestGenerateSBOM(t *testing.T) {
+	source := t.TempDir()
+	if err := os.WriteFile(filepath.Join(source, "tool"), []byte("hello"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := collectArchiveEntries(source, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := hashPackedFiles(entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newTestPackageManager(t.TempDir())
+	pm.installedPackages["libfoo"] = &PackageInfo{Name: "libfoo", Version: "1.4.2"}
+	pm.installedPackages["libbar"] = &PackageInfo{Name: "libbar", Version: "3.0.0"}
+
+	manifest := &PackageManifest{
+		Name:         "tool",
+		Version:      "1.0.0",
+		License:      "MIT",
+		Dependencies: map[string]string{"libfoo": "^1.2.0", "libbar": "^2.0.0"},
+	}
+	metadata := &PackageMetadata{PackageManifest: manifest, CreatedAt: time.Unix(1700000000, 0)}
+	const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	t.Run("spdx", func(t *testing.T) {
+		data, err := generateSBOM(pm.newSBOMOptions(SBOMFormatSPDX, manifest), metadata, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var document spdxDocument
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(document.Packages) != 3 || document.Packages[0].LicenseDeclared != "MIT" {
+			t.Fatalf("unexpected packages: %+v", document.Packages)
+		}
+		// Зависимости отсортированы; libbar 3.0.0 не удовлетворяет ^2.0.0
+		if bar, foo := document.Packages[1], document.Packages[2]; bar.Name != "libbar" || bar.VersionInfo != "" || foo.Name != "libfoo" || foo.VersionInfo != "1.4.2" {
+			t.Errorf("unexpected dependencies: %+v, %+v", bar, foo)
+		}
+		if len(document.Files) != 1 || document.Files[0].FileName != "./tool" || document.Files[0].Checksums[1].Value != helloSHA256 {
+			t.Errorf("unexpected files: %+v", document.Files)
+		}
+		if document.CreationInfo.Created != "2023-11-14T22:13:20Z" {
+			t.Errorf("created = %s", document.CreationInfo.Created)
+		}
+	})
+
+	t.Run("cyclonedx", func(t *testing.T) {
+		data, err := generateSBOM(pm.newSBOMOptions(SBOMFormatCycloneDX, manifest), metadata, files)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var document cycloneDXDocument
+		if err := json.Unmarshal(data, &document); err != nil {
+			t.Fatal(err)
+		}
+
+		if document.Metadata.Component.Name != "tool" || len(document.Components) != 3 {
+			t.Fatalf("unexpected components: %+v", document.Components)
+		}
+		if foo := document.Components[1]; foo.Name != "libfoo" || foo.PURL != "pkg:generic/libfoo@1.4.2" {
+			t.Errorf("unexpected dependency: %+v", foo)
+		}
+		if file := document.Components[2]; file.Type != "file" || file.Hashes[1].Content != helloSHA256 {
+			t.Errorf("unexpected file: %+v", file)
+		}
+		if len(document.Dependencies) != 3 || len(document.Dependencies[0].DependsOn) != 2 {
+			t.Errorf("unexpected dependency graph: %+v", document.Dependencies)
+		}
+	})
+}
+
+// TestReadSBOM проверяет чтение SBOM из архива и установленного пакета
+func TestReadSBOM(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "app")
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "tool"), []byte("tool"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	pm := newTestPackageManager(dir)
+	archiveManager, err := NewCommonArchiveManager(pm.configManager.GetConfig(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archiveManager.Close()
+	pm.archiveManager = archiveManager
+
+	for _, format := range []string{"tar.zst", "zip"} {
+		output := filepath.Join(dir, "tool."+format)
+		if _, err := pm.PackDirectory(context.Background(), source, PackOptions{
+			Name: "tool", Version: "1.0.0", Output: output, Format: format, SBOMFormat: SBOMFormatCycloneDX,
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := pm.ReadSBOM(output)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		var document cycloneDXDocument
+		if err := json.Unmarshal(data, &document); err != nil || document.BOMFormat != "CycloneDX" {
+			t.Errorf("%s: unexpected SBOM: %s", format, data)
+		}
+
+		// Установка сохраняет SBOM рядом с информацией о пакете
+		extracted := t.TempDir()
+		if err := archiveManager.ExtractArchive(output, extracted, ArchiveFormat(format)); err != nil {
+			t.Fatal(err)
+		}
+		installPath := filepath.Join(dir, "installed-"+format)
+		if err := saveInstalledSBOM(extracted, installPath); err != nil {
+			t.Fatal(err)
+		}
+		pm.installedPackages["tool"] = &PackageInfo{Name: "tool", InstallPath: installPath}
+		if installed, err := pm.ReadSBOM("tool"); err != nil || string(installed) != string(data) {
+			t.Errorf("%s: installed SBOM differs: %v", format, err)
+		}
+	}
+
+	if _, err := pm.ReadSBOM("missing"); err == nil {
+		t.Error("expected error for unknown package")
+	}
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ListScripts возвращает скрипты из секции scripts манифеста текущего проекта
+func (pm *PackageManager) ListScripts() (map[string]string, error) {
+	manifest, err := pm.configManager.LoadLocalConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load local config: %w", err)
+	}
+	return manifest.Scripts, nil
+}
+
+// RunScript выполняет скрипт name из секции scripts манифеста текущего проекта.
+// Аргументы args передаются скрипту как позиционные параметры оболочки и
+// дописываются к команде. Скрипт получает CRIAGE_PACKAGE_NAME и CRIAGE_VERSION.
+// При ненулевом коде выхода возвращается *exec.ExitError
+func (pm *PackageManager) RunScript(ctx context.Context, name string, args []string) error {
+	manifest, err := pm.configManager.LoadLocalConfig(".")
+	if err != nil {
+		return fmt.Errorf("failed to load local config: %w", err)
+	}
+
+	script, exists := manifest.Scripts[name]
+	if !exists {
+		return fmt.Errorf("script not found: %s (available: %s)", name, strings.Join(scriptNames(manifest.Scripts), ", "))
+	}
+
+	if len(args) > 0 {
+		script += ` "$@"`
+	}
+	cmd := shellCommand(ctx, script)
+	// $0 - имя скрипта, далее аргументы командной строки
+	cmd.Args = append(cmd.Args, name)
+	cmd.Args = append(cmd.Args, args...)
+	cmd.Env = mergeEnv(os.Environ(), map[string]string{
+		"CRIAGE_PACKAGE_NAME": manifest.Name,
+		"CRIAGE_VERSION":      manifest.Version,
+	})
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
+
+// scriptNames возвращает отсортированные имена скриптов
+func scriptNames(scripts map[string]string) []string {
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
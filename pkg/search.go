@@ -0,0 +1,431 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Поля сортировки результатов поиска
+const (
+	SearchSortScore     = "score"
+	SearchSortDownloads = "downloads"
+	SearchSortUpdated   = "updated"
+	SearchSortName      = "name"
+)
+
+// RepositorySearchResult результат поиска с указанием репозитория-источника
+type RepositorySearchResult struct {
+	SearchResult
+	Repository string `json:"repository"`
+	Priority   int    `json:"priority"`
+}
+
+// Размер страницы результатов поиска
+const (
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 100
+)
+
+// maxSearchWindow число первых результатов каждого репозитория, из которых собираются
+// страницы при поиске в нескольких репозиториях или с фильтрами
+const maxSearchWindow = 1000
+
+// SearchOptions параметры поиска пакетов
+type SearchOptions struct {
+	// Repository ограничивает поиск одним репозиторием
+	Repository string
+	// Timeout ограничивает время ожидания ответа каждого репозитория.
+	// По умолчанию используется timeout из конфигурации
+	Timeout time.Duration
+
+	// Фильтры передаются серверу и повторно применяются на клиенте
+	OS      string
+	Arch    string
+	Author  string
+	License string
+	Keyword string
+
+	// Sort поле сортировки: score, downloads, updated или name (по умолчанию score)
+	Sort string
+	// Limit размер страницы до MaxSearchLimit, 0 - DefaultSearchLimit
+	Limit int
+	// Page номер страницы, начиная с 1
+	Page int
+}
+
+// SearchResults страница результатов поиска
+type SearchResults struct {
+	Results []RepositorySearchResult `json:"results"`
+	Total   int                      `json:"total"`
+	Page    int                      `json:"page"`
+	Limit   int                      `json:"limit"`
+}
+
+// hasFilters возвращает true, если задан хотя бы один фильтр
+func (o SearchOptions) hasFilters() bool {
+	return o.Author != "" || o.hasEntryFilters()
+}
+
+// hasEntryFilters возвращает true, если заданы фильтры, для проверки которых
+// нужна полная запись о пакете
+func (o SearchOptions) hasEntryFilters() bool {
+	return o.OS != "" || o.Arch != "" || o.License != "" || o.Keyword != ""
+}
+
+// SearchPackages ищет пакеты одновременно во всех включенных репозиториях.
+// Фильтры, сортировка и страница передаются серверу. В одном репозитории без
+// фильтров страница запрашивается у сервера. Иначе у каждого репозитория частями
+// до MaxSearchLimit запрашиваются первые Page*Limit результатов, подходящих под
+// фильтры на клиенте (серверы без поддержки фильтров их игнорируют), и страница
+// формируется после дедупликации по имени пакета: остается запись из репозитория
+// с наивысшим приоритетом
+func (pm *PackageManager) SearchPackages(ctx context.Context, query string, options SearchOptions) (*SearchResults, error) {
+	switch options.Sort {
+	case "":
+		options.Sort = SearchSortScore
+	case SearchSortScore, SearchSortDownloads, SearchSortUpdated, SearchSortName:
+	default:
+		return nil, fmt.Errorf("invalid sort field: %s (expected score, downloads, updated or name)", options.Sort)
+	}
+	if options.Limit == 0 {
+		options.Limit = DefaultSearchLimit
+	}
+	if options.Limit < 1 || options.Limit > MaxSearchLimit {
+		return nil, fmt.Errorf("limit must be between 1 and %d", MaxSearchLimit)
+	}
+	if options.Page < 1 {
+		options.Page = 1
+	}
+
+	var repositories []Repository
+	for _, repo := range pm.configManager.GetRepositories() {
+		if options.Repository != "" {
+			if repo.Name == options.Repository {
+				repositories = append(repositories, repo)
+			}
+			continue
+		}
+		if repo.Enabled {
+			repositories = append(repositories, repo)
+		}
+	}
+
+	if options.Repository != "" && len(repositories) == 0 {
+		return nil, fmt.Errorf("repository not found: %s", options.Repository)
+	}
+
+	// Страница собирается на клиенте, если репозиториев несколько или результаты
+	// сервера нужно проверить фильтрами
+	merged := len(repositories) > 1 || options.hasFilters()
+	if merged && options.Page*options.Limit > maxSearchWindow {
+		return nil, fmt.Errorf("page %d is beyond the first %d results of each repository; narrow the query", options.Page, maxSearchWindow)
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(pm.configManager.GetConfig().Timeout) * time.Second
+	}
+
+	repoResults := make([]*SearchResponse, len(repositories))
+	var wg sync.WaitGroup
+
+	for i, repo := range repositories {
+		wg.Add(1)
+		go func(i int, repo Repository) {
+			defer wg.Done()
+
+			repoCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			var response *SearchResponse
+			var err error
+			if merged {
+				response, err = pm.collectSearchResults(repoCtx, repo, query, options, options.Page*options.Limit)
+			} else {
+				response, err = pm.searchInRepository(repoCtx, repo, query, options, options.Page, options.Limit)
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "Предупреждение: failed to search in repository %s: %v\n", repo.Name, err)
+				return
+			}
+			repoResults[i] = response
+		}(i, repo)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Дедупликация по имени с учетом приоритета репозитория. Total - сумма
+	// значений серверов за вычетом найденных дубликатов
+	total := 0
+	byName := make(map[string]RepositorySearchResult)
+	for i, repo := range repositories {
+		response := repoResults[i]
+		if response == nil {
+			continue
+		}
+		if merged {
+			total += response.Total
+		} else {
+			total += max(response.Total, (options.Page-1)*options.Limit+len(response.Results))
+		}
+		for _, result := range response.Results {
+			existing, exists := byName[result.Name]
+			if exists {
+				total--
+				if existing.Priority >= repo.Priority {
+					continue
+				}
+			}
+			byName[result.Name] = RepositorySearchResult{
+				SearchResult: result,
+				Repository:   repo.Name,
+				Priority:     repo.Priority,
+			}
+		}
+	}
+
+	results := make([]RepositorySearchResult, 0, len(byName))
+	for _, result := range byName {
+		results = append(results, result)
+	}
+	sortSearchResults(results, options.Sort)
+
+	if merged {
+		start := min((options.Page-1)*options.Limit, len(results))
+		results = results[start:min(start+options.Limit, len(results))]
+	}
+
+	pm.fillSearchStatistics(ctx, results, repositories)
+
+	return &SearchResults{
+		Results: results,
+		Total:   total,
+		Page:    options.Page,
+		Limit:   options.Limit,
+	}, nil
+}
+
+// fillSearchStatistics дополняет загрузками и датой обновления из записей о пакетах
+// результаты страницы, для которых сервер их не вернул. Записи запрашиваются
+// параллельно; ошибка получения записи оставляет результат без изменений
+func (pm *PackageManager) fillSearchStatistics(ctx context.Context, results []RepositorySearchResult, repositories []Repository) {
+	reposByName := make(map[string]Repository, len(repositories))
+	for _, repo := range repositories {
+		reposByName[repo.Name] = repo
+	}
+
+	workers := pm.configManager.GetConfig().Parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	semaphore := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i := range results {
+		if results[i].Downloads != 0 || !results[i].Updated.IsZero() {
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			entry, err := pm.fetchPackageEntry(ctx, reposByName[results[i].Repository], results[i].Name)
+			if err != nil {
+				return
+			}
+			results[i].Downloads = entry.Downloads
+			results[i].Updated = entry.Updated
+		}(i)
+	}
+	wg.Wait()
+}
+
+// collectSearchResults запрашивает у репозитория результаты частями до MaxSearchLimit,
+// пока не наберется count подходящих под фильтры, результаты не закончатся или не
+// будут просмотрены первые maxSearchWindow. Total ответа - оценка числа подходящих
+// результатов по доле подходящих среди просмотренных
+func (pm *PackageManager) collectSearchResults(ctx context.Context, repo Repository, query string, options SearchOptions, count int) (*SearchResponse, error) {
+	limit := min(count, MaxSearchLimit)
+	collected := &SearchResponse{}
+	serverTotal, scanned := 0, 0
+
+	for page := 1; len(collected.Results) < count && scanned < maxSearchWindow; page++ {
+		response, err := pm.searchInRepository(ctx, repo, query, options, page, limit)
+		if err != nil {
+			return nil, err
+		}
+		serverTotal = response.Total
+		scanned += len(response.Results)
+
+		matched, err := pm.filterSearchResults(ctx, repo, response.Results, options)
+		if err != nil {
+			return nil, err
+		}
+		collected.Results = append(collected.Results, matched...)
+
+		if len(response.Results) < limit {
+			// Результаты сервера закончились, число подходящих известно точно
+			collected.Total = len(collected.Results)
+			return collected, nil
+		}
+	}
+
+	// Для непросмотренных результатов сервера доля подходящих считается такой же
+	collected.Total = len(collected.Results)
+	if scanned > 0 && serverTotal > scanned {
+		collected.Total += (serverTotal - scanned) * len(collected.Results) / scanned
+	}
+	return collected, nil
+}
+
+// filterSearchResults применяет фильтры к результатам сервера. Для фильтров по
+// платформе, лицензии и ключевому слову записи о пакетах запрашиваются параллельно
+// и заодно дополняют результаты статистикой; результат, запись которого получить
+// не удалось, отбрасывается
+func (pm *PackageManager) filterSearchResults(ctx context.Context, repo Repository, results []SearchResult, options SearchOptions) ([]SearchResult, error) {
+	filtered := make([]SearchResult, 0, len(results))
+	for _, result := range results {
+		if options.Author == "" || strings.EqualFold(result.Author, options.Author) {
+			filtered = append(filtered, result)
+		}
+	}
+	if !options.hasEntryFilters() {
+		return filtered, nil
+	}
+
+	workers := pm.configManager.GetConfig().Parallel
+	if workers < 1 {
+		workers = 1
+	}
+
+	keep := make([]bool, len(filtered))
+	semaphore := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+
+	for i := range filtered {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			entry, err := pm.fetchPackageEntry(ctx, repo, filtered[i].Name)
+			if err != nil || !packageEntryMatches(entry, options) {
+				return
+			}
+			if filtered[i].Downloads == 0 && filtered[i].Updated.IsZero() {
+				filtered[i].Downloads = entry.Downloads
+				filtered[i].Updated = entry.Updated
+			}
+			keep[i] = true
+		}(i)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	matched := filtered[:0]
+	for i, result := range filtered {
+		if keep[i] {
+			matched = append(matched, result)
+		}
+	}
+	return matched, nil
+}
+
+// packageEntryMatches проверяет запись о пакете на соответствие фильтрам
+func packageEntryMatches(entry *PackageEntry, options SearchOptions) bool {
+	if options.License != "" && !strings.EqualFold(entry.License, options.License) {
+		return false
+	}
+
+	if options.Keyword != "" {
+		found := false
+		for _, keyword := range entry.Keywords {
+			if strings.EqualFold(keyword, options.Keyword) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if options.OS != "" || options.Arch != "" {
+		for _, version := range entry.Versions {
+			for _, file := range version.Files {
+				if (options.OS == "" || file.OS == options.OS) && (options.Arch == "" || file.Arch == options.Arch) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// sortSearchResults сортирует результаты по указанному полю
+func sortSearchResults(results []RepositorySearchResult, field string) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		switch field {
+		case SearchSortDownloads:
+			if a.Downloads != b.Downloads {
+				return a.Downloads > b.Downloads
+			}
+		case SearchSortUpdated:
+			if !a.Updated.Equal(b.Updated) {
+				return a.Updated.After(b.Updated)
+			}
+		case SearchSortName:
+			return a.Name < b.Name
+		default:
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+// searchInRepository запрашивает у репозитория страницу page размером limit с
+// фильтрами и сортировкой options
+func (pm *PackageManager) searchInRepository(ctx context.Context, repo Repository, query string, options SearchOptions, page, limit int) (*SearchResponse, error) {
+	params := url.Values{}
+	for key, value := range map[string]string{
+		"os":      options.OS,
+		"arch":    options.Arch,
+		"author":  options.Author,
+		"license": options.License,
+		"keyword": options.Keyword,
+		"sort":    options.Sort,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(limit))
+
+	return pm.repositoryClient(repo).Search(ctx, query, params)
+}
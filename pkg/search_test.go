@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// searchServer репозиторий с результатами поиска, упорядоченными по score, который
+// игнорирует фильтры. Записи о пакетах содержат загрузки и лицензию из licenses;
+// число запросов записей считается в entries, наибольший запрошенный limit - в maxLimit
+type searchServer struct {
+	mu       sync.Mutex
+	results  []SearchResult
+	licenses map[string]string
+	query    url.Values
+	entries  int
+	maxLimit int
+}
+
+func (s *searchServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name, ok := strings.CutPrefix(r.URL.Path, "/api/v1/packages/"); ok {
+		s.entries++
+		json.NewEncoder(w).Encode(ApiResponse{Success: true, Data: PackageEntry{Name: name, Downloads: 7, License: s.licenses[name]}})
+		return
+	}
+
+	s.query = r.URL.Query()
+	page, _ := strconv.Atoi(s.query.Get("page"))
+	limit, _ := strconv.Atoi(s.query.Get("limit"))
+	s.maxLimit = max(s.maxLimit, limit)
+	start := min((page-1)*limit, len(s.results))
+	json.NewEncoder(w).Encode(ApiResponse{Success: true, Data: SearchResponse{
+		Results: s.results[start:min(start+limit, len(s.results))],
+		Total:   len(s.results),
+	}})
+}
+
+// TestSearchPackages проверяет, что страница и фильтры передаются серверу, общее
+// число берется из ответов серверов, а записи о пакетах запрашиваются только для
+// результатов страницы без статистики
+func TestSearchPackages(t *testing.T) {
+	main := &searchServer{}
+	for i := 0; i < 30; i++ {
+		main.results = append(main.results, SearchResult{Name: fmt.Sprintf("pkg%02d", i), Score: float64(100 - i), Downloads: 1})
+	}
+	extra := &searchServer{results: []SearchResult{{Name: "pkg00", Score: 200}, {Name: "tool", Score: 95.5}}}
+
+	mainServer := httptest.NewServer(main)
+	defer mainServer.Close()
+	extraServer := httptest.NewServer(extra)
+	defer extraServer.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	pm := newTestPackageManager(t.TempDir())
+	pm.configManager.config.Timeout = 10
+	pm.configManager.config.Repositories = []Repository{
+		{Name: "main", URL: mainServer.URL, Priority: 10, Enabled: true},
+		{Name: "extra", URL: extraServer.URL, Priority: 1, Enabled: true},
+	}
+
+	results, err := pm.SearchPackages(context.Background(), "pkg", SearchOptions{Repository: "main", Sort: SearchSortName, Limit: 10, Page: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.query.Get("page") != "3" || main.query.Get("limit") != "10" || main.query.Get("sort") != "name" {
+		t.Errorf("unexpected query: %v", main.query)
+	}
+	if results.Total != 30 || len(results.Results) != 10 || results.Results[0].Name != "pkg20" {
+		t.Errorf("unexpected page: total %d, %+v", results.Total, results.Results)
+	}
+	if main.entries != 0 {
+		t.Errorf("package entries requested for results with statistics: %d", main.entries)
+	}
+
+	// В нескольких репозиториях pkg00 дублируется и остается из main
+	results, err = pm.SearchPackages(context.Background(), "pkg", SearchOptions{Limit: 5, Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extra.query.Get("page") != "1" || extra.query.Get("limit") != "10" {
+		t.Errorf("unexpected query: %v", extra.query)
+	}
+	var names []string
+	for _, result := range results.Results {
+		names = append(names, result.Repository+"/"+result.Name)
+	}
+	if results.Total != 31 || strings.Join(names, " ") != "extra/tool main/pkg05 main/pkg06 main/pkg07 main/pkg08" {
+		t.Errorf("unexpected page: total %d, %v", results.Total, names)
+	}
+	if extra.entries != 1 || results.Results[0].Downloads != 7 {
+		t.Errorf("expected one entry request for tool, got %d", extra.entries)
+	}
+
+	if _, err := pm.SearchPackages(context.Background(), "pkg", SearchOptions{Limit: 100, Page: 11}); err == nil {
+		t.Error("expected error for a page beyond the search window")
+	}
+}
+
+// TestSearchPackagesClientFilters проверяет фильтрацию на клиенте для сервера, который
+// игнорирует фильтры, и запрос результатов частями не больше MaxSearchLimit
+func TestSearchPackagesClientFilters(t *testing.T) {
+	main := &searchServer{licenses: make(map[string]string)}
+	for i := 0; i < 250; i++ {
+		name := fmt.Sprintf("pkg%03d", i)
+		main.results = append(main.results, SearchResult{Name: name, Score: float64(1000 - i), Downloads: 1})
+		if i%5 == 0 {
+			main.licenses[name] = "MIT"
+		}
+	}
+	extra := &searchServer{results: []SearchResult{{Name: "tool", Score: 1}}}
+
+	mainServer := httptest.NewServer(main)
+	defer mainServer.Close()
+	extraServer := httptest.NewServer(extra)
+	defer extraServer.Close()
+
+	t.Setenv("HOME", t.TempDir())
+	pm := newTestPackageManager(t.TempDir())
+	pm.configManager.config.Timeout = 10
+	pm.configManager.config.Parallel = 8
+	pm.configManager.config.Repositories = []Repository{
+		{Name: "main", URL: mainServer.URL, Enabled: true},
+		{Name: "extra", URL: extraServer.URL, Enabled: true},
+	}
+
+	results, err := pm.SearchPackages(context.Background(), "pkg", SearchOptions{Repository: "main", License: "mit", Limit: 10, Page: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.query.Get("license") != "mit" {
+		t.Errorf("filter not sent to the server: %v", main.query)
+	}
+	if results.Total != 50 || len(results.Results) != 10 || results.Results[0].Name != "pkg050" || results.Results[9].Name != "pkg095" {
+		t.Errorf("unexpected filtered page: total %d, %+v", results.Total, results.Results)
+	}
+
+	results, err = pm.SearchPackages(context.Background(), "pkg", SearchOptions{Limit: 100, Page: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if main.maxLimit > MaxSearchLimit {
+		t.Errorf("requested %d results in one page", main.maxLimit)
+	}
+	if results.Total != 251 || len(results.Results) != 51 || results.Results[0].Name != "pkg200" || results.Results[50].Repository != "extra" {
+		t.Errorf("unexpected page: total %d, %d results", results.Total, len(results.Results))
+	}
+}
+
+// TestSortSearchResults проверяет сортировку результатов поиска
+func TestSortSearchResults(t *testing.T) {
+	now := time.Now()
+	results := []RepositorySearchResult{
+		{SearchResult: SearchResult{Name: "b", Score: 1, Downloads: 30, Updated: now.Add(-time.Hour)}},
+		{SearchResult: SearchResult{Name: "a", Score: 2, Downloads: 10, Updated: now}},
+		{SearchResult: SearchResult{Name: "c", Score: 2, Downloads: 20, Updated: now.Add(-2 * time.Hour)}},
+	}
+
+	tests := map[string]string{
+		SearchSortScore:     "acb",
+		SearchSortDownloads: "bca",
+		SearchSortUpdated:   "abc",
+		SearchSortName:      "abc",
+	}
+
+	for field, expected := range tests {
+		sorted := append([]RepositorySearchResult(nil), results...)
+		sortSearchResults(sorted, field)
+		got := ""
+		for _, result := range sorted {
+			got += result.Name
+		}
+		if got != expected {
+			t.Errorf("sort by %s: expected %s, got %s", field, expected, got)
+		}
+	}
+}
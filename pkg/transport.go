@@ -0,0 +1,240 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// BuildVersion версия criage, подставляется в User-Agent по умолчанию
+var BuildVersion = "1.0.0"
+
+// UserAgent возвращает заголовок User-Agent согласно настройкам
+func UserAgent(network NetworkConfig, version string) string {
+	if network.UserAgent != "" {
+		return network.UserAgent
+	}
+	return "criage/" + version
+}
+
+// NewHTTPTransport создает HTTP-транспорт по сетевым настройкам конфигурации:
+// прокси, дополнительные корневые сертификаты, клиентский сертификат,
+// таймаут соединения и размер пула соединений
+func NewHTTPTransport(config *Config, version string) (http.RoundTripper, error) {
+	network := config.Network
+
+	tlsConfig, err := newTLSConfig(network)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxyFunc(network)
+	if err != nil {
+		return nil, err
+	}
+
+	connectTimeout := time.Duration(network.ConnectTimeout) * time.Second
+	dialer := &net.Dialer{
+		Timeout:   connectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   connectTimeout,
+		MaxIdleConns:          network.MaxConnections * 4,
+		MaxIdleConnsPerHost:   network.MaxConnections,
+		MaxConnsPerHost:       network.MaxConnections,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &userAgentTransport{base: transport, userAgent: UserAgent(network, version)}, nil
+}
+
+// newHTTPClients создает клиент для запросов к API, ограниченный общим timeout,
+// и клиент для передачи файлов пакетов с отдельным network.download_timeout.
+// Оба клиента используют один пул соединений
+func newHTTPClients(config *Config, version string) (*http.Client, *http.Client, error) {
+	transport, err := NewHTTPTransport(config, version)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	apiClient := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(config.Timeout) * time.Second,
+	}
+	transferClient := &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(config.Network.DownloadTimeout) * time.Second,
+	}
+	return apiClient, transferClient, nil
+}
+
+// userAgentTransport добавляет заголовок User-Agent к запросам без него
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// errorTransport возвращает ошибку настройки транспорта на каждый запрос
+type errorTransport struct {
+	err error
+}
+
+func (t errorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// newTLSConfig загружает дополнительные корневые сертификаты и клиентский сертификат
+func newTLSConfig(network NetworkConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if network.CABundle != "" {
+		pem, err := os.ReadFile(network.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+
+		// Сертификаты из bundle дополняют системные, а не заменяют их
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA bundle %s", network.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case network.ClientCert != "" && network.ClientKey != "":
+		certificate, err := tls.LoadX509KeyPair(network.ClientCert, network.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	case network.ClientCert != "" || network.ClientKey != "":
+		return nil, fmt.Errorf("network.client_cert and network.client_key must be set together")
+	}
+
+	return tlsConfig, nil
+}
+
+// parseProxyURL разбирает адрес прокси; адрес без схемы считается http://
+func parseProxyURL(value string) (*url.URL, error) {
+	if !strings.Contains(value, "://") {
+		value = "http://" + value
+	}
+
+	proxyURL, err := url.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", value, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q (expected http, https or socks5 scheme)", value)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", value)
+	}
+	return proxyURL, nil
+}
+
+// newProxyFunc возвращает функцию выбора прокси. Явный network.proxy заменяет
+// HTTP_PROXY/HTTPS_PROXY; network.no_proxy дополняет NO_PROXY
+func newProxyFunc(network NetworkConfig) (func(*http.Request) (*url.URL, error), error) {
+	noProxy := splitNoProxy(network.NoProxy)
+
+	if network.Proxy == "" {
+		return func(req *http.Request) (*url.URL, error) {
+			if matchNoProxy(req.URL, noProxy) {
+				return nil, nil
+			}
+			return http.ProxyFromEnvironment(req)
+		}, nil
+	}
+
+	proxyURL, err := parseProxyURL(network.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	noProxy = append(noProxy, splitNoProxy(os.Getenv("NO_PROXY"))...)
+	noProxy = append(noProxy, splitNoProxy(os.Getenv("no_proxy"))...)
+
+	return func(req *http.Request) (*url.URL, error) {
+		if matchNoProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+// splitNoProxy разбирает список хостов через запятую
+func splitNoProxy(value string) []string {
+	var entries []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.ToLower(strings.TrimSpace(entry)); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// matchNoProxy проверяет хост по списку исключений в формате NO_PROXY:
+// "*", домен (с поддоменами, допускается ведущая точка), IP-адрес или подсеть CIDR,
+// с необязательным портом
+func matchNoProxy(target *url.URL, entries []string) bool {
+	host := strings.ToLower(target.Hostname())
+	port := target.Port()
+	if port == "" {
+		port = map[string]string{"http": "80", "https": "443"}[target.Scheme]
+	}
+	ip := net.ParseIP(host)
+
+	for _, entry := range entries {
+		if entry == "*" {
+			return true
+		}
+
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost, entryPort := entry, ""
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			entryHost, entryPort = h, p
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+
+		entryHost = strings.TrimPrefix(entryHost, ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
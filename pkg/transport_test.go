@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMatchNoProxy проверяет разбор исключений NO_PROXY
+func TestMatchNoProxy(t *testing.T) {
+	entries := splitNoProxy("localhost, .corp.example.com,10.0.0.0/8, repo.example.org:8443")
+	tests := map[string]bool{
+		"http://localhost/api":                true,
+		"https://packages.corp.example.com/x": true,
+		"https://corp.example.com/x":          true,
+		"https://example.com/x":               false,
+		"http://10.1.2.3/x":                   true,
+		"http://192.168.1.1/x":                false,
+		"https://repo.example.org:8443/x":     true,
+		"https://repo.example.org/x":          false,
+	}
+	for raw, expected := range tests {
+		target, _ := url.Parse(raw)
+		if matchNoProxy(target, entries) != expected {
+			t.Errorf("matchNoProxy(%s) = %t, expected %t", raw, !expected, expected)
+		}
+	}
+}
+
+// TestHTTPTransport проверяет прокси из конфигурации, дополнительный CA и User-Agent
+func TestHTTPTransport(t *testing.T) {
+	var userAgent string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(bundle, certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultConfig()
+	config.Network.CABundle = bundle
+	config.Network.Proxy = "proxy.example.com:3128"
+	config.Network.NoProxy = "127.0.0.1"
+
+	transport, err := NewHTTPTransport(config, "2.3.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("request with custom CA failed: %v", err)
+	}
+	resp.Body.Close()
+	if userAgent != "criage/2.3.4" {
+		t.Errorf("expected User-Agent criage/2.3.4, got %q", userAgent)
+	}
+
+	proxy := transport.(*userAgentTransport).base.(*http.Transport).Proxy
+	req, _ := http.NewRequest(http.MethodGet, "https://packages.example.com/", nil)
+	proxyURL, err := proxy(req)
+	if err != nil || proxyURL == nil || proxyURL.String() != "http://proxy.example.com:3128" {
+		t.Errorf("expected configured proxy, got %v, %v", proxyURL, err)
+	}
+
+	config.Network.ClientCert = bundle
+	if _, err := NewHTTPTransport(config, "2.3.4"); err == nil {
+		t.Error("expected error when client_cert is set without client_key")
+	}
+}
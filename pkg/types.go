@@ -22,18 +22,77 @@ type Repository = commontypes.Repository
 
 // Config представляет конфигурацию criage
 type Config struct {
-	GlobalPath   string                 `yaml:"global_path" json:"global_path"`
-	LocalPath    string                 `yaml:"local_path" json:"local_path"`
-	CachePath    string                 `yaml:"cache_path" json:"cache_path"`
-	TempPath     string                 `yaml:"temp_path" json:"temp_path"`
-	Repositories []Repository           `yaml:"repositories" json:"repositories"`
-	Compression  CompressionConfig      `yaml:"compression" json:"compression"`
-	Parallel     int                    `yaml:"parallel" json:"parallel"`
-	Timeout      int                    `yaml:"timeout" json:"timeout"`
-	RetryCount   int                    `yaml:"retry_count" json:"retry_count"`
-	AutoUpdate   bool                   `yaml:"auto_update" json:"auto_update"`
-	VerifyHashes bool                   `yaml:"verify_hashes" json:"verify_hashes"`
-	Settings     map[string]interface{} `yaml:"settings" json:"settings"`
+	ConfigVersion int               `yaml:"config_version" json:"config_version"`
+	GlobalPath    string            `yaml:"global_path" json:"global_path"`
+	LocalPath     string            `yaml:"local_path" json:"local_path"`
+	CachePath     string            `yaml:"cache_path" json:"cache_path"`
+	TempPath      string            `yaml:"temp_path" json:"temp_path"`
+	Repositories  []Repository      `yaml:"repositories" json:"repositories"`
+	Compression   CompressionConfig `yaml:"compression" json:"compression"`
+	Parallel      int               `yaml:"parallel" json:"parallel"`
+	Timeout       int               `yaml:"timeout" json:"timeout"`
+	RetryCount    int               `yaml:"retry_count" json:"retry_count"`
+	AutoUpdate    bool              `yaml:"auto_update" json:"auto_update"`
+	VerifyHashes  bool              `yaml:"verify_hashes" json:"verify_hashes"`
+	// CredentialHelper внешнее хранилище учетных данных; пустое значение - файл credentials.yaml
+	CredentialHelper string                 `yaml:"credential_helper,omitempty" json:"credential_helper,omitempty"`
+	Network          NetworkConfig          `yaml:"network" json:"network"`
+	RateLimit        RateLimitConfig        `yaml:"rate_limit" json:"rate_limit"`
+	InstallPolicy    InstallPolicyConfig    `yaml:"install_policy" json:"install_policy"`
+	Settings         map[string]interface{} `yaml:"settings" json:"settings"`
+}
+
+// NetworkConfig настройки HTTP-транспорта. Таймауты задаются в секундах;
+// общий timeout конфигурации ограничивает запросы к API
+type NetworkConfig struct {
+	// Proxy прокси для всех запросов; пустое значение - HTTP_PROXY/HTTPS_PROXY из окружения
+	Proxy string `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	// NoProxy хосты через запятую, к которым прокси не применяется (дополняет NO_PROXY)
+	NoProxy string `yaml:"no_proxy,omitempty" json:"no_proxy,omitempty"`
+	// CABundle PEM-файл с дополнительными корневыми сертификатами
+	CABundle string `yaml:"ca_bundle,omitempty" json:"ca_bundle,omitempty"`
+	// ClientCert и ClientKey клиентский сертификат для mTLS
+	ClientCert string `yaml:"client_cert,omitempty" json:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty" json:"client_key,omitempty"`
+	// ConnectTimeout время установки соединения, включая TLS
+	ConnectTimeout int `yaml:"connect_timeout" json:"connect_timeout"`
+	// DownloadTimeout время скачивания или загрузки файла пакета; 0 - без ограничения
+	DownloadTimeout int `yaml:"download_timeout" json:"download_timeout"`
+	// MaxConnections максимальное число соединений с одним хостом
+	MaxConnections int `yaml:"max_connections" json:"max_connections"`
+	// UserAgent заголовок User-Agent; пустое значение - criage/<версия>
+	UserAgent string `yaml:"user_agent,omitempty" json:"user_agent,omitempty"`
+}
+
+// InstallPolicyConfig требования к происхождению устанавливаемых пакетов
+type InstallPolicyConfig struct {
+	// RequireProvenance запрещает установку пакетов без документа происхождения
+	RequireProvenance bool `yaml:"require_provenance" json:"require_provenance"`
+	// RequireCleanTree разрешает только пакеты, собранные из ревизии VCS без
+	// незафиксированных изменений
+	RequireCleanTree bool `yaml:"require_clean_tree" json:"require_clean_tree"`
+}
+
+// RateLimitSettings ограничение частоты запросов к репозиторию
+type RateLimitSettings struct {
+	// RequestsPerSecond скорость пополнения; 0 отключает ограничение
+	RequestsPerSecond float64 `yaml:"requests_per_second" json:"requests_per_second"`
+	// Burst число запросов, которые можно выполнить подряд без ожидания
+	Burst int `yaml:"burst" json:"burst"`
+}
+
+// RateLimitConfig ограничения частоты запросов: общие и для отдельных репозиториев
+type RateLimitConfig struct {
+	RateLimitSettings `yaml:",inline"`
+	Repositories      map[string]RateLimitSettings `yaml:"repositories,omitempty" json:"repositories,omitempty"`
+}
+
+// ForRepository возвращает ограничения репозитория или общие, если для него ничего не задано
+func (c RateLimitConfig) ForRepository(name string) RateLimitSettings {
+	if settings, exists := c.Repositories[name]; exists {
+		return settings
+	}
+	return c.RateLimitSettings
 }
 
 type SearchResult = commontypes.SearchResult
@@ -46,6 +105,8 @@ type FileEntry = commontypes.FileEntry
 
 type ApiResponse = commontypes.ApiResponse
 
+type UploadRequest = commontypes.UploadRequest
+
 type RepositoryStats = commontypes.Statistics
 
 type ArchiveFormat = commontypes.ArchiveFormat
@@ -60,10 +121,11 @@ const (
 // DefaultConfig возвращает конфигурацию по умолчанию
 func DefaultConfig() *Config {
 	return &Config{
-		GlobalPath: "/usr/local/lib/criage",
-		LocalPath:  "./criage_modules",
-		CachePath:  "~/.cache/criage",
-		TempPath:   "/tmp/criage",
+		ConfigVersion: CurrentConfigVersion,
+		GlobalPath:    "/usr/local/lib/criage",
+		LocalPath:     "./criage_modules",
+		CachePath:     "~/.cache/criage",
+		TempPath:      "/tmp/criage",
 		Repositories: []Repository{
 			{
 				Name:     "default",
@@ -81,6 +143,14 @@ func DefaultConfig() *Config {
 		RetryCount:   3,
 		AutoUpdate:   false,
 		VerifyHashes: true,
-		Settings:     make(map[string]interface{}),
+		Network: NetworkConfig{
+			ConnectTimeout:  10,
+			DownloadTimeout: 0,
+			MaxConnections:  10,
+		},
+		RateLimit: RateLimitConfig{
+			RateLimitSettings: RateLimitSettings{RequestsPerSecond: 5, Burst: 5},
+		},
+		Settings: make(map[string]interface{}),
 	}
 }
@@ -0,0 +1,432 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// UploadProgress получает число отправленных байт файла и его размер
+type UploadProgress func(sent, total int64)
+
+// UploadOptions параметры загрузки архива пакета
+type UploadOptions struct {
+	// Name, Version и платформа архива OS/Arch передаются серверу вместе с архивом:
+	// архивы разных целей одной версии не должны заменять друг друга
+	Name    string
+	Version string
+	OS      string
+	Arch    string
+	// Progress вызывается по мере отправки файла; может быть nil
+	Progress UploadProgress
+	// StateDir директория, в которой сохраняется сессия загрузки по частям, чтобы
+	// продолжить ее после перезапуска; пустое значение - сессия не сохраняется
+	StateDir string
+}
+
+var (
+	// uploadChunkSize размер части при загрузке по частям; файлы не больше него
+	// загружаются одним запросом
+	uploadChunkSize int64 = 16 << 20
+	// chunkRetryDelay пауза перед первым повтором части; удваивается с каждой попыткой
+	chunkRetryDelay = time.Second
+)
+
+// maxChunkRetries число повторов одной части после ошибки
+const maxChunkRetries = 5
+
+// errChunkedUploadUnsupported сервер не поддерживает загрузку по частям
+var errChunkedUploadUnsupported = errors.New("chunked upload is not supported by the server")
+
+// Upload загружает архив пакета. Файлы больше uploadChunkSize загружаются по частям
+// (POST /api/v1/uploads), если сервер это поддерживает; иначе архив передается потоком
+// в multipart-форме (POST /api/v1/upload) без буферизации в памяти
+func (c *RepositoryClient) Upload(ctx context.Context, archivePath string, options UploadOptions) error {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+
+	if info.Size() > uploadChunkSize {
+		err := c.uploadChunked(ctx, archivePath, info.Size(), options)
+		if !errors.Is(err, errChunkedUploadUnsupported) {
+			return err
+		}
+	}
+	return c.uploadMultipart(ctx, archivePath, info.Size(), options)
+}
+
+// uploadSessionRequest запрос открытия сессии загрузки с платформой архива
+type uploadSessionRequest struct {
+	UploadRequest
+	OS   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+}
+
+// formFields возвращает поля формы с описанием архива в фиксированном порядке
+func (o UploadOptions) formFields() [][2]string {
+	var fields [][2]string
+	for _, field := range [][2]string{{"name", o.Name}, {"version", o.Version}, {"os", o.OS}, {"arch", o.Arch}} {
+		if field[1] != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// uploadMultipart отправляет архив одним запросом. Форма формируется в io.Pipe по мере
+// чтения тела запроса; для повтора после ответа 429 файл открывается заново
+func (c *RepositoryClient) uploadMultipart(ctx context.Context, archivePath string, size int64, options UploadOptions) error {
+	boundary := multipart.NewWriter(nil).Boundary()
+	filename := filepath.Base(archivePath)
+	fields := options.formFields()
+
+	// Длина формы без содержимого файла: поля, заголовок части и завершающий разделитель
+	var envelope bytes.Buffer
+	writer := multipart.NewWriter(&envelope)
+	writer.SetBoundary(boundary)
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return fmt.Errorf("failed to create form field: %w", err)
+		}
+	}
+	if _, err := writer.CreateFormFile("package", filename); err != nil {
+		return fmt.Errorf("failed to create form file: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finalize form: %w", err)
+	}
+
+	newBody := func() (io.ReadCloser, error) {
+		file, err := os.Open(archivePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open file: %w", err)
+		}
+
+		reader, pipeWriter := io.Pipe()
+		go func() {
+			defer file.Close()
+			writer := multipart.NewWriter(pipeWriter)
+			writer.SetBoundary(boundary)
+			var err error
+			for _, field := range fields {
+				if err = writer.WriteField(field[0], field[1]); err != nil {
+					break
+				}
+			}
+			var part io.Writer
+			if err == nil {
+				part, err = writer.CreateFormFile("package", filename)
+			}
+			if err == nil {
+				_, err = io.Copy(part, &progressReader{reader: file, total: size, progress: options.Progress})
+			}
+			if err == nil {
+				err = writer.Close()
+			}
+			pipeWriter.CloseWithError(err)
+		}()
+		return reader, nil
+	}
+
+	body, err := newBody()
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(ctx, http.MethodPost, c.endpoint(nil, "upload"), body)
+	if err != nil {
+		body.Close()
+		return err
+	}
+	req.ContentLength = int64(envelope.Len()) + size
+	req.GetBody = newBody
+	req.Header.Set("Content-Type", "multipart/form-data; boundary="+boundary)
+
+	resp, err := c.sendTransfer(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decodeResponse(resp, nil)
+}
+
+// uploadSession сессия загрузки по частям; Offset - число байт, принятых сервером
+type uploadSession struct {
+	ID        string `json:"id"`
+	Offset    int64  `json:"offset"`
+	ChunkSize int64  `json:"chunkSize,omitempty"`
+}
+
+// uploadState сохраненная сессия загрузки по частям
+type uploadState struct {
+	Repository string `json:"repository"`
+	Checksum   string `json:"checksum"`
+	SessionID  string `json:"session_id"`
+}
+
+// uploadChunked загружает архив по частям. Неудачная часть повторяется до
+// maxChunkRetries раз с отправкой с позиции, которую сообщает сервер
+func (c *RepositoryClient) uploadChunked(ctx context.Context, archivePath string, size int64, options UploadOptions) error {
+	checksum, err := fileSHA256(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	statePath := c.uploadStatePath(options.StateDir, checksum)
+	session, err := c.resumeUploadSession(ctx, statePath, checksum)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		if session, err = c.createUploadSession(ctx, uploadSessionRequest{
+			UploadRequest: UploadRequest{
+				PackageName: options.Name,
+				Version:     options.Version,
+				Filename:    filepath.Base(archivePath),
+				Size:        size,
+				Checksum:    checksum,
+			},
+			OS:   options.OS,
+			Arch: options.Arch,
+		}); err != nil {
+			return err
+		}
+		saveUploadState(statePath, uploadState{Repository: c.baseURL, Checksum: checksum, SessionID: session.ID})
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	chunkSize := uploadChunkSize
+	if session.ChunkSize > 0 {
+		chunkSize = session.ChunkSize
+	}
+
+	offset, retries := session.Offset, 0
+	for offset < size {
+		if options.Progress != nil {
+			options.Progress(offset, size)
+		}
+
+		length := min(chunkSize, size-offset)
+		next, err := c.uploadChunk(ctx, session.ID, file, offset, length, size)
+		if err == nil {
+			offset, retries = next, 0
+			continue
+		}
+		if !retryableUploadError(ctx, err) || retries >= maxChunkRetries {
+			return fmt.Errorf("failed to upload bytes %d-%d: %w", offset, offset+length-1, err)
+		}
+
+		delay := chunkRetryDelay << retries
+		retries++
+		fmt.Fprintf(os.Stderr, "Ошибка загрузки части (%v), повтор %d из %d через %s\n", err, retries, maxChunkRetries, delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		// Часть могла быть принята сервером частично или полностью
+		current, err := c.getUploadSession(ctx, session.ID)
+		if err != nil {
+			if !retryableUploadError(ctx, err) {
+				return err
+			}
+			continue
+		}
+		offset = current.Offset
+	}
+	if options.Progress != nil {
+		options.Progress(size, size)
+	}
+
+	// Сервер проверяет контрольную сумму собранного файла, поэтому таймаут API не применяется
+	req, err := c.newRequest(ctx, http.MethodPost, c.endpoint(nil, "uploads", session.ID, "complete"), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.sendTransfer(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := decodeResponse(resp, nil); err != nil {
+		return err
+	}
+	if statePath != "" {
+		os.Remove(statePath)
+	}
+	return nil
+}
+
+// uploadStatePath возвращает файл сессии загрузки архива с контрольной суммой checksum
+// в этот репозиторий внутри stateDir или пустую строку, если сессии не сохраняются
+func (c *RepositoryClient) uploadStatePath(stateDir, checksum string) string {
+	if stateDir == "" {
+		return ""
+	}
+	key := sha256.Sum256([]byte(c.baseURL + "\n" + checksum))
+	return filepath.Join(stateDir, hex.EncodeToString(key[:])+".json")
+}
+
+// resumeUploadSession возвращает сохраненную в statePath сессию загрузки того же
+// файла в тот же репозиторий или nil, если продолжать нечего
+func (c *RepositoryClient) resumeUploadSession(ctx context.Context, statePath, checksum string) (*uploadSession, error) {
+	if statePath == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, nil
+	}
+	var state uploadState
+	if err := json.Unmarshal(data, &state); err != nil || state.Repository != c.baseURL || state.Checksum != checksum {
+		return nil, nil
+	}
+
+	session, err := c.getUploadSession(ctx, state.SessionID)
+	if IsNotFound(err) {
+		// Сессия истекла на сервере
+		os.Remove(statePath)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "Продолжение загрузки с позиции %d\n", session.Offset)
+	return session, nil
+}
+
+// createUploadSession открывает сессию загрузки по частям (POST /api/v1/uploads)
+func (c *RepositoryClient) createUploadSession(ctx context.Context, request uploadSessionRequest) (*uploadSession, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newRequest(ctx, http.MethodPost, c.endpoint(nil, "uploads"), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var session uploadSession
+	err = c.do(req, &session)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return nil, errChunkedUploadUnsupported
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if session.ID == "" {
+		return nil, fmt.Errorf("server returned upload session without id")
+	}
+	return &session, nil
+}
+
+// getUploadSession возвращает состояние сессии загрузки (GET /api/v1/uploads/{id})
+func (c *RepositoryClient) getUploadSession(ctx context.Context, id string) (*uploadSession, error) {
+	var session uploadSession
+	if err := c.get(ctx, nil, &session, "uploads", id); err != nil {
+		return nil, err
+	}
+	session.ID = id
+	return &session, nil
+}
+
+// uploadChunk отправляет length байт файла с позиции offset (PUT /api/v1/uploads/{id})
+// и возвращает позицию, до которой сервер принял файл
+func (c *RepositoryClient) uploadChunk(ctx context.Context, id string, file *os.File, offset, length, size int64) (int64, error) {
+	req, err := c.newRequest(ctx, http.MethodPut, c.endpoint(nil, "uploads", id), io.NewSectionReader(file, offset, length))
+	if err != nil {
+		return 0, err
+	}
+	req.ContentLength = length
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(io.NewSectionReader(file, offset, length)), nil
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+length-1, size))
+
+	resp, err := c.sendTransfer(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var session uploadSession
+	if err := decodeResponse(resp, &session); err != nil {
+		return 0, err
+	}
+	if session.Offset <= offset {
+		return 0, fmt.Errorf("server did not accept the chunk (offset %d)", session.Offset)
+	}
+	return session.Offset, nil
+}
+
+// retryableUploadError сообщает, можно ли повторить часть после ошибки err: сетевые
+// ошибки, ответы 5xx, 408, 409 (рассогласование позиции) и 429
+func retryableUploadError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+	switch apiErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return apiErr.StatusCode >= 500
+}
+
+// saveUploadState сохраняет сессию загрузки; ошибка записи только лишает
+// возможности продолжить загрузку после перезапуска
+func saveUploadState(statePath string, state uploadState) {
+	if statePath == "" {
+		return
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(statePath), 0755); err != nil {
+		return
+	}
+	os.WriteFile(statePath, data, 0600)
+}
+
+// progressReader вызывает progress после каждого чтения
+type progressReader struct {
+	reader   io.Reader
+	sent     int64
+	total    int64
+	progress UploadProgress
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.sent += int64(n)
+	if r.progress != nil && n > 0 {
+		r.progress(r.sent, r.total)
+	}
+	return n, err
+}
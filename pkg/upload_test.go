@@ -0,0 +1,232 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setUploadChunkSize уменьшает размер части и паузу повтора на время теста
+func setUploadChunkSize(t *testing.T, size int64) {
+	chunkSize, retryDelay := uploadChunkSize, chunkRetryDelay
+	uploadChunkSize, chunkRetryDelay = size, time.Millisecond
+	t.Cleanup(func() { uploadChunkSize, chunkRetryDelay = chunkSize, retryDelay })
+}
+
+// TestUploadMultipartFallback проверяет потоковую загрузку одним запросом, если
+// сервер не поддерживает загрузку по частям
+func TestUploadMultipartFallback(t *testing.T) {
+	setUploadChunkSize(t, 4)
+	content := "package archive content"
+	archivePath := filepath.Join(t.TempDir(), "tool-1.0.0.criage")
+	if err := os.WriteFile(archivePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/upload" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.ContentLength <= int64(len(content)) {
+			t.Errorf("unexpected content length %d", r.ContentLength)
+		}
+		file, header, err := r.FormFile("package")
+		if err != nil {
+			t.Errorf("failed to read form: %v", err)
+			return
+		}
+		data, _ := io.ReadAll(file)
+		received = r.FormValue("os") + "/" + r.FormValue("arch") + ":" + header.Filename + ":" + string(data)
+		json.NewEncoder(w).Encode(ApiResponse{Success: true})
+	}))
+	defer server.Close()
+
+	var sent, total int64
+	client := NewRepositoryClient(server.URL, "token", server.Client(), nil)
+	err := client.Upload(context.Background(), archivePath, UploadOptions{
+		Name:     "tool",
+		Version:  "1.0.0",
+		OS:       "linux",
+		Arch:     "arm64",
+		Progress: func(s, t int64) { sent, total = s, t },
+	})
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if received != "linux/arm64:tool-1.0.0.criage:"+content {
+		t.Errorf("server received %q", received)
+	}
+	if sent != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("progress = %d/%d", sent, total)
+	}
+}
+
+// chunkServer сервер загрузки по частям. Часть с позицией failAt принимается
+// наполовину и завершается ответом failStatus
+type chunkServer struct {
+	mu         sync.Mutex
+	data       []byte
+	failAt     int64
+	failStatus int
+	completed  bool
+	sessions   int
+}
+
+func (s *chunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reply := func(status int, data interface{}) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ApiResponse{Success: status == http.StatusOK, Data: data})
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/uploads":
+		var request uploadSessionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.Checksum == "" || request.PackageName != "tool" || request.OS != "linux" || request.Arch != "amd64" {
+			reply(http.StatusBadRequest, nil)
+			return
+		}
+		s.sessions++
+		reply(http.StatusOK, uploadSession{ID: "s1"})
+	case r.Method == http.MethodGet && r.URL.Path == "/api/v1/uploads/s1":
+		reply(http.StatusOK, uploadSession{ID: "s1", Offset: int64(len(s.data))})
+	case r.Method == http.MethodPut && r.URL.Path == "/api/v1/uploads/s1":
+		var start, end, size int64
+		fmt.Sscanf(r.Header.Get("Content-Range"), "bytes %d-%d/%d", &start, &end, &size)
+		if start != int64(len(s.data)) {
+			reply(http.StatusConflict, nil)
+			return
+		}
+		chunk, _ := io.ReadAll(r.Body)
+		if start == s.failAt && s.failStatus != 0 {
+			s.data = append(s.data, chunk[:len(chunk)/2]...)
+			status := s.failStatus
+			s.failStatus = 0
+			reply(status, nil)
+			return
+		}
+		s.data = append(s.data, chunk...)
+		reply(http.StatusOK, uploadSession{ID: "s1", Offset: int64(len(s.data))})
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/uploads/s1/complete":
+		s.completed = true
+		reply(http.StatusOK, nil)
+	default:
+		reply(http.StatusNotFound, nil)
+	}
+}
+
+// TestUploadChunked проверяет повтор части после ошибки сервера и продолжение
+// сохраненной сессии после неустранимой ошибки
+func TestUploadChunked(t *testing.T) {
+	setUploadChunkSize(t, 4)
+	dir := t.TempDir()
+	content := strings.Repeat("0123456789", 3)
+	archivePath := filepath.Join(dir, "tool-1.0.0.criage")
+	if err := os.WriteFile(archivePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stateDir := filepath.Join(dir, "uploads")
+	options := UploadOptions{Name: "tool", Version: "1.0.0", OS: "linux", Arch: "amd64", StateDir: stateDir}
+	checksum, err := fileSHA256(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("retry", func(t *testing.T) {
+		handler := &chunkServer{failAt: 8, failStatus: http.StatusBadGateway}
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		var sent int64
+		options := options
+		options.Progress = func(s, _ int64) { sent = s }
+		client := NewRepositoryClient(server.URL, "token", server.Client(), nil)
+		statePath := client.uploadStatePath(stateDir, checksum)
+		if err := client.Upload(context.Background(), archivePath, options); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		if string(handler.data) != content || !handler.completed {
+			t.Errorf("server received %q, completed %v", handler.data, handler.completed)
+		}
+		if sent != int64(len(content)) {
+			t.Errorf("progress = %d", sent)
+		}
+		if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+			t.Error("upload state was not removed")
+		}
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		handler := &chunkServer{failAt: 12, failStatus: http.StatusForbidden}
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		client := NewRepositoryClient(server.URL, "token", server.Client(), nil)
+		statePath := client.uploadStatePath(stateDir, checksum)
+		if other := NewRepositoryClient("https://other.example.com", "", nil, nil); other.uploadStatePath(stateDir, checksum) == statePath {
+			t.Error("upload state of another repository shares the file")
+		}
+		if err := client.Upload(context.Background(), archivePath, options); err == nil {
+			t.Fatal("expected non-retryable error")
+		}
+		if _, err := os.Stat(statePath); err != nil {
+			t.Fatalf("upload state not saved: %v", err)
+		}
+
+		if err := client.Upload(context.Background(), archivePath, options); err != nil {
+			t.Fatalf("resumed Upload failed: %v", err)
+		}
+		if string(handler.data) != content || handler.sessions != 1 {
+			t.Errorf("server received %q in %d sessions", handler.data, handler.sessions)
+		}
+	})
+}
+
+// TestUploadPackageAdHocRegistry проверяет, что загрузка в репозиторий не из конфигурации
+// использует клиент передачи файлов, а не таймаут API
+func TestUploadPackageAdHocRegistry(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	archivePath := filepath.Join(t.TempDir(), "tool-1.0.0.criage")
+	if err := os.WriteFile(archivePath, []byte("package archive content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/upload" {
+			http.NotFound(w, r)
+			return
+		}
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(1500 * time.Millisecond)
+		json.NewEncoder(w).Encode(ApiResponse{Success: true})
+	}))
+	defer server.Close()
+
+	pm := newTestPackageManager(t.TempDir())
+	config := pm.configManager.config
+	config.Timeout = 1
+	config.Network.DownloadTimeout = 30
+	var err error
+	if pm.httpClient, pm.transferClient, err = newHTTPClients(config, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &PackageManifest{Name: "tool", Version: "1.0.0"}
+	if err := pm.uploadPackage(context.Background(), server.URL, "token", archivePath, manifest, BuildTargetConfig{}); err != nil {
+		t.Fatalf("upload to ad-hoc registry failed: %v", err)
+	}
+}
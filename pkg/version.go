@@ -0,0 +1,411 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version семантическая версия пакета (major.minor.patch[-prerelease][+build])
+type Version struct {
+	Major      int
+	Minor      int
+	Patch      int
+	Prerelease string
+	Build      string
+}
+
+// ParseVersion разбирает строку версии. Допускается префикс "v" и
+// отсутствие minor/patch компонент ("1", "1.2")
+func ParseVersion(s string) (*Version, error) {
+	v, _, err := parsePartialVersion(s)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// parsePartialVersion разбирает версию и возвращает количество явно указанных
+// числовых компонент. Компоненты "x", "X" и "*" считаются неуказанными
+func parsePartialVersion(s string) (*Version, int, error) {
+	raw := s
+	s = strings.TrimSpace(s)
+	s = strings.TrimPrefix(s, "v")
+	s = strings.TrimPrefix(s, "V")
+	if s == "" {
+		return nil, 0, fmt.Errorf("invalid version: %q", raw)
+	}
+
+	v := &Version{}
+	if i := strings.Index(s, "+"); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.Index(s, "-"); i >= 0 {
+		v.Prerelease = s[i+1:]
+		s = s[:i]
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return nil, 0, fmt.Errorf("invalid version: %q", raw)
+	}
+
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	specified := 0
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, 0, fmt.Errorf("invalid version: %q", raw)
+		}
+		*numbers[i] = n
+		specified++
+	}
+
+	return v, specified, nil
+}
+
+// String возвращает каноническое представление версии
+func (v *Version) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare сравнивает версии: -1 если v < other, 0 если равны, 1 если v > other.
+// Метаданные сборки не учитываются
+func (v *Version) Compare(other *Version) int {
+	if c := compareInts(v.Major, other.Major); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Minor, other.Minor); c != 0 {
+		return c
+	}
+	if c := compareInts(v.Patch, other.Patch); c != 0 {
+		return c
+	}
+	return comparePrerelease(v.Prerelease, other.Prerelease)
+}
+
+// CompareVersions сравнивает две строки версий. Если одну из строк не удается
+// разобрать, строки сравниваются лексикографически
+func CompareVersions(a, b string) int {
+	va, errA := ParseVersion(a)
+	vb, errB := ParseVersion(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return va.Compare(vb)
+}
+
+// compareInts сравнивает два числа
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// comparePrerelease сравнивает pre-release метки по правилам semver:
+// версия без метки старше версии с меткой, числовые идентификаторы
+// сравниваются численно и младше буквенных
+func comparePrerelease(a, b string) int {
+	if a == b {
+		return 0
+	}
+	if a == "" {
+		return 1
+	}
+	if b == "" {
+		return -1
+	}
+
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if c := compareInts(aNum, bNum); c != 0 {
+				return c
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareInts(len(aParts), len(bParts))
+}
+
+// versionComparator одно условие ограничения версии (например, ">=1.2.0")
+type versionComparator struct {
+	op      string
+	version *Version
+}
+
+// matches проверяет версию на соответствие условию
+func (c versionComparator) matches(v *Version) bool {
+	cmp := v.Compare(c.version)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	default:
+		return false
+	}
+}
+
+// Constraint ограничение версии в стиле npm/semver: "^1.2.0", "~1.2",
+// ">=1.0.0 <2.0.0", "1.x", "1.0.0 - 1.5.0", "^1.0.0 || ^2.0.0"
+type Constraint struct {
+	raw  string
+	sets [][]versionComparator
+}
+
+// ParseConstraint разбирает строку ограничения версии.
+// Пустая строка, "*" и "latest" соответствуют любой версии
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: s}
+
+	for _, alternative := range strings.Split(s, "||") {
+		alternative = strings.TrimSpace(alternative)
+		set, err := parseComparatorSet(alternative)
+		if err != nil {
+			return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+		}
+		c.sets = append(c.sets, set)
+	}
+
+	return c, nil
+}
+
+// String возвращает исходную строку ограничения
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+// Check проверяет, удовлетворяет ли версия ограничению.
+// Pre-release версии подходят только если ограничение явно ссылается на
+// pre-release той же major.minor.patch версии
+func (c *Constraint) Check(v *Version) bool {
+	for _, set := range c.sets {
+		if comparatorSetMatches(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaxSatisfying возвращает наибольшую версию из списка, удовлетворяющую ограничению.
+// Нераспознанные версии пропускаются
+func (c *Constraint) MaxSatisfying(versions []string) (string, bool) {
+	var best *Version
+	bestRaw := ""
+
+	for _, raw := range versions {
+		v, err := ParseVersion(raw)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best = v
+			bestRaw = raw
+		}
+	}
+
+	return bestRaw, best != nil
+}
+
+// MaxVersion возвращает наибольшую из версий или пустую строку
+func MaxVersion(versions []string) string {
+	var best *Version
+	bestRaw := ""
+
+	for _, raw := range versions {
+		v, err := ParseVersion(raw)
+		if err != nil {
+			continue
+		}
+		if best == nil || v.Compare(best) > 0 {
+			best = v
+			bestRaw = raw
+		}
+	}
+
+	return bestRaw
+}
+
+// comparatorSetMatches проверяет версию на соответствие всем условиям набора
+func comparatorSetMatches(set []versionComparator, v *Version) bool {
+	for _, comparator := range set {
+		if !comparator.matches(v) {
+			return false
+		}
+	}
+
+	if v.Prerelease == "" {
+		return true
+	}
+
+	for _, comparator := range set {
+		cv := comparator.version
+		if cv.Prerelease != "" && cv.Major == v.Major && cv.Minor == v.Minor && cv.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+// parseComparatorSet разбирает набор условий, объединенных через пробел (логическое И)
+func parseComparatorSet(s string) ([]versionComparator, error) {
+	if s == "" || s == "*" || s == "latest" {
+		return []versionComparator{{op: ">=", version: &Version{}}}, nil
+	}
+
+	// Диапазон через дефис: "1.2.3 - 2.3.4"
+	if parts := strings.Split(s, " - "); len(parts) == 2 {
+		lower, _, err := parsePartialVersion(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		upper, specified, err := parsePartialVersion(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		set := []versionComparator{{op: ">=", version: lower}}
+		if specified == 3 {
+			set = append(set, versionComparator{op: "<=", version: upper})
+		} else {
+			set = append(set, versionComparator{op: "<", version: bumpVersion(upper, specified)})
+		}
+		return set, nil
+	}
+
+	// Оператор, отделенный пробелом (">= 1.0.0", "^ 1.2"), относится к следующей версии
+	var tokens []string
+	pending := ""
+	for _, field := range strings.Fields(s) {
+		if strings.Trim(field, "<>=^~") == "" {
+			pending += field
+			continue
+		}
+		tokens = append(tokens, pending+field)
+		pending = ""
+	}
+	if pending != "" {
+		return nil, fmt.Errorf("missing version after %q", pending)
+	}
+
+	var set []versionComparator
+	for _, token := range tokens {
+		comparators, err := parseComparator(token)
+		if err != nil {
+			return nil, err
+		}
+		set = append(set, comparators...)
+	}
+
+	return set, nil
+}
+
+// parseComparator разбирает одно условие и раскрывает сокращения (^, ~, x-диапазоны)
+func parseComparator(token string) ([]versionComparator, error) {
+	op := ""
+	for _, prefix := range []string{">=", "<=", "~>", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(token, prefix) {
+			op = prefix
+			token = strings.TrimSpace(token[len(prefix):])
+			break
+		}
+	}
+
+	v, specified, err := parsePartialVersion(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "^":
+		upper := &Version{}
+		switch {
+		case v.Major > 0 || specified < 2:
+			upper.Major = v.Major + 1
+		case v.Minor > 0 || specified < 3:
+			upper.Minor = v.Minor + 1
+		default:
+			upper.Patch = v.Patch + 1
+		}
+		return []versionComparator{{op: ">=", version: v}, {op: "<", version: upper}}, nil
+	case "~", "~>":
+		if specified < 2 {
+			return []versionComparator{{op: ">=", version: v}, {op: "<", version: bumpVersion(v, 1)}}, nil
+		}
+		return []versionComparator{{op: ">=", version: v}, {op: "<", version: bumpVersion(v, 2)}}, nil
+	case ">", "<=":
+		if specified < 3 {
+			// ">1.2" эквивалентно ">=1.3.0", "<=1.2" эквивалентно "<1.3.0"
+			if specified == 0 {
+				if op == ">" {
+					return []versionComparator{{op: "<", version: &Version{}}}, nil
+				}
+				return []versionComparator{{op: ">=", version: &Version{}}}, nil
+			}
+			next := bumpVersion(v, specified)
+			if op == ">" {
+				return []versionComparator{{op: ">=", version: next}}, nil
+			}
+			return []versionComparator{{op: "<", version: next}}, nil
+		}
+		return []versionComparator{{op: op, version: v}}, nil
+	case ">=", "<":
+		return []versionComparator{{op: op, version: v}}, nil
+	default:
+		// Точная версия или x-диапазон
+		if specified == 3 {
+			return []versionComparator{{op: "=", version: v}}, nil
+		}
+		if specified == 0 {
+			return []versionComparator{{op: ">=", version: &Version{}}}, nil
+		}
+		return []versionComparator{{op: ">=", version: v}, {op: "<", version: bumpVersion(v, specified)}}, nil
+	}
+}
+
+// bumpVersion возвращает наименьшую версию, следующую за диапазоном,
+// заданным первыми specified компонентами (например, 1.2 -> 1.3.0)
+func bumpVersion(v *Version, specified int) *Version {
+	switch specified {
+	case 1:
+		return &Version{Major: v.Major + 1}
+	case 2:
+		return &Version{Major: v.Major, Minor: v.Minor + 1}
+	default:
+		return &Version{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+	}
+}
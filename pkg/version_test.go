@@ -0,0 +1,106 @@
+package pkg
+
+import "testing"
+
+// TestCompareVersions проверяет сравнение версий по правилам semver
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"v2.0.0", "1.99.99", 1},
+		{"1.2", "1.2.0", 0},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.11", "1.0.0-beta.2", 1},
+		{"1.0.0+build.1", "1.0.0+build.2", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, expected %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// TestConstraintCheck проверяет разбор и применение ограничений версий
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{"", "3.4.5", true},
+		{"*", "0.0.1", true},
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"^1.2.3", "1.9.0", true},
+		{"^1.2.3", "2.0.0", false},
+		{"^0.2.3", "0.2.9", true},
+		{"^0.2.3", "0.3.0", false},
+		{"^0.0.3", "0.0.4", false},
+		{"~1.2.3", "1.2.9", true},
+		{"~1.2.3", "1.3.0", false},
+		{"~1", "1.9.9", true},
+		{">=1.0.0 <2.0.0", "1.5.0", true},
+		{">=1.0.0 <2.0.0", "2.0.0", false},
+		{">1.2", "1.2.9", false},
+		{">1.2", "1.3.0", true},
+		{"<=1.2", "1.2.9", true},
+		{"1.x", "1.4.0", true},
+		{"1.2.*", "1.3.0", false},
+		{"1.0.0 - 1.5", "1.5.9", true},
+		{"1.0.0 - 1.5.0", "1.5.1", false},
+		{"^1.0.0 || ^3.0.0", "3.1.0", true},
+		{"^1.0.0 || ^3.0.0", "2.1.0", false},
+		{"^1.0.0", "1.1.0-beta", false},
+		{">=1.1.0-alpha", "1.1.0-beta", true},
+		{">= 1.0.0 < 2.0.0", "1.5.0", true},
+		{">= 1.0.0 < 2.0.0", "2.0.0", false},
+		{"^ 1.2", "1.9.0", true},
+		{"~ 1.2.3 || >= 3", "3.0.0", true},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("ParseConstraint(%q) failed: %v", tt.constraint, err)
+		}
+		v, err := ParseVersion(tt.version)
+		if err != nil {
+			t.Fatalf("ParseVersion(%q) failed: %v", tt.version, err)
+		}
+		if got := c.Check(v); got != tt.want {
+			t.Errorf("%q.Check(%q) = %v, expected %v", tt.constraint, tt.version, got, tt.want)
+		}
+	}
+
+	if _, err := ParseConstraint("1.0.0 >="); err == nil {
+		t.Error("expected error for operator without version")
+	}
+}
+
+// TestMaxSatisfying проверяет выбор наибольшей подходящей версии
+func TestMaxSatisfying(t *testing.T) {
+	versions := []string{"1.0.0", "1.4.2", "1.10.0", "2.0.0", "2.1.0-rc.1", "invalid"}
+
+	c, err := ParseConstraint("^1.0.0")
+	if err != nil {
+		t.Fatalf("ParseConstraint failed: %v", err)
+	}
+
+	if got, ok := c.MaxSatisfying(versions); !ok || got != "1.10.0" {
+		t.Errorf("MaxSatisfying = %q, %v, expected 1.10.0", got, ok)
+	}
+
+	if got := MaxVersion(versions); got != "2.1.0-rc.1" {
+		t.Errorf("MaxVersion = %q, expected 2.1.0-rc.1", got)
+	}
+
+	if _, err := ParseConstraint(">=abc"); err == nil {
+		t.Error("expected error for invalid constraint")
+	}
+}